@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// apiKeyPrefix starts every API key so that leaked keys are easy to spot.
+	apiKeyPrefix = "grb_"
+	// ApiKeyPrefixLength is the length of the start of a key shown to tell
+	// keys apart.
+	ApiKeyPrefixLength = len(apiKeyPrefix) + 8
+)
+
+// GenerateApiKey returns a new random API key holding 256 bits of entropy.
+func GenerateApiKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashApiKey returns the hex encoded SHA-256 hash keys are stored and looked
+// up by. Unlike passwords, keys are random enough not to need a slow hash.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiKey(t *testing.T) {
+	// GIVEN
+	key1, err := GenerateApiKey()
+	require.NoError(t, err)
+	key2, err := GenerateApiKey()
+	require.NoError(t, err)
+
+	// THEN
+	assert.True(t, strings.HasPrefix(key1, apiKeyPrefix))
+	assert.Len(t, key1, len(apiKeyPrefix)+43)
+	assert.NotEqual(t, key1, key2)
+	assert.Equal(t, HashApiKey(key1), HashApiKey(key1))
+	assert.NotEqual(t, HashApiKey(key1), HashApiKey(key2))
+	assert.Len(t, HashApiKey(key1), 64)
+}
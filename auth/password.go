@@ -0,0 +1,32 @@
+// Package auth hashes passwords and issues the signed bearer tokens users
+// authenticate with.
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MaxPasswordLength is the longest password bcrypt hashes in full, in bytes.
+const MaxPasswordLength = 72
+
+var ErrWrongPassword = errors.New("wrong password")
+
+// HashPassword returns the bcrypt hash of the password.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword returns ErrWrongPassword unless the password matches the hash.
+func CheckPassword(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrWrongPassword
+	}
+	return err
+}
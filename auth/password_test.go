@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassword(t *testing.T) {
+	// GIVEN
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+
+	// THEN
+	assert.NotContains(t, hash, "correct horse")
+	assert.NoError(t, CheckPassword(hash, "correct horse"))
+	assert.ErrorIs(t, CheckPassword(hash, "wrong horse"), ErrWrongPassword)
+	assert.Error(t, CheckPassword("not a hash", "correct horse"))
+}
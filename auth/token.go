@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// tokenHeader is the fixed header of every token: a JWT signed with
+// HMAC-SHA256.
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Signer issues and verifies the bearer tokens identifying users. Tokens are
+// JWTs signed with HMAC-SHA256, so every token issued with a key stays valid
+// until it expires or the key changes.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Issue returns a token identifying the user until now plus ttl.
+func (s *Signer) Issue(userId uint64, now time.Time, ttl time.Duration) (string, error) {
+	payload, err := json.Marshal(claims{
+		Subject:   strconv.FormatUint(userId, 10),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.sign(unsigned), nil
+}
+
+// Verify returns the id of the user the token identifies, ErrInvalidToken
+// when it was not issued with the key of the signer and ErrTokenExpired when
+// it is no longer valid at now.
+func (s *Signer) Verify(token string, now time.Time) (uint64, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return 0, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return 0, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return 0, ErrInvalidToken
+	}
+	userId, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	if !now.Before(time.Unix(c.ExpiresAt, 0)) {
+		return 0, ErrTokenExpired
+	}
+	return userId, nil
+}
+
+func (s *Signer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Unix(10011, 0)
+	signer := NewSigner([]byte("key"))
+	token, err := signer.Issue(42, now, time.Hour)
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		now     time.Time
+		want    uint64
+		wantErr error
+	}{
+		{name: "valid", signer: signer, token: token, now: now, want: 42},
+		{name: "just before expiry", signer: signer, token: token, now: now.Add(time.Hour - time.Second), want: 42},
+		{name: "expired", signer: signer, token: token, now: now.Add(time.Hour), wantErr: ErrTokenExpired},
+		{name: "other key", signer: NewSigner([]byte("other")), token: token, now: now, wantErr: ErrInvalidToken},
+		{name: "tampered payload", signer: signer, token: parts[0] + "." + parts[1] + "x." + parts[2], now: now, wantErr: ErrInvalidToken},
+		{name: "not a token", signer: signer, token: "token", now: now, wantErr: ErrInvalidToken},
+		{name: "empty", signer: signer, token: "", now: now, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			got, err := tt.signer.Verify(tt.token, tt.now)
+
+			// THEN
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
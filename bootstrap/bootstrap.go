@@ -1,10 +1,100 @@
 package bootstrap
 
 import (
+	"fmt"
+	"os"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/repository"
+	"bitbucket.org/mindera/go-rest-blog/search"
 	"bitbucket.org/mindera/go-rest-blog/service"
 )
 
-func Init(port int) error {
-	api := service.NewRestApiService()
-	return api.ServeContent(port)
+const (
+	MemoryStorage = "memory"
+	FileStorage   = "file"
+)
+
+type Config struct {
+	Port int
+	// Storage selects the persistence backend, either MemoryStorage or FileStorage.
+	Storage string
+	// DataDir is where FileStorage keeps its write-ahead log and snapshot.
+	DataDir string
+	// CompactEvery is the number of log records after which FileStorage writes a new snapshot.
+	CompactEvery int
+	// LegacyErrors answers errors with AckJsonResponse bodies instead of RFC 7807 problem details.
+	LegacyErrors bool
+	// FeedSize is the number of items served in the RSS and Atom feeds.
+	FeedSize int
+	// BaseURL is the absolute URL the service is reachable at, used for links in feeds.
+	BaseURL string
+	// RobotsFile holds the rules served from /robots.txt; every crawler is allowed everywhere when it is empty.
+	RobotsFile string
+	// TokenKey signs bearer tokens; a random key, which invalidates tokens on every restart, is used when it is empty.
+	TokenKey string
+	// TokenTTL is how long bearer tokens stay valid.
+	TokenTTL time.Duration
+	// AdminUsername and AdminPassword are the credentials of the admin created, or promoted, on startup; no admin is
+	// seeded when AdminUsername is empty.
+	AdminUsername string
+	AdminPassword string
+}
+
+func Init(cfg Config) error {
+	var opts []service.Option
+	if cfg.LegacyErrors {
+		opts = append(opts, service.WithLegacyErrors())
+	}
+	if cfg.FeedSize > 0 {
+		opts = append(opts, service.WithFeedSize(cfg.FeedSize))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, service.WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.RobotsFile != "" {
+		rules, err := os.ReadFile(cfg.RobotsFile)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, service.WithRobots(string(rules)))
+	}
+	if cfg.TokenKey != "" {
+		opts = append(opts, service.WithTokenKey([]byte(cfg.TokenKey)))
+	}
+	if cfg.TokenTTL > 0 {
+		opts = append(opts, service.WithTokenTTL(cfg.TokenTTL))
+	}
+
+	var (
+		posts    repository.PostStore
+		comments repository.CommentStore
+	)
+	switch cfg.Storage {
+	case "", MemoryStorage:
+		posts, comments = repository.NewPostRepository(), repository.NewCommentRepository()
+	case FileStorage:
+		store, err := repository.OpenFileStore(cfg.DataDir, cfg.CompactEvery)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		posts, comments = store.Posts(), store.Comments()
+		opts = append(opts, service.WithRevisions(store.Revisions()), service.WithUsers(store.Users()), service.WithApiKeys(store.ApiKeys()))
+	default:
+		return fmt.Errorf("unknown storage backend: %q", cfg.Storage)
+	}
+
+	posts, comments, index, err := search.Wrap(posts, comments)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, service.WithSearch(index))
+	api := service.NewRestApiServiceWithStores(posts, comments, opts...)
+	if cfg.AdminUsername != "" {
+		if err := api.SeedAdmin(cfg.AdminUsername, cfg.AdminPassword); err != nil {
+			return err
+		}
+	}
+	return api.ServeContent(cfg.Port)
 }
@@ -0,0 +1,167 @@
+// Package diff computes line-level differences between two texts.
+package diff
+
+import "strings"
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// maxEdits bounds the work spent on a minimal diff. Texts that differ in
+// more lines are diffed as a removal of every differing line followed by
+// the insertion of the new ones.
+const maxEdits = 1000
+
+// Line is a line of either text. OldNumber and NewNumber are the 1-based
+// numbers of the line in the old and the new text, zero for the text the
+// line does not occur in.
+type Line struct {
+	Op        Op     `json:"op"`
+	Text      string `json:"text"`
+	OldNumber int    `json:"oldLine,omitempty"`
+	NewNumber int    `json:"newLine,omitempty"`
+}
+
+// Lines returns the edit script turning old into new, line by line. Lines
+// that did not change are included as Equal lines.
+func Lines(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]Op, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, edits(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, Equal)
+	}
+
+	lines := make([]Line, 0, len(ops))
+	i, j := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			lines = append(lines, Line{Op: Equal, Text: a[i], OldNumber: i + 1, NewNumber: j + 1})
+			i++
+			j++
+		case Delete:
+			lines = append(lines, Line{Op: Delete, Text: a[i], OldNumber: i + 1})
+			i++
+		case Insert:
+			lines = append(lines, Line{Op: Insert, Text: b[j], NewNumber: j + 1})
+			j++
+		}
+	}
+	return lines
+}
+
+// edits finds a shortest edit script with the Myers algorithm, keeping the
+// frontier of every step to trace the script back. Deletions come before
+// insertions wherever both orders are possible.
+func edits(a, b []string) []Op {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(n, m)
+	}
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+	found := false
+	for d := 0; d <= max && d <= maxEdits && !found; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return replaceAll(n, m)
+	}
+
+	var reversed []Op
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d] holds the frontier before step d for diagonals -d..d.
+		frontier := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || k != d && frontier(k-1) < frontier(k+1) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := frontier(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Equal)
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, Insert)
+		} else {
+			reversed = append(reversed, Delete)
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, Equal)
+		x--
+		y--
+	}
+
+	ops := make([]Op, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+func replaceAll(n, m int) []Op {
+	ops := make([]Op, 0, n+m)
+	for i := 0; i < n; i++ {
+		ops = append(ops, Delete)
+	}
+	for i := 0; i < m; i++ {
+		ops = append(ops, Insert)
+	}
+	return ops
+}
+
+// splitLines splits text into lines without their terminators. A trailing
+// line break does not start another line.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,93 @@
+package diff
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected []Line
+	}{
+		{name: "identical", old: "a\nb", new: "a\nb", expected: []Line{
+			{Op: Equal, Text: "a", OldNumber: 1, NewNumber: 1},
+			{Op: Equal, Text: "b", OldNumber: 2, NewNumber: 2},
+		}},
+		{name: "both empty", old: "", new: "", expected: []Line{}},
+		{name: "from empty", old: "", new: "a\n", expected: []Line{
+			{Op: Insert, Text: "a", NewNumber: 1},
+		}},
+		{name: "to empty", old: "a\r\nb\r\n", new: "", expected: []Line{
+			{Op: Delete, Text: "a", OldNumber: 1},
+			{Op: Delete, Text: "b", OldNumber: 2},
+		}},
+		{name: "changed line", old: "a\nb\nc", new: "a\nB\nc", expected: []Line{
+			{Op: Equal, Text: "a", OldNumber: 1, NewNumber: 1},
+			{Op: Delete, Text: "b", OldNumber: 2},
+			{Op: Insert, Text: "B", NewNumber: 2},
+			{Op: Equal, Text: "c", OldNumber: 3, NewNumber: 3},
+		}},
+		{name: "moved lines", old: "a\nb\nc\na\nb\nb\na", new: "c\nb\na\nb\na\nc", expected: []Line{
+			{Op: Delete, Text: "a", OldNumber: 1},
+			{Op: Delete, Text: "b", OldNumber: 2},
+			{Op: Equal, Text: "c", OldNumber: 3, NewNumber: 1},
+			{Op: Insert, Text: "b", NewNumber: 2},
+			{Op: Equal, Text: "a", OldNumber: 4, NewNumber: 3},
+			{Op: Equal, Text: "b", OldNumber: 5, NewNumber: 4},
+			{Op: Delete, Text: "b", OldNumber: 6},
+			{Op: Equal, Text: "a", OldNumber: 7, NewNumber: 5},
+			{Op: Insert, Text: "c", NewNumber: 6},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Lines(tt.old, tt.new))
+		})
+	}
+}
+
+func TestLines_ReconstructsBothTexts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomText := func(lines int) string {
+		text := make([]string, lines)
+		for i := range text {
+			text[i] = strconv.Itoa(rnd.Intn(5))
+		}
+		return strings.Join(text, "\n")
+	}
+	for i := 0; i < 200; i++ {
+		old, new := randomText(rnd.Intn(30)), randomText(rnd.Intn(30))
+		var gotOld, gotNew []string
+		for _, line := range Lines(old, new) {
+			if line.Op != Insert {
+				gotOld = append(gotOld, line.Text)
+			}
+			if line.Op != Delete {
+				gotNew = append(gotNew, line.Text)
+			}
+		}
+		assert.Equal(t, old, strings.Join(gotOld, "\n"))
+		assert.Equal(t, new, strings.Join(gotNew, "\n"))
+	}
+}
+
+func TestLines_BeyondMaxEdits(t *testing.T) {
+	old := make([]string, maxEdits+1)
+	new := make([]string, maxEdits+1)
+	for i := range old {
+		old[i] = "old " + strconv.Itoa(i)
+		new[i] = "new " + strconv.Itoa(i)
+	}
+	lines := Lines("same\n"+strings.Join(old, "\n"), "same\n"+strings.Join(new, "\n"))
+	assert.Len(t, lines, 2*len(old)+1)
+	assert.Equal(t, Line{Op: Equal, Text: "same", OldNumber: 1, NewNumber: 1}, lines[0])
+	assert.Equal(t, Delete, lines[len(old)].Op)
+	assert.Equal(t, Insert, lines[len(old)+1].Op)
+}
@@ -1,14 +1,32 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"os"
+	"time"
 
 	"bitbucket.org/mindera/go-rest-blog/bootstrap"
+	"bitbucket.org/mindera/go-rest-blog/repository"
 )
 
 func main() {
-	defaultPort := 8080
-	if err := bootstrap.Init(defaultPort); err != nil {
+	var cfg bootstrap.Config
+	flag.IntVar(&cfg.Port, "port", 8080, "port the REST api listens on")
+	flag.StringVar(&cfg.Storage, "storage", bootstrap.MemoryStorage, "persistence backend: memory or file")
+	flag.StringVar(&cfg.DataDir, "data-dir", "data", "directory used by the file storage backend")
+	flag.IntVar(&cfg.CompactEvery, "compact-every", repository.DefaultCompactEvery, "log records written before the file storage backend takes a snapshot")
+	flag.BoolVar(&cfg.LegacyErrors, "legacy-errors", false, "answer errors with the legacy AckJsonResponse body instead of problem+json")
+	flag.IntVar(&cfg.FeedSize, "feed-size", 20, "number of posts or comments served in the RSS and Atom feeds")
+	flag.StringVar(&cfg.BaseURL, "base-url", "", "absolute URL the service is reachable at, used for links in feeds; defaults to the host of each request")
+	flag.StringVar(&cfg.RobotsFile, "robots-file", "", "file with the rules served from /robots.txt; every crawler is allowed everywhere by default")
+	flag.StringVar(&cfg.TokenKey, "token-key", os.Getenv("TOKEN_KEY"), "key bearer tokens are signed with, $TOKEN_KEY by default; tokens do not survive restarts without one")
+	flag.DurationVar(&cfg.TokenTTL, "token-ttl", 24*time.Hour, "how long issued bearer tokens stay valid")
+	flag.StringVar(&cfg.AdminUsername, "admin-username", os.Getenv("ADMIN_USERNAME"), "username of the admin created or promoted on startup, $ADMIN_USERNAME by default; no admin is seeded without one")
+	flag.StringVar(&cfg.AdminPassword, "admin-password", os.Getenv("ADMIN_PASSWORD"), "password of the seeded admin, $ADMIN_PASSWORD by default")
+	flag.Parse()
+
+	if err := bootstrap.Init(cfg); err != nil {
 		log.Fatalf("Service will be shutdown because error ocurred:  %+v", err.Error())
 	}
 }
@@ -0,0 +1,349 @@
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	autolink      = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.-]{1,31}:[^<>\s]*)>`)
+	emailAutolink = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_{|}~-]+@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*)>`)
+	inlineTag     = regexp.MustCompile(`^</?[A-Za-z][A-Za-z0-9-]*(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'=<>` + "`" + `]+))?)*\s*/?>`)
+)
+
+// renderInline renders the inline elements of a paragraph or heading.
+func renderInline(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && isPunct(text[i+1]):
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			out.WriteString("<br>\n")
+			i += 2
+		case c == '\n':
+			if strings.HasSuffix(out.String(), "  ") {
+				trimmed := strings.TrimRight(out.String(), " ")
+				out.Reset()
+				out.WriteString(trimmed + "<br>")
+			}
+			out.WriteByte('\n')
+			i++
+		case c == '`':
+			i += renderCodeSpan(&out, text[i:])
+		case c == '!' && strings.HasPrefix(text[i:], "!["):
+			if n := renderLink(&out, text[i+1:], true); n > 0 {
+				i += 1 + n
+			} else {
+				out.WriteByte('!')
+				i++
+			}
+		case c == '[':
+			if n := renderLink(&out, text[i:], false); n > 0 {
+				i += n
+			} else {
+				out.WriteByte('[')
+				i++
+			}
+		case c == '<':
+			i += renderAngle(&out, text[i:])
+		case c == '*' || c == '_' || c == '~':
+			i += renderEmphasis(&out, text, i)
+		case c == '&':
+			if n := entityLength(text[i:]); n > 0 {
+				out.WriteString(text[i : i+n])
+				i += n
+			} else {
+				out.WriteString("&amp;")
+				i++
+			}
+		default:
+			out.WriteString(html.EscapeString(text[i : i+1]))
+			i++
+		}
+	}
+	return out.String()
+}
+
+// renderCodeSpan renders the code span s starts with and returns its length.
+// A backtick run without a closing run of the same length is literal text.
+func renderCodeSpan(out *strings.Builder, s string) int {
+	run := countRun(s, 0)
+	for j := run; j < len(s); {
+		if s[j] != '`' {
+			j++
+			continue
+		}
+		closing := countRun(s, j)
+		if closing == run {
+			code := strings.ReplaceAll(s[run:j], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			out.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return j + closing
+		}
+		j += closing
+	}
+	out.WriteString(s[:run])
+	return run
+}
+
+// renderLink renders the inline link or image s starts with, s starting at
+// the opening bracket, and returns its length, zero when s does not start
+// with one.
+func renderLink(out *strings.Builder, s string, image bool) int {
+	end := matchingBracket(s)
+	if end < 0 || end+1 >= len(s) || s[end+1] != '(' {
+		return 0
+	}
+	label := s[1:end]
+	destination, title, n := parseLinkTarget(s[end+2:])
+	if n == 0 {
+		return 0
+	}
+	if image {
+		out.WriteString(`<img src="` + html.EscapeString(destination) + `" alt="` + html.EscapeString(plainText(label)) + `"`)
+		if title != "" {
+			out.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		out.WriteString(">")
+	} else {
+		out.WriteString(`<a href="` + html.EscapeString(destination) + `"`)
+		if title != "" {
+			out.WriteString(` title="` + html.EscapeString(title) + `"`)
+		}
+		out.WriteString(">" + renderInline(label) + "</a>")
+	}
+	return end + 2 + n
+}
+
+// matchingBracket returns the index of the bracket closing the one s starts
+// with, skipping escaped brackets and code spans.
+func matchingBracket(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			run := countRun(s, i)
+			if closing := strings.Index(s[i+run:], s[i:i+run]); closing >= 0 {
+				i += run + closing + run - 1
+			} else {
+				i += run - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseLinkTarget parses `destination "title")` and returns the destination,
+// the title and the length up to and including the closing parenthesis.
+func parseLinkTarget(s string) (string, string, int) {
+	i := skipSpaces(s, 0)
+	var destination string
+	if i < len(s) && s[i] == '<' {
+		end := strings.IndexAny(s[i+1:], ">\n")
+		if end < 0 || s[i+1+end] != '>' {
+			return "", "", 0
+		}
+		destination = s[i+1 : i+1+end]
+		i += end + 2
+	} else {
+		start, depth := i, 0
+		for ; i < len(s) && s[i] > ' '; i++ {
+			if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+				i++
+			} else if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		destination = s[start:i]
+	}
+	var title string
+	if j := skipSpaces(s, i); j > i && j < len(s) && strings.IndexByte(`"'(`, s[j]) >= 0 {
+		closer := s[j]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := strings.IndexByte(s[j+1:], closer)
+		if end < 0 {
+			return "", "", 0
+		}
+		title = s[j+1 : j+1+end]
+		i = j + end + 2
+	}
+	i = skipSpaces(s, i)
+	if i >= len(s) || s[i] != ')' {
+		return "", "", 0
+	}
+	return html.UnescapeString(unescapeBackslashes(destination)), html.UnescapeString(unescapeBackslashes(title)), i + 1
+}
+
+// renderAngle renders the autolink or raw HTML tag s starts with, or a
+// literal "<" otherwise, and returns the length consumed.
+func renderAngle(out *strings.Builder, s string) int {
+	if m := autolink.FindStringSubmatch(s); m != nil {
+		out.WriteString(`<a href="` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+		return len(m[0])
+	}
+	if m := emailAutolink.FindStringSubmatch(s); m != nil {
+		out.WriteString(`<a href="mailto:` + html.EscapeString(m[1]) + `">` + html.EscapeString(m[1]) + "</a>")
+		return len(m[0])
+	}
+	if m := inlineTag.FindString(s); m != "" {
+		out.WriteString(m)
+		return len(m)
+	}
+	out.WriteString("&lt;")
+	return 1
+}
+
+// renderEmphasis renders the emphasis, strong emphasis or strikethrough
+// opening at text[i] and returns the length consumed. A delimiter run that
+// cannot open, or is never closed, is literal text. Underscores do not open
+// or close emphasis within words.
+func renderEmphasis(out *strings.Builder, text string, i int) int {
+	c := text[i]
+	run := countRun(text, i)
+	if !canOpen(text, i, run) {
+		out.WriteString(text[i : i+run])
+		return run
+	}
+	var length int
+	var tags []string
+	switch {
+	case c == '~' && run == 2:
+		length, tags = 2, []string{"del"}
+	case c == '~':
+		out.WriteString(text[i : i+run])
+		return run
+	case run >= 3:
+		length, tags = 3, []string{"em", "strong"}
+	case run == 2:
+		length, tags = 2, []string{"strong"}
+	default:
+		length, tags = 1, []string{"em"}
+	}
+	delimiter := strings.Repeat(string(c), length)
+	for j := i + run; j < len(text); {
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			var discard strings.Builder
+			j += renderCodeSpan(&discard, text[j:])
+			continue
+		case c:
+			closing := countRun(text, j)
+			if closing >= length && canClose(text, j, closing) && j > i+run {
+				inner := text[i+length : j]
+				for _, tag := range tags {
+					out.WriteString("<" + tag + ">")
+				}
+				out.WriteString(renderInline(inner))
+				for k := len(tags) - 1; k >= 0; k-- {
+					out.WriteString("</" + tags[k] + ">")
+				}
+				return j + len(delimiter) - i
+			}
+			j += closing
+			continue
+		}
+		j++
+	}
+	out.WriteString(text[i : i+run])
+	return run
+}
+
+// canOpen reports whether the delimiter run of the given length at text[i]
+// may open emphasis: it must be followed by a non-space character and, for
+// underscores, must not follow a letter or digit.
+func canOpen(text string, i, run int) bool {
+	next, _ := utf8.DecodeRuneInString(text[i+run:])
+	if i+run >= len(text) || unicode.IsSpace(next) {
+		return false
+	}
+	if text[i] == '_' && i > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+	}
+	return true
+}
+
+func canClose(text string, j, run int) bool {
+	prev, _ := utf8.DecodeLastRuneInString(text[:j])
+	if j == 0 || unicode.IsSpace(prev) {
+		return false
+	}
+	if text[j] == '_' && j+run < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[j+run:])
+		return !unicode.IsLetter(next) && !unicode.IsDigit(next)
+	}
+	return true
+}
+
+// plainText strips the markup from the label of an image for its alt text.
+func plainText(label string) string {
+	var out strings.Builder
+	for i := 0; i < len(label); i++ {
+		switch c := label[i]; {
+		case c == '\\' && i+1 < len(label) && isPunct(label[i+1]):
+			out.WriteByte(label[i+1])
+			i++
+		case c == '*' || c == '_' || c == '`' || c == '~' || c == '[' || c == ']':
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+func unescapeBackslashes(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isPunct(s[i+1]) {
+			i++
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+func countRun(s string, i int) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\n') {
+		i++
+	}
+	return i
+}
+
+func isPunct(c byte) bool {
+	return c < 0x80 && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
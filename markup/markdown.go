@@ -0,0 +1,280 @@
+package markup
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	atxHeading    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	thematicBreak = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	setextLine    = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceOpen     = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	listMarker    = regexp.MustCompile(`^( {0,3})([-*+]|(\d{1,9})[.)])( {1,4}|[ \t]*$)`)
+	htmlBlockOpen = regexp.MustCompile(`^ {0,3}</?[A-Za-z][A-Za-z0-9-]*(?:[ \t>/]|$)`)
+)
+
+// Markdown renders the CommonMark subset used by posts as HTML: ATX and
+// setext headings, paragraphs, block quotes, ordered and unordered lists,
+// fenced and indented code blocks, thematic breaks, emphasis, strikethrough,
+// code spans, inline links, images, autolinks and hard line breaks. Raw HTML
+// is passed through, so the result must be sanitized before it is served.
+func Markdown(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\t", "    ")
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(src, "\n"), false)
+	return out.String()
+}
+
+// renderBlocks renders the lines as a sequence of blocks. Paragraphs of tight
+// list items are written without their <p> element.
+func renderBlocks(out *strings.Builder, lines []string, tight bool) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case isBlank(line):
+			i++
+		case fenceOpen.MatchString(line):
+			i = renderFencedCode(out, lines, i)
+		case indentation(line) >= 4:
+			i = renderIndentedCode(out, lines, i)
+		case atxHeading.MatchString(line):
+			m := atxHeading.FindStringSubmatch(line)
+			writeHeading(out, len(m[1]), m[2])
+			i++
+		case thematicBreak.MatchString(line):
+			out.WriteString("<hr>\n")
+			i++
+		case isBlockQuote(line):
+			i = renderBlockQuote(out, lines, i)
+		case listMarker.MatchString(line):
+			i = renderList(out, lines, i)
+		case htmlBlockOpen.MatchString(line):
+			for ; i < len(lines) && !isBlank(lines[i]); i++ {
+				out.WriteString(lines[i] + "\n")
+			}
+		default:
+			i = renderParagraph(out, lines, i, tight)
+		}
+	}
+}
+
+func renderFencedCode(out *strings.Builder, lines []string, i int) int {
+	m := fenceOpen.FindStringSubmatch(lines[i])
+	indent, fence := len(m[1]), m[2]
+	out.WriteString("<pre><code")
+	if fields := strings.Fields(m[3]); len(fields) > 0 {
+		out.WriteString(` class="language-` + html.EscapeString(unescapeBackslashes(fields[0])) + `"`)
+	}
+	out.WriteString(">")
+	for i++; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if len(lines[i])-len(trimmed) < 4 && strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" ") == "" {
+			i++
+			break
+		}
+		out.WriteString(html.EscapeString(trimIndent(lines[i], indent)) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return i
+}
+
+func renderIndentedCode(out *strings.Builder, lines []string, i int) int {
+	end := i
+	for j := i; j < len(lines); j++ {
+		if isBlank(lines[j]) {
+			continue
+		}
+		if indentation(lines[j]) < 4 {
+			break
+		}
+		end = j + 1
+	}
+	out.WriteString("<pre><code>")
+	for ; i < end; i++ {
+		out.WriteString(html.EscapeString(trimIndent(lines[i], 4)) + "\n")
+	}
+	out.WriteString("</code></pre>\n")
+	return end
+}
+
+func writeHeading(out *strings.Builder, level int, text string) {
+	tag := "h" + strconv.Itoa(level)
+	out.WriteString("<" + tag + ">" + renderInline(strings.TrimSpace(text)) + "</" + tag + ">\n")
+}
+
+func isBlockQuote(line string) bool {
+	return indentation(line) < 4 && strings.HasPrefix(strings.TrimLeft(line, " "), ">")
+}
+
+// renderBlockQuote renders the block quote starting at line i. Lines without
+// the quote marker continue the quote as long as they continue a paragraph.
+func renderBlockQuote(out *strings.Builder, lines []string, i int) int {
+	var inner []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlockQuote(line) {
+			line = strings.TrimLeft(line, " ")[1:]
+			if strings.HasPrefix(line, " ") {
+				line = line[1:]
+			}
+			inner = append(inner, line)
+			continue
+		}
+		if isBlank(line) || len(inner) == 0 || isBlank(inner[len(inner)-1]) || startsBlock(line) {
+			break
+		}
+		inner = append(inner, line)
+	}
+	out.WriteString("<blockquote>\n")
+	renderBlocks(out, inner, false)
+	out.WriteString("</blockquote>\n")
+	return i
+}
+
+type listItem struct {
+	lines []string
+}
+
+// renderList renders the list starting at line i. Items continue on the
+// lines indented at least as deep as their content; a list separated by blank
+// lines is loose and keeps the paragraphs of its items.
+func renderList(out *strings.Builder, lines []string, i int) int {
+	first := listMarker.FindStringSubmatch(lines[i])
+	ordered := first[3] != ""
+	delimiter := first[2][len(first[2])-1:]
+
+	var (
+		items      []listItem
+		loose      bool
+		blankAfter bool
+	)
+	for i < len(lines) {
+		m := listMarker.FindStringSubmatch(lines[i])
+		if m == nil || (m[3] != "") != ordered || m[2][len(m[2])-1:] != delimiter {
+			break
+		}
+		if blankAfter {
+			loose = true
+		}
+		contentIndent := len(m[0])
+		if isBlank(lines[i][len(m[0]):]) {
+			contentIndent = len(m[1]) + len(m[2]) + 1
+		}
+		item := listItem{lines: []string{strings.TrimRight(lines[i][len(m[0]):], " ")}}
+		i++
+		blankAfter = false
+		for i < len(lines) {
+			line := lines[i]
+			if isBlank(line) {
+				blankAfter = true
+				item.lines = append(item.lines, "")
+				i++
+				continue
+			}
+			if indentation(line) >= contentIndent {
+				if blankAfter {
+					loose = true
+				}
+				blankAfter = false
+				item.lines = append(item.lines, line[contentIndent:])
+				i++
+				continue
+			}
+			// a lazy continuation of the paragraph the item ends with
+			if !blankAfter && !startsBlock(line) && !listMarker.MatchString(line) {
+				item.lines = append(item.lines, line)
+				i++
+				continue
+			}
+			break
+		}
+		for len(item.lines) > 0 && isBlank(item.lines[len(item.lines)-1]) {
+			item.lines = item.lines[:len(item.lines)-1]
+		}
+		items = append(items, item)
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	out.WriteString("<" + tag)
+	if ordered {
+		if start, _ := strconv.Atoi(first[3]); start != 1 {
+			out.WriteString(` start="` + strconv.Itoa(start) + `"`)
+		}
+	}
+	out.WriteString(">\n")
+	for _, item := range items {
+		out.WriteString("<li>")
+		var content strings.Builder
+		renderBlocks(&content, item.lines, !loose)
+		out.WriteString(strings.TrimSuffix(content.String(), "\n"))
+		out.WriteString("</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i
+}
+
+// renderParagraph renders the paragraph starting at line i, which becomes a
+// setext heading when it is underlined.
+func renderParagraph(out *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlank(line) {
+			break
+		}
+		if len(text) > 0 {
+			if m := setextLine.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				writeHeading(out, level, strings.Join(text, "\n"))
+				return i + 1
+			}
+			if startsBlock(line) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+	content := renderInline(strings.TrimRight(strings.Join(text, "\n"), " "))
+	if tight {
+		out.WriteString(content + "\n")
+		return i
+	}
+	out.WriteString("<p>" + content + "</p>\n")
+	return i
+}
+
+// startsBlock reports whether the line interrupts a paragraph. Only bullet
+// lists and ordered lists starting at 1 do, so that numbers starting a line
+// of text are not mistaken for a list.
+func startsBlock(line string) bool {
+	if m := listMarker.FindStringSubmatch(line); m != nil && !isBlank(line[len(m[0]):]) {
+		return m[3] == "" || m[3] == "1"
+	}
+	return fenceOpen.MatchString(line) || atxHeading.MatchString(line) || thematicBreak.MatchString(line) ||
+		isBlockQuote(line) || htmlBlockOpen.MatchString(line)
+}
+
+func isBlank(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+func trimIndent(line string, n int) string {
+	if indent := indentation(line); indent < n {
+		n = indent
+	}
+	return line[n:]
+}
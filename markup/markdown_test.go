@@ -0,0 +1,50 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{name: "paragraphs", src: "first\nline\n\nsecond", expected: "<p>first\nline</p>\n<p>second</p>\n"},
+		{name: "hard line break", src: "first  \nsecond\\\nthird", expected: "<p>first<br>\nsecond<br>\nthird</p>\n"},
+		{name: "atx headings", src: "# one\n### three ###\n####### seven", expected: "<h1>one</h1>\n<h3>three</h3>\n<p>####### seven</p>\n"},
+		{name: "setext headings", src: "one\n===\ntwo\n---", expected: "<h1>one</h1>\n<h2>two</h2>\n"},
+		{name: "thematic break", src: "* * *\n\n___", expected: "<hr>\n<hr>\n"},
+		{name: "emphasis", src: "*em* _em_ **strong** __strong__ ***both*** ~~del~~",
+			expected: "<p><em>em</em> <em>em</em> <strong>strong</strong> <strong>strong</strong> <em><strong>both</strong></em> <del>del</del></p>\n"},
+		{name: "no emphasis within words with underscores", src: "snake_case_name and 2 * 3", expected: "<p>snake_case_name and 2 * 3</p>\n"},
+		{name: "unclosed emphasis", src: "**open and *half", expected: "<p>**open and *half</p>\n"},
+		{name: "code span", src: "use `a <b> & *c*` and `` x`y ``", expected: "<p>use <code>a &lt;b&gt; &amp; *c*</code> and <code>x`y</code></p>\n"},
+		{name: "backslash escapes", src: `\*not em\* \[x\]`, expected: "<p>*not em* [x]</p>\n"},
+		{name: "html is escaped in text", src: "a < b & c > d &amp;", expected: "<p>a &lt; b &amp; c &gt; d &amp;</p>\n"},
+		{name: "link", src: `[the *site*](https://example.com/a_(b) "Title")`,
+			expected: "<p><a href=\"https://example.com/a_(b)\" title=\"Title\">the <em>site</em></a></p>\n"},
+		{name: "link with angle destination", src: "[x](<a b.html>)", expected: "<p><a href=\"a b.html\">x</a></p>\n"},
+		{name: "not a link", src: "[x] (y) [z]", expected: "<p>[x] (y) [z]</p>\n"},
+		{name: "image", src: `![a *b*](/i.png 'T')`, expected: "<p><img src=\"/i.png\" alt=\"a b\" title=\"T\"></p>\n"},
+		{name: "autolinks", src: "<https://example.com> <me@example.com>",
+			expected: "<p><a href=\"https://example.com\">https://example.com</a> <a href=\"mailto:me@example.com\">me@example.com</a></p>\n"},
+		{name: "fenced code", src: "```go\nif a < b {\n```\nafter", expected: "<pre><code class=\"language-go\">if a &lt; b {\n</code></pre>\n<p>after</p>\n"},
+		{name: "unclosed fence", src: "~~~\ncode", expected: "<pre><code>code\n</code></pre>\n"},
+		{name: "indented code", src: "    a\n\n    b\nc", expected: "<pre><code>a\n\nb\n</code></pre>\n<p>c</p>\n"},
+		{name: "block quote", src: "> a\nlazy\n> > b", expected: "<blockquote>\n<p>a\nlazy</p>\n<blockquote>\n<p>b</p>\n</blockquote>\n</blockquote>\n"},
+		{name: "tight list", src: "- a\n- b\n  more\n+ c", expected: "<ul>\n<li>a</li>\n<li>b\nmore</li>\n</ul>\n<ul>\n<li>c</li>\n</ul>\n"},
+		{name: "loose list", src: "1. a\n\n2. b", expected: "<ol>\n<li><p>a</p></li>\n<li><p>b</p></li>\n</ol>\n"},
+		{name: "ordered list start", src: "3) a\n4) b", expected: "<ol start=\"3\">\n<li>a</li>\n<li>b</li>\n</ol>\n"},
+		{name: "nested list", src: "- a\n  - b", expected: "<ul>\n<li>a\n<ul>\n<li>b</li>\n</ul></li>\n</ul>\n"},
+		{name: "numbers do not interrupt paragraphs", src: "in\n1986. it", expected: "<p>in\n1986. it</p>\n"},
+		{name: "raw html", src: "<div>\n<b>x</b>\n</div>\n\n<i>y</i> z", expected: "<div>\n<b>x</b>\n</div>\n<i>y</i> z\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Markdown(tt.src))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package markup renders the content of posts as sanitized HTML.
+package markup
+
+import (
+	"container/list"
+	"hash/fnv"
+	"html"
+	"strings"
+	"sync"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// Render returns the content written in the given format as sanitized HTML.
+// Plain text is escaped, its blank-line separated paragraphs wrapped in <p>
+// and its line breaks kept.
+func Render(format, content string) string {
+	if format == model.FormatMarkdown {
+		return Sanitize(Markdown(content))
+	}
+	var out strings.Builder
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	for _, paragraph := range strings.Split(content, "\n\n") {
+		if paragraph = strings.Trim(paragraph, "\n"); strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return out.String()
+}
+
+// Cache keeps the rendered content of the most recently rendered posts. An
+// entry is only served for the format and content it was rendered from, so
+// a stale entry is never served even when Invalidate is missed; Invalidate
+// frees it early.
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[uint64]*list.Element
+	// recent orders the entries from the most to the least recently used.
+	recent *list.List
+}
+
+type cacheEntry struct {
+	postId uint64
+	format string
+	hash   uint64
+	html   string
+}
+
+// NewCache returns a cache holding up to size posts.
+func NewCache(size int) *Cache {
+	return &Cache{size: size, entries: make(map[uint64]*list.Element), recent: list.New()}
+}
+
+// Render returns the rendered content of the post, rendering it on a miss.
+func (c *Cache) Render(post model.Post) string {
+	format, hash := post.ContentFormat(), contentHash(post.Content)
+
+	c.mu.Lock()
+	if e, ok := c.entries[post.Id]; ok {
+		entry := e.Value.(*cacheEntry)
+		if entry.format == format && entry.hash == hash {
+			c.recent.MoveToFront(e)
+			c.mu.Unlock()
+			return entry.html
+		}
+	}
+	c.mu.Unlock()
+
+	rendered := Render(format, post.Content)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(post.Id)
+	c.entries[post.Id] = c.recent.PushFront(&cacheEntry{postId: post.Id, format: format, hash: hash, html: rendered})
+	for c.recent.Len() > c.size {
+		c.remove(c.recent.Back().Value.(*cacheEntry).postId)
+	}
+	return rendered
+}
+
+// Invalidate drops the rendered content of the post.
+func (c *Cache) Invalidate(postId uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(postId)
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.recent.Len()
+}
+
+func (c *Cache) remove(postId uint64) {
+	if e, ok := c.entries[postId]; ok {
+		c.recent.Remove(e)
+		delete(c.entries, postId)
+	}
+}
+
+func contentHash(content string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	return h.Sum64()
+}
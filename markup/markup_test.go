@@ -0,0 +1,53 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestRender(t *testing.T) {
+	assert.Equal(t, "<p>a &lt;b&gt;<br>\nc</p>\n<p>*d*</p>\n", Render(model.FormatPlain, "a <b>\r\nc\n\n\n*d*\n"))
+	assert.Equal(t, "<p>a &lt;b&gt;</p>\n", Render("", "a <b>"))
+	assert.Equal(t, "<p><em>a</em> </p>\n", Render(model.FormatMarkdown, "*a* <script>alert(1)</script>"))
+	assert.Equal(t, "<p><a rel=\"nofollow noopener\">x</a></p>\n", Render(model.FormatMarkdown, "[x](javascript:alert(1))"))
+}
+
+func TestCache(t *testing.T) {
+	// GIVEN
+	c := NewCache(2)
+	post1 := model.Post{Id: 1, Content: "*a*", Format: model.FormatMarkdown}
+	post2 := model.Post{Id: 2, Content: "b"}
+	post3 := model.Post{Id: 3, Content: "c"}
+
+	// WHEN
+	rendered := c.Render(post1)
+
+	// THEN
+	assert.Equal(t, "<p><em>a</em></p>\n", rendered)
+	assert.Equal(t, rendered, c.Render(post1))
+	assert.Equal(t, 1, c.Len())
+
+	// changed content or format is rendered again
+	post1.Format = model.FormatPlain
+	assert.Equal(t, "<p>*a*</p>\n", c.Render(post1))
+	post1.Content = "*b*"
+	assert.Equal(t, "<p>*b*</p>\n", c.Render(post1))
+	assert.Equal(t, 1, c.Len())
+
+	// the least recently used post is evicted
+	c.Render(post2)
+	c.Render(post1)
+	c.Render(post3)
+	assert.Equal(t, 2, c.Len())
+	c.mu.Lock()
+	_, cached2 := c.entries[post2.Id]
+	c.mu.Unlock()
+	assert.False(t, cached2)
+
+	c.Invalidate(post1.Id)
+	c.Invalidate(post2.Id)
+	assert.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,350 @@
+package markup
+
+import (
+	"html"
+	"strings"
+)
+
+// allowedTags maps the elements kept by Sanitize to the attributes kept on
+// them. Any other element is dropped while its text is kept, except for the
+// elements in droppedTags whose content goes with them.
+var allowedTags = map[string]map[string]bool{
+	"a":          {"href": true, "title": true},
+	"abbr":       {"title": true},
+	"b":          {},
+	"blockquote": {},
+	"br":         {},
+	"code":       {"class": true},
+	"del":        {},
+	"em":         {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src": true, "alt": true, "title": true},
+	"li":         {},
+	"ol":         {"start": true},
+	"p":          {},
+	"pre":        {},
+	"s":          {},
+	"strong":     {},
+	"sub":        {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"align": true},
+	"th":         {"align": true},
+	"thead":      {},
+	"tr":         {},
+	"ul":         {},
+}
+
+var droppedTags = map[string]bool{
+	"embed":    true,
+	"iframe":   true,
+	"math":     true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+	"textarea": true,
+	"title":    true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+var urlAttributes = map[string]bool{"href": true, "src": true}
+
+// safeSchemes are the URL schemes links may use. Relative URLs are always
+// allowed.
+var safeSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Sanitize keeps the elements and attributes of the allowlist above and drops
+// everything else, including event handlers, inline styles, comments and URLs
+// with schemes other than http, https and mailto. The result is well formed:
+// every element it opens is closed.
+func Sanitize(src string) string {
+	var (
+		out  strings.Builder
+		open []string
+		// dropping is the element whose content is being dropped, if any.
+		dropping string
+	)
+	for i := 0; i < len(src); {
+		if src[i] != '<' {
+			next := strings.IndexByte(src[i:], '<')
+			if next < 0 {
+				next = len(src) - i
+			}
+			if dropping == "" {
+				out.WriteString(escapeText(src[i : i+next]))
+			}
+			i += next
+			continue
+		}
+		if strings.HasPrefix(src[i:], "<!--") {
+			end := strings.Index(src[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+		t, n := parseTag(src[i:])
+		if n == 0 {
+			if dropping == "" {
+				out.WriteString("&lt;")
+			}
+			i++
+			continue
+		}
+		i += n
+		if dropping != "" {
+			if t.closing && t.name == dropping {
+				dropping = ""
+			}
+			continue
+		}
+		if droppedTags[t.name] {
+			if !t.closing && !t.selfClosing {
+				dropping = t.name
+			}
+			continue
+		}
+		attributes, allowed := allowedTags[t.name]
+		if !allowed {
+			continue
+		}
+		if t.closing {
+			for j := len(open) - 1; j >= 0; j-- {
+				if open[j] == t.name {
+					for k := len(open) - 1; k >= j; k-- {
+						out.WriteString("</" + open[k] + ">")
+					}
+					open = open[:j]
+					break
+				}
+			}
+			continue
+		}
+		out.WriteString("<" + t.name)
+		for _, attr := range t.attrs {
+			if !attributes[attr.name] || !safeAttribute(t.name, attr.name, attr.value) {
+				continue
+			}
+			out.WriteString(" " + attr.name + `="` + html.EscapeString(attr.value) + `"`)
+		}
+		if t.name == "a" {
+			out.WriteString(` rel="nofollow noopener"`)
+		}
+		out.WriteString(">")
+		if !voidTags[t.name] {
+			open = append(open, t.name)
+		}
+	}
+	for j := len(open) - 1; j >= 0; j-- {
+		out.WriteString("</" + open[j] + ">")
+	}
+	return out.String()
+}
+
+func safeAttribute(tag, name, value string) bool {
+	switch {
+	case urlAttributes[name]:
+		return safeURL(value)
+	case tag == "code" && name == "class":
+		return strings.HasPrefix(value, "language-") && !strings.ContainsAny(value, " \t\n")
+	case name == "align":
+		return value == "left" || value == "center" || value == "right"
+	case name == "start":
+		return strings.Trim(value, "0123456789") == "" && value != ""
+	}
+	return true
+}
+
+// safeURL reports whether the URL is relative or uses one of the safe
+// schemes. Browsers ignore control characters and whitespace within schemes,
+// so they are ignored here as well.
+func safeURL(value string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value)
+	colon := strings.IndexByte(cleaned, ':')
+	if colon < 0 || strings.ContainsAny(cleaned[:colon], "/?#") {
+		return true
+	}
+	return safeSchemes[strings.ToLower(cleaned[:colon])]
+}
+
+// escapeText escapes the text between tags, leaving well-formed character
+// references as they are.
+func escapeText(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '&':
+			if n := entityLength(text[i:]); n > 0 {
+				out.WriteString(text[i : i+n])
+				i += n - 1
+			} else {
+				out.WriteString("&amp;")
+			}
+		case '<':
+			out.WriteString("&lt;")
+		case '>':
+			out.WriteString("&gt;")
+		case '"':
+			out.WriteString("&#34;")
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// entityLength returns the length of the character reference s starts with,
+// zero when it does not start with one.
+func entityLength(s string) int {
+	i := 1
+	if i < len(s) && s[i] == '#' {
+		i++
+		hex := i < len(s) && (s[i] == 'x' || s[i] == 'X')
+		if hex {
+			i++
+		}
+		start := i
+		for i < len(s) && i-start < 8 && (isDigit(s[i]) || hex && isHexLetter(s[i])) {
+			i++
+		}
+		if i == start {
+			return 0
+		}
+	} else {
+		start := i
+		for i < len(s) && i-start < 32 && isAlnum(s[i]) {
+			i++
+		}
+		if i == start || i >= len(s) || s[i] != ';' || html.UnescapeString(s[:i+1]) == s[:i+1] {
+			return 0
+		}
+	}
+	if i < len(s) && s[i] == ';' {
+		return i + 1
+	}
+	return 0
+}
+
+type tag struct {
+	name        string
+	closing     bool
+	selfClosing bool
+	attrs       []attribute
+}
+
+type attribute struct {
+	name  string
+	value string
+}
+
+// parseTag parses the start or end tag s starts with and returns its length,
+// zero when s does not start with a tag.
+func parseTag(s string) (tag, int) {
+	var t tag
+	i := 1
+	if i < len(s) && s[i] == '/' {
+		t.closing = true
+		i++
+	}
+	start := i
+	for i < len(s) && (isAlnum(s[i]) || i > start && s[i] == '-') {
+		i++
+	}
+	if i == start || !isLetter(s[start]) {
+		return tag{}, 0
+	}
+	t.name = strings.ToLower(s[start:i])
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return tag{}, 0
+		}
+		switch s[i] {
+		case '>':
+			return t, i + 1
+		case '/':
+			if i+1 < len(s) && s[i+1] == '>' {
+				t.selfClosing = true
+				return t, i + 2
+			}
+			i++
+			continue
+		}
+		nameStart := i
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		attr := attribute{name: strings.ToLower(s[nameStart:i])}
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isSpace(s[i]) {
+				i++
+			}
+			if i >= len(s) {
+				return tag{}, 0
+			}
+			if quote := s[i]; quote == '"' || quote == '\'' {
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					return tag{}, 0
+				}
+				attr.value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(s) && !isSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				attr.value = s[valueStart:i]
+			}
+			attr.value = html.UnescapeString(attr.value)
+		}
+		if attr.name != "" {
+			t.attrs = append(t.attrs, attr)
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHexLetter(c byte) bool {
+	return 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isAlnum(c byte) bool {
+	return isLetter(c) || isDigit(c)
+}
@@ -0,0 +1,45 @@
+package markup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{name: "allowed markup is kept", src: `<p>a <em>b</em> <code class="language-go">c</code></p>`,
+			expected: `<p>a <em>b</em> <code class="language-go">c</code></p>`},
+		{name: "scripts are dropped with their content", src: `a<script>alert("x")</script>b<SCRIPT src=x></SCRIPT>c`, expected: "abc"},
+		{name: "styles and iframes are dropped", src: `<style>p{}</style><iframe src="x">y</iframe>z`, expected: "z"},
+		{name: "unknown elements keep their text", src: `<div class="x"><span>text</span></div>`, expected: "text"},
+		{name: "event handlers are dropped", src: `<img src="a.png" onerror="alert(1)" alt="a"><p onclick=x>p</p>`,
+			expected: `<img src="a.png" alt="a"><p>p</p>`},
+		{name: "style attributes are dropped", src: `<p style="color:red" class="x">p</p>`, expected: `<p>p</p>`},
+		{name: "safe links", src: `<a href="https://x.org/?a=1&amp;b=2" title='t'>x</a><a href="/rel">r</a><a href="mailto:a@b.c">m</a>`,
+			expected: `<a href="https://x.org/?a=1&amp;b=2" title="t" rel="nofollow noopener">x</a><a href="/rel" rel="nofollow noopener">r</a>` +
+				`<a href="mailto:a@b.c" rel="nofollow noopener">m</a>`},
+		{name: "javascript urls", src: `<a href="javascript:alert(1)">a</a><a href=" JaVaScRiPt:x">b</a><a href="jav&#x09;ascript:x">c</a>`,
+			expected: `<a rel="nofollow noopener">a</a><a rel="nofollow noopener">b</a><a rel="nofollow noopener">c</a>`},
+		{name: "data and vbscript urls", src: `<img src="data:image/svg+xml,x"><a href="vbscript:x">v</a>`,
+			expected: `<img><a rel="nofollow noopener">v</a>`},
+		{name: "colons after the path are relative", src: `<a href="/a:b">x</a>`, expected: `<a href="/a:b" rel="nofollow noopener">x</a>`},
+		{name: "unsafe code classes", src: `<code class="x language-go">c</code>`, expected: `<code>c</code>`},
+		{name: "comments are dropped", src: `a<!-- <script>x</script> -->b<!-- open`, expected: "ab"},
+		{name: "unclosed elements are closed", src: `<ul><li><strong>x`, expected: `<ul><li><strong>x</strong></li></ul>`},
+		{name: "stray end tags are dropped", src: `</p>x</em>`, expected: "x"},
+		{name: "misnested elements", src: `<em><strong>x</em>y</strong>`, expected: `<em><strong>x</strong></em>y`},
+		{name: "text is escaped", src: `a < b > c & "d" &amp; &#60; &bogus;`, expected: `a &lt; b &gt; c &amp; &#34;d&#34; &amp; &#60; &amp;bogus;`},
+		{name: "broken tags are text", src: `<a href="x`, expected: `&lt;a href=&#34;x`},
+		{name: "attribute values are escaped", src: `<img alt="&quot;><script>" src=x>`, expected: `<img alt="&#34;&gt;&lt;script&gt;" src="x">`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Sanitize(tt.src))
+		})
+	}
+}
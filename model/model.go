@@ -2,17 +2,175 @@ package model
 
 import "time"
 
+// DeletedCommentPlaceholder replaces the text and author of a deleted comment.
+const DeletedCommentPlaceholder = "[deleted]"
+
+// Post statuses. Only published posts are visible to anonymous readers.
+const (
+	StatusDraft     = "draft"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// User roles, from the least to the most privileged. What each role may do
+// is decided by the policy of the service.
+const (
+	RoleReader    = "reader"
+	RoleAuthor    = "author"
+	RoleModerator = "moderator"
+	RoleEditor    = "editor"
+	RoleAdmin     = "admin"
+)
+
+// Content formats of posts.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
 type Comment struct {
-	Id           uint64
-	PostId       uint64
-	Comment      string
-	Author       string
+	Id     uint64
+	PostId uint64
+	// ParentId is the id of the comment this one replies to, zero for top-level comments.
+	ParentId uint64 `json:",omitempty"`
+	Comment  string
+	Author   string
+	// AuthorId is the id of the user who wrote the comment, zero for comments
+	// written before users existed.
+	AuthorId     uint64 `json:",omitempty"`
 	CreationDate time.Time
+	EditedAt     *time.Time `json:",omitempty"`
+	Deleted      bool       `json:",omitempty"`
 }
 
 type Post struct {
-	Id           uint64
-	Title        string
-	Content      string
+	Id    uint64
+	Title string
+	// Slug identifies the post in URLs. It is generated from Title by the
+	// repository and changes when the title does.
+	Slug    string `json:",omitempty"`
+	Content string
+	// Format is how Content is written, FormatPlain when empty.
+	Format       string `json:",omitempty"`
 	CreationDate time.Time
+	EditedAt     *time.Time `json:",omitempty"`
+	Tags         []string   `json:",omitempty"`
+	Category     string     `json:",omitempty"`
+	Status       string     `json:",omitempty"`
+	// PublishAt is when a scheduled post gets published.
+	PublishAt *time.Time `json:",omitempty"`
+	// OwnerId is the id of the user who created the post, zero for posts
+	// created before users existed.
+	OwnerId uint64 `json:",omitempty"`
+}
+
+// PublicationStatus returns the status of the post, counting posts stored
+// without one as published.
+func (p Post) PublicationStatus() string {
+	if p.Status == "" {
+		return StatusPublished
+	}
+	return p.Status
+}
+
+func (p Post) Published() bool {
+	return p.PublicationStatus() == StatusPublished
+}
+
+// PublicationDate returns when the post was or will be published: the time
+// it was scheduled for if it has one, otherwise its creation.
+func (p Post) PublicationDate() time.Time {
+	if p.PublishAt != nil {
+		return *p.PublishAt
+	}
+	return p.CreationDate
+}
+
+// Due reports whether the post is scheduled to be published at or before now.
+func (p Post) Due(now time.Time) bool {
+	return p.Status == StatusScheduled && p.PublishAt != nil && !p.PublishAt.After(now)
+}
+
+// ContentFormat returns the format of the content, counting posts stored
+// without one as plain text.
+func (p Post) ContentFormat() string {
+	if p.Format == "" {
+		return FormatPlain
+	}
+	return p.Format
+}
+
+// Revision is an immutable copy of a post as it was after one of its changes.
+// Revisions of a post are numbered from 1 in the order they were made.
+type Revision struct {
+	PostId    uint64
+	Number    int
+	Author    string
+	CreatedAt time.Time
+	Title     string
+	Content   string
+	Format    string   `json:",omitempty"`
+	Tags      []string `json:",omitempty"`
+	Category  string   `json:",omitempty"`
+	Status    string   `json:",omitempty"`
+}
+
+// User is an account callers authenticate as. Usernames are unique ignoring
+// case.
+type User struct {
+	Id       uint64
+	Username string
+	// PasswordHash is the bcrypt hash of the password; it is never served.
+	PasswordHash string
+	// Role is one of the Role constants, RoleReader when empty.
+	Role         string `json:",omitempty"`
+	CreationDate time.Time
+}
+
+// UserRole returns the role of the user, counting users stored without one
+// as readers.
+func (u User) UserRole() string {
+	if u.Role == "" {
+		return RoleReader
+	}
+	return u.Role
+}
+
+// Scopes of API keys, each granting a kind of request. What the owner of the
+// key may do is still decided by its role.
+const (
+	ScopePostsRead     = "posts:read"
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsRead  = "comments:read"
+	ScopeCommentsWrite = "comments:write"
+)
+
+// ApiKey lets machine clients act as the user owning it, limited to its
+// scopes. Only a hash of the key is kept.
+type ApiKey struct {
+	Id     uint64
+	UserId uint64
+	Name   string
+	// Prefix is the start of the key, shown to tell keys apart.
+	Prefix string
+	// Hash is the hex encoded SHA-256 hash of the key.
+	Hash         string
+	Scopes       []string
+	CreationDate time.Time
+	LastUsedAt   *time.Time `json:",omitempty"`
+	RevokedAt    *time.Time `json:",omitempty"`
+}
+
+func (k ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k ApiKey) Revoked() bool {
+	return k.RevokedAt != nil
 }
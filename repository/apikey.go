@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// ApiKeyRepository keeps API keys in memory, indexed by id and by the hash
+// of the key.
+type ApiKeyRepository struct {
+	mu     sync.RWMutex
+	keys   map[uint64]model.ApiKey
+	byHash map[string]uint64
+	lastId uint64
+}
+
+func NewApiKeyRepository() *ApiKeyRepository {
+	return &ApiKeyRepository{keys: make(map[uint64]model.ApiKey), byHash: make(map[string]uint64)}
+}
+
+type ApiKeyNotFoundError struct {
+	id uint64
+}
+
+func (e ApiKeyNotFoundError) Error() string {
+	if e.id == 0 {
+		return "Error: API key was not found in the repository!"
+	}
+	return fmt.Sprintf("Error: API key with id: %v was not found in the repository!", e.id)
+}
+
+func (e ApiKeyNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+type ApiKeyAlreadyExistsError struct{}
+
+func (e ApiKeyAlreadyExistsError) Error() string {
+	return "Error: API key already exists in the repository!"
+}
+
+func (e ApiKeyAlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// Create stores a new key under the next free id, stamping CreationDate with
+// the current time when it is unset.
+func (c *ApiKeyRepository) Create(key model.ApiKey) (model.ApiKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, err := c.allocate(key)
+	if err != nil {
+		return key, err
+	}
+	c.put(key)
+	return key, nil
+}
+
+// allocate fills in the id and creation date Create stores the key with.
+// Callers must hold the lock.
+func (c *ApiKeyRepository) allocate(key model.ApiKey) (model.ApiKey, error) {
+	if _, taken := c.byHash[key.Hash]; taken {
+		return key, ApiKeyAlreadyExistsError{}
+	}
+	key.Id = c.lastId + 1
+	if key.CreationDate.IsZero() {
+		key.CreationDate = time.Now().UTC()
+	}
+	return key, nil
+}
+
+// prepareCreate returns the key as Create would store it, without storing
+// it.
+func (c *ApiKeyRepository) prepareCreate(key model.ApiKey) (model.ApiKey, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.allocate(key)
+}
+
+func (c *ApiKeyRepository) GetById(id uint64) (*model.ApiKey, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	key, ok := c.keys[id]
+	if !ok {
+		return nil, ApiKeyNotFoundError{id}
+	}
+	return &key, nil
+}
+
+// GetByHash finds the key with the given hash, revoked or not.
+func (c *ApiKeyRepository) GetByHash(hash string) (*model.ApiKey, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.byHash[hash]
+	if !ok {
+		return nil, ApiKeyNotFoundError{}
+	}
+	key := c.keys[id]
+	return &key, nil
+}
+
+// GetAllByUserId returns the keys of the user ordered by id.
+func (c *ApiKeyRepository) GetAllByUserId(userId uint64) []model.ApiKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []model.ApiKey
+	for _, key := range c.keys {
+		if key.UserId == userId {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys
+}
+
+// Update replaces the stored key with the same id. The hash of a key never
+// changes and a revoked key stays revoked.
+func (c *ApiKeyRepository) Update(key model.ApiKey) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	existing, ok := c.keys[key.Id]
+	if !ok {
+		return ApiKeyNotFoundError{key.Id}
+	}
+	c.put(keepImmutable(key, existing))
+	return nil
+}
+
+// Touch records that the key with the id was last used at the given time,
+// leaving the rest of the key as it is.
+func (c *ApiKeyRepository) Touch(id uint64, at time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key, ok := c.keys[id]
+	if !ok {
+		return ApiKeyNotFoundError{id}
+	}
+	key.LastUsedAt = &at
+	c.keys[id] = key
+	return nil
+}
+
+// keepImmutable returns the key with the fields of the existing key that
+// updates cannot change.
+func keepImmutable(key, existing model.ApiKey) model.ApiKey {
+	key.Hash = existing.Hash
+	if existing.RevokedAt != nil {
+		key.RevokedAt = existing.RevokedAt
+	}
+	return key
+}
+
+// put stores the key, replacing a key stored under the same id. Callers must
+// hold the write lock.
+func (c *ApiKeyRepository) put(key model.ApiKey) {
+	if old, ok := c.keys[key.Id]; ok {
+		delete(c.byHash, old.Hash)
+	}
+	c.keys[key.Id] = key
+	c.byHash[key.Hash] = key.Id
+	if key.Id > c.lastId {
+		c.lastId = key.Id
+	}
+}
+
+// all returns every key ordered by id.
+func (c *ApiKeyRepository) all() []model.ApiKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	keys := make([]model.ApiKey, 0, len(c.keys))
+	for _, key := range c.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id < keys[j].Id })
+	return keys
+}
+
+func (c *ApiKeyRepository) upsert(key model.ApiKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(key)
+}
+
+func (c *ApiKeyRepository) reserveUpTo(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id > c.lastId {
+		c.lastId = id
+	}
+}
+
+func (c *ApiKeyRepository) lastAllocatedId() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastId
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestApiKeyRepository(t *testing.T) {
+	createdAt := time.Unix(10011, 0).UTC()
+
+	// GIVEN
+	c := NewApiKeyRepository()
+
+	// WHEN
+	key1, err := c.Create(model.ApiKey{UserId: 1, Name: "ci", Hash: "hash1", Scopes: []string{model.ScopePostsWrite}, CreationDate: createdAt})
+	require.NoError(t, err)
+	key2, err := c.Create(model.ApiKey{UserId: 2, Name: "importer", Hash: "hash2"})
+	require.NoError(t, err)
+	key3, err := c.Create(model.ApiKey{UserId: 1, Name: "reader", Hash: "hash3"})
+	require.NoError(t, err)
+
+	// THEN
+	assert.Equal(t, uint64(1), key1.Id)
+	assert.Equal(t, createdAt, key1.CreationDate)
+	assert.False(t, key2.CreationDate.IsZero())
+	assert.Equal(t, []model.ApiKey{key1, key3}, c.GetAllByUserId(1))
+	assert.Empty(t, c.GetAllByUserId(3))
+
+	got, err := c.GetByHash("hash2")
+	require.NoError(t, err)
+	assert.Equal(t, &key2, got)
+	_, err = c.GetByHash("hash4")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.GetById(4)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.Create(model.ApiKey{UserId: 3, Name: "duplicate", Hash: "hash1"})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+
+	// the hash of a key cannot be changed
+	revokedAt := createdAt.Add(time.Hour)
+	key1.RevokedAt, key1.Hash = &revokedAt, "changed"
+	require.NoError(t, c.Update(key1))
+	got, err = c.GetByHash("hash1")
+	require.NoError(t, err)
+	assert.True(t, got.Revoked())
+	assert.ErrorIs(t, c.Update(model.ApiKey{Id: 4}), ErrNotFound)
+
+	// recording the use of a revoked key, or writing back a copy read before
+	// it was revoked, keeps it revoked
+	usedAt := revokedAt.Add(time.Hour)
+	stale := key1
+	stale.RevokedAt = nil
+	require.NoError(t, c.Update(stale))
+	require.NoError(t, c.Touch(key1.Id, usedAt))
+	got, err = c.GetById(key1.Id)
+	require.NoError(t, err)
+	assert.True(t, got.Revoked())
+	assert.Equal(t, &usedAt, got.LastUsedAt)
+	assert.ErrorIs(t, c.Touch(4, usedAt), ErrNotFound)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+var benchmarkSizes = []int{1000, 10000, 100000}
+
+const benchmarkCommentsPerPost = 10
+
+func seededPostRepository(size int) *PostRepository {
+	p := NewPostRepository()
+	for id := 1; id <= size; id++ {
+		_ = p.Insert(model.Post{Id: uint64(id), Title: "title", Content: "content", CreationDate: time.Unix(int64(id), 0)})
+	}
+	return p
+}
+
+func seededCommentRepository(size int) *CommentRepository {
+	c := NewCommentRepository()
+	for id := 1; id <= size; id++ {
+		postId := uint64(id/benchmarkCommentsPerPost + 1)
+		_ = c.Insert(model.Comment{Id: uint64(id), PostId: postId, Comment: "comment", Author: "author", CreationDate: time.Unix(int64(id), 0)})
+	}
+	return c
+}
+
+func BenchmarkPostRepository_GetById(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			p := seededPostRepository(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.GetById(uint64(i%size + 1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPostRepository_Insert(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			p := seededPostRepository(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.Insert(model.Post{Id: uint64(size + i + 1), Title: "title"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCommentRepository_GetById(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			c := seededCommentRepository(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := c.GetById(uint64(i%size + 1)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCommentRepository_GetAllByPostId(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			c := seededCommentRepository(size)
+			posts := size / benchmarkCommentsPerPost
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if len(c.GetAllByPostId(uint64(i%posts+1))) == 0 {
+					b.Fatal("expected comments")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCommentRepository_Insert(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			c := seededCommentRepository(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Insert(model.Comment{Id: uint64(size + i + 1), PostId: 1, Comment: "comment"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+const (
+	stressWorkers    = 16
+	stressIterations = 200
+)
+
+func TestPostRepository_ConcurrentAccess(t *testing.T) {
+	t.Run("parallel inserts of distinct posts", func(t *testing.T) {
+		p := NewPostRepository()
+		var wg sync.WaitGroup
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					id := uint64(w*stressIterations + i + 1)
+					assert.NoError(t, p.Insert(model.Post{Id: id, Title: "post", CreationDate: time.Unix(int64(id), 0)}))
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		for id := uint64(1); id <= stressWorkers*stressIterations; id++ {
+			_, err := p.GetById(id)
+			require.NoError(t, err)
+		}
+	})
+
+	t.Run("parallel inserts of the same post", func(t *testing.T) {
+		p := NewPostRepository()
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			successes int
+		)
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := p.Insert(model.Post{Id: 42, Title: "contended"}); err == nil {
+					mu.Lock()
+					successes++
+					mu.Unlock()
+				} else {
+					assert.ErrorIs(t, err, PostAlreadyExistsError{42})
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, successes)
+	})
+
+	t.Run("readers and writers interleaved", func(t *testing.T) {
+		p := NewPostRepository()
+		require.NoError(t, p.Insert(model.Post{Id: 1, Title: "seed"}))
+		var wg sync.WaitGroup
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(2)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					id := uint64(w*stressIterations + i + 2)
+					assert.NoError(t, p.Insert(model.Post{Id: id, Title: "post"}))
+					assert.NoError(t, p.Update(model.Post{Id: id, Title: "updated"}))
+					assert.NoError(t, p.Delete(id))
+				}
+			}(w)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					post, err := p.GetById(1)
+					assert.NoError(t, err)
+					assert.Equal(t, "seed", post.Title)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestCommentRepository_ConcurrentAccess(t *testing.T) {
+	t.Run("parallel inserts of distinct comments", func(t *testing.T) {
+		c := NewCommentRepository()
+		var wg sync.WaitGroup
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					id := uint64(w*stressIterations + i + 1)
+					assert.NoError(t, c.Insert(model.Comment{Id: id, PostId: uint64(w), Comment: "comment", Author: "author"}))
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		for w := 0; w < stressWorkers; w++ {
+			assert.Len(t, c.GetAllByPostId(uint64(w)), stressIterations)
+		}
+	})
+
+	t.Run("parallel inserts of the same comment", func(t *testing.T) {
+		c := NewCommentRepository()
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			successes int
+		)
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := c.Insert(model.Comment{Id: 42, PostId: 1}); err == nil {
+					mu.Lock()
+					successes++
+					mu.Unlock()
+				} else {
+					assert.ErrorIs(t, err, CommentAlreadyExistsError{42})
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, successes)
+		assert.Len(t, c.GetAllByPostId(1), 1)
+	})
+
+	t.Run("readers and writers interleaved", func(t *testing.T) {
+		c := NewCommentRepository()
+		var wg sync.WaitGroup
+		for w := 0; w < stressWorkers; w++ {
+			wg.Add(2)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					id := uint64(w*stressIterations + i + 1)
+					assert.NoError(t, c.Insert(model.Comment{Id: id, PostId: uint64(w), Comment: "comment"}))
+					assert.NoError(t, c.Update(model.Comment{Id: id, PostId: uint64(w), Comment: "edited"}))
+				}
+			}(w)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < stressIterations; i++ {
+					for _, comment := range c.GetAllByPostId(uint64(w)) {
+						got, err := c.GetById(comment.Id)
+						assert.NoError(t, err)
+						assert.Equal(t, comment.PostId, got.PostId)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		for w := 0; w < stressWorkers; w++ {
+			for _, comment := range c.GetAllByPostId(uint64(w)) {
+				assert.Equal(t, "edited", comment.Comment)
+			}
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package repository
+
+import "errors"
+
+// Sentinel errors matched by the repository error types through errors.Is,
+// so callers can tell failures apart without knowing the concrete type.
+var (
+	ErrNotFound      = errors.New("entity not found")
+	ErrAlreadyExists = errors.New("entity already exists")
+)
+
+// Kinds of entities reported by the Kind method of the repository errors.
+const (
+	PostKind    = "post"
+	CommentKind = "comment"
+)
+
+// EntityError is implemented by the errors that concern a single post or
+// comment; use errors.As to find out which entity a failure is about.
+type EntityError interface {
+	error
+	ID() uint64
+	Kind() string
+}
+
+var (
+	_ EntityError = PostNotFoundError{}
+	_ EntityError = PostAlreadyExistsError{}
+	_ EntityError = CommentNotFoundError{}
+	_ EntityError = CommentAlreadyExistsError{}
+)
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestEntityErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		sentinel error
+		other    error
+		kind     string
+	}{
+		{name: "post not found", err: PostNotFoundError{7}, sentinel: ErrNotFound, other: ErrAlreadyExists, kind: PostKind},
+		{name: "post already exists", err: PostAlreadyExistsError{7}, sentinel: ErrAlreadyExists, other: ErrNotFound, kind: PostKind},
+		{name: "comment not found", err: CommentNotFoundError{7}, sentinel: ErrNotFound, other: ErrAlreadyExists, kind: CommentKind},
+		{name: "comment already exists", err: CommentAlreadyExistsError{7}, sentinel: ErrAlreadyExists, other: ErrNotFound, kind: CommentKind},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("handling request: %w", tt.err)
+
+			assert.ErrorIs(t, wrapped, tt.sentinel)
+			assert.ErrorIs(t, wrapped, tt.err)
+			assert.False(t, errors.Is(wrapped, tt.other))
+
+			var entityErr EntityError
+			require.True(t, errors.As(wrapped, &entityErr))
+			assert.Equal(t, uint64(7), entityErr.ID())
+			assert.Equal(t, tt.kind, entityErr.Kind())
+		})
+	}
+}
+
+func TestEntityErrors_ReturnedByRepositories(t *testing.T) {
+	posts := NewPostRepository()
+	_, err := posts.GetById(5)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	comments := NewCommentRepository()
+	_, err = comments.Create(model.Comment{Id: 9, PostId: 1})
+	require.NoError(t, err)
+	err = comments.Insert(model.Comment{Id: 9, PostId: 1})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	var entityErr EntityError
+	require.True(t, errors.As(err, &entityErr))
+	assert.Equal(t, uint64(9), entityErr.ID())
+	assert.Equal(t, CommentKind, entityErr.Kind())
+}
@@ -0,0 +1,643 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+const (
+	DefaultCompactEvery = 1000
+
+	snapshotFileName = "snapshot.json"
+	logFileName      = "wal.log"
+)
+
+const (
+	opPutPost       = "put-post"
+	opDeletePost    = "delete-post"
+	opPutComment    = "put-comment"
+	opDeleteComment = "delete-comment"
+	// opDeletePostComments removes all comments of the post with the record's id.
+	opDeletePostComments = "delete-post-comments"
+	opPutRevision        = "put-revision"
+	// opDeletePostRevisions removes all revisions of the post with the record's id.
+	opDeletePostRevisions = "delete-post-revisions"
+	opPutUser             = "put-user"
+	opPutApiKey           = "put-api-key"
+)
+
+// FileStore persists posts, comments, users and API keys on disk. Every write
+// is appended to a write-ahead log before it becomes visible: ids and slugs
+// are worked out first, the record is logged and only then stored in memory,
+// so a failed append leaves no trace. The log is periodically compacted into a
+// snapshot. Both are replayed into in-memory repositories
+// when the store is opened.
+type FileStore struct {
+	mu           sync.Mutex
+	dir          string
+	log          *os.File
+	pending      int
+	compactEvery int
+	posts        *PostRepository
+	comments     *CommentRepository
+	revisions    *RevisionRepository
+	users        *UserRepository
+	apiKeys      *ApiKeyRepository
+}
+
+type snapshot struct {
+	Posts         []model.Post    `json:"posts"`
+	Comments      []model.Comment `json:"comments"`
+	LastPostId    uint64          `json:"lastPostId"`
+	LastCommentId uint64          `json:"lastCommentId"`
+	// Redirects maps the former slugs of posts to their ids.
+	Redirects map[string]uint64 `json:"redirects,omitempty"`
+	// SlugSuffixes holds the next numeric suffix of every slug base, so that
+	// suffixes of deleted posts are not handed out again.
+	SlugSuffixes map[string]int   `json:"slugSuffixes,omitempty"`
+	Revisions    []model.Revision `json:"revisions,omitempty"`
+	Users        []model.User     `json:"users,omitempty"`
+	LastUserId   uint64           `json:"lastUserId,omitempty"`
+	ApiKeys      []model.ApiKey   `json:"apiKeys,omitempty"`
+	LastApiKeyId uint64           `json:"lastApiKeyId,omitempty"`
+}
+
+type logRecord struct {
+	Op       string          `json:"op"`
+	Id       uint64          `json:"id,omitempty"`
+	Post     *model.Post     `json:"post,omitempty"`
+	Comment  *model.Comment  `json:"comment,omitempty"`
+	Revision *model.Revision `json:"revision,omitempty"`
+	User     *model.User     `json:"user,omitempty"`
+	ApiKey   *model.ApiKey   `json:"apiKey,omitempty"`
+}
+
+type CorruptLogError struct {
+	offset int64
+}
+
+func (e CorruptLogError) Error() string {
+	return fmt.Sprintf("Error: write-ahead log is corrupt at offset: %v!", e.offset)
+}
+
+func OpenFileStore(dir string, compactEvery int) (*FileStore, error) {
+	if compactEvery <= 0 {
+		compactEvery = DefaultCompactEvery
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{
+		dir:          dir,
+		compactEvery: compactEvery,
+		posts:        NewPostRepository(),
+		comments:     NewCommentRepository(),
+		revisions:    NewRevisionRepository(),
+		users:        NewUserRepository(),
+		apiKeys:      NewApiKeyRepository(),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) Posts() PostStore {
+	return filePostStore{PostRepository: s.posts, store: s}
+}
+
+func (s *FileStore) Comments() CommentStore {
+	return fileCommentStore{CommentRepository: s.comments, store: s}
+}
+
+func (s *FileStore) Revisions() RevisionStore {
+	return fileRevisionStore{RevisionRepository: s.revisions, store: s}
+}
+
+func (s *FileStore) Users() UserStore {
+	return fileUserStore{UserRepository: s.users, store: s}
+}
+
+func (s *FileStore) ApiKeys() ApiKeyStore {
+	return fileApiKeyStore{ApiKeyRepository: s.apiKeys, store: s}
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
+
+// Compact writes the current state to a snapshot and truncates the log.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+func (s *FileStore) compact() error {
+	data, err := json.Marshal(snapshot{
+		Posts:         s.posts.all(),
+		Comments:      s.comments.all(),
+		LastPostId:    s.posts.lastAllocatedId(),
+		LastCommentId: s.comments.lastAllocatedId(),
+		Redirects:     s.posts.slugRedirects(),
+		SlugSuffixes:  s.posts.slugSuffixes(),
+		Revisions:     s.revisions.all(),
+		Users:         s.users.all(),
+		LastUserId:    s.users.lastAllocatedId(),
+		ApiKeys:       s.apiKeys.all(),
+		LastApiKeyId:  s.apiKeys.lastAllocatedId(),
+	})
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, snapshotFileName)
+	if err := writeFileSync(path+".tmp", data); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+	// A crash before the truncation only means the log is replayed on top of
+	// a snapshot that already contains it, which is harmless because every
+	// record is idempotent.
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.pending = 0
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+	for _, post := range snap.Posts {
+		s.posts.upsert(post)
+	}
+	s.posts.restoreRedirects(snap.Redirects)
+	s.posts.restoreSuffixes(snap.SlugSuffixes)
+	for _, comment := range snap.Comments {
+		s.comments.upsert(comment)
+	}
+	for _, revision := range snap.Revisions {
+		s.revisions.upsert(revision)
+	}
+	for _, user := range snap.Users {
+		s.users.upsert(user)
+	}
+	for _, key := range snap.ApiKeys {
+		s.apiKeys.upsert(key)
+	}
+	s.posts.reserveUpTo(snap.LastPostId)
+	s.comments.reserveUpTo(snap.LastCommentId)
+	s.users.reserveUpTo(snap.LastUserId)
+	s.apiKeys.reserveUpTo(snap.LastApiKeyId)
+	return nil
+}
+
+// replayLog applies every complete record of the log. A torn record at the
+// very end of the file is what a crash in the middle of an append leaves
+// behind, so it is cut off instead of failing the startup.
+func (s *FileStore) replayLog() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, logFileName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			f.Close()
+			return readErr
+		}
+		if len(line) == 0 {
+			break
+		}
+		rec, decodeErr := decodeLogRecord(line)
+		if readErr == io.EOF || decodeErr != nil {
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				f.Close()
+				return CorruptLogError{offset}
+			}
+			if err := f.Truncate(offset); err != nil {
+				f.Close()
+				return err
+			}
+			break
+		}
+		s.apply(rec)
+		s.pending++
+		offset += int64(len(line))
+	}
+	s.log = f
+	return nil
+}
+
+func (s *FileStore) apply(rec logRecord) {
+	switch rec.Op {
+	case opPutPost:
+		s.posts.upsert(*rec.Post)
+	case opDeletePost:
+		s.posts.discard(rec.Id)
+	case opPutComment:
+		s.comments.upsert(*rec.Comment)
+	case opDeleteComment:
+		s.comments.discard(rec.Id)
+	case opDeletePostComments:
+		s.comments.discardAllByPostId(rec.Id)
+	case opPutRevision:
+		s.revisions.upsert(*rec.Revision)
+	case opDeletePostRevisions:
+		s.revisions.discardAllByPostId(rec.Id)
+	case opPutUser:
+		s.users.upsert(*rec.User)
+	case opPutApiKey:
+		s.apiKeys.upsert(*rec.ApiKey)
+	}
+}
+
+// append durably writes the record to the log. Callers must hold s.mu and
+// call compactIfDue only once the record is applied in memory, because the
+// snapshot is taken from memory and replaces the log.
+func (s *FileStore) append(rec logRecord) error {
+	line, err := encodeLogRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(line); err != nil {
+		return err
+	}
+	if err := s.log.Sync(); err != nil {
+		return err
+	}
+	s.pending++
+	return nil
+}
+
+// compactIfDue compacts the log once it holds compactEvery records. Callers
+// must hold s.mu.
+func (s *FileStore) compactIfDue() {
+	if s.pending < s.compactEvery {
+		return
+	}
+	if err := s.compact(); err != nil {
+		log.Printf("compaction of %s failed, will retry: %v", s.dir, err)
+	}
+}
+
+// Records are stored one per line as "<crc32 hex> <json>\n".
+func encodeLogRecord(rec logRecord) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(payload), payload)), nil
+}
+
+func decodeLogRecord(line []byte) (logRecord, error) {
+	var rec logRecord
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	sep := bytes.IndexByte(line, ' ')
+	if sep < 0 {
+		return rec, errors.New("missing checksum")
+	}
+	sum, err := strconv.ParseUint(string(line[:sep]), 16, 32)
+	if err != nil {
+		return rec, err
+	}
+	payload := line[sep+1:]
+	if crc32.ChecksumIEEE(payload) != uint32(sum) {
+		return rec, errors.New("checksum mismatch")
+	}
+	err = json.Unmarshal(payload, &rec)
+	return rec, err
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+type filePostStore struct {
+	*PostRepository
+	store *FileStore
+}
+
+func (s filePostStore) Insert(post model.Post) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	stored, err := s.PostRepository.prepareInsert(post)
+	if err != nil {
+		return err
+	}
+	if err := s.store.append(logRecord{Op: opPutPost, Post: &stored}); err != nil {
+		return err
+	}
+	s.PostRepository.upsert(stored)
+	return nil
+}
+
+func (s filePostStore) Create(post model.Post) (model.Post, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	created, err := s.PostRepository.prepareCreate(post)
+	if err != nil {
+		return created, err
+	}
+	if err := s.store.append(logRecord{Op: opPutPost, Post: &created}); err != nil {
+		return created, err
+	}
+	s.PostRepository.upsert(created)
+	return created, nil
+}
+
+func (s filePostStore) Update(post model.Post) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	// The post is logged as stored, slug included, so that replaying the log
+	// reproduces the slug handed out now.
+	stored, err := s.PostRepository.prepareUpdate(post)
+	if err != nil {
+		return err
+	}
+	if err := s.store.append(logRecord{Op: opPutPost, Post: &stored}); err != nil {
+		return err
+	}
+	s.PostRepository.upsert(stored)
+	return nil
+}
+
+func (s filePostStore) PublishDue(id uint64, now time.Time) (model.Post, bool, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	post, err := s.PostRepository.GetById(id)
+	if err != nil {
+		return model.Post{}, false, err
+	}
+	if !post.Due(now) {
+		return *post, false, nil
+	}
+	post.Status = model.StatusPublished
+	if err := s.store.append(logRecord{Op: opPutPost, Post: post}); err != nil {
+		return model.Post{}, false, err
+	}
+	return s.PostRepository.PublishDue(id, now)
+}
+
+func (s filePostStore) Delete(id uint64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if _, err := s.PostRepository.GetById(id); err != nil {
+		return err
+	}
+	if err := s.store.append(logRecord{Op: opDeletePost, Id: id}); err != nil {
+		return err
+	}
+	return s.PostRepository.Delete(id)
+}
+
+type fileCommentStore struct {
+	*CommentRepository
+	store *FileStore
+}
+
+func (s fileCommentStore) Insert(comment model.Comment) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if _, err := s.CommentRepository.GetById(comment.Id); err == nil {
+		return CommentAlreadyExistsError{comment.Id}
+	}
+	if err := s.store.append(logRecord{Op: opPutComment, Comment: &comment}); err != nil {
+		return err
+	}
+	return s.CommentRepository.Insert(comment)
+}
+
+func (s fileCommentStore) Create(comment model.Comment) (model.Comment, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	created, err := s.CommentRepository.prepareCreate(comment)
+	if err != nil {
+		return created, err
+	}
+	if err := s.store.append(logRecord{Op: opPutComment, Comment: &created}); err != nil {
+		return created, err
+	}
+	s.CommentRepository.upsert(created)
+	return created, nil
+}
+
+func (s fileCommentStore) Update(comment model.Comment) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if _, err := s.CommentRepository.GetById(comment.Id); err != nil {
+		return err
+	}
+	if err := s.store.append(logRecord{Op: opPutComment, Comment: &comment}); err != nil {
+		return err
+	}
+	return s.CommentRepository.Update(comment)
+}
+
+func (s fileCommentStore) Delete(id uint64) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if _, err := s.CommentRepository.GetById(id); err != nil {
+		return err
+	}
+	if err := s.store.append(logRecord{Op: opDeleteComment, Id: id}); err != nil {
+		return err
+	}
+	return s.CommentRepository.Delete(id)
+}
+
+func (s fileCommentStore) DeleteAllByPostId(id uint64) (int, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if len(s.CommentRepository.GetAllByPostId(id)) == 0 {
+		return 0, nil
+	}
+	if err := s.store.append(logRecord{Op: opDeletePostComments, Id: id}); err != nil {
+		return 0, err
+	}
+	return s.CommentRepository.DeleteAllByPostId(id)
+}
+
+type fileRevisionStore struct {
+	*RevisionRepository
+	store *FileStore
+}
+
+func (s fileRevisionStore) Add(revision model.Revision) (model.Revision, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	added := s.RevisionRepository.prepareAdd(revision)
+	if err := s.store.append(logRecord{Op: opPutRevision, Revision: &added}); err != nil {
+		return added, err
+	}
+	s.RevisionRepository.upsert(added)
+	return added, nil
+}
+
+func (s fileRevisionStore) DeleteAllByPostId(id uint64) (int, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if len(s.RevisionRepository.GetAllByPostId(id)) == 0 {
+		return 0, nil
+	}
+	if err := s.store.append(logRecord{Op: opDeletePostRevisions, Id: id}); err != nil {
+		return 0, err
+	}
+	return s.RevisionRepository.DeleteAllByPostId(id)
+}
+
+type fileUserStore struct {
+	*UserRepository
+	store *FileStore
+}
+
+func (s fileUserStore) Create(user model.User) (model.User, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	created, err := s.UserRepository.prepareCreate(user)
+	if err != nil {
+		return created, err
+	}
+	if err := s.store.append(logRecord{Op: opPutUser, User: &created}); err != nil {
+		return created, err
+	}
+	s.UserRepository.upsert(created)
+	return created, nil
+}
+
+func (s fileUserStore) Update(user model.User) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	if _, err := s.UserRepository.GetById(user.Id); err != nil {
+		return err
+	}
+	if other, err := s.UserRepository.GetByUsername(user.Username); err == nil && other.Id != user.Id {
+		return UserAlreadyExistsError{user.Username}
+	}
+	if err := s.store.append(logRecord{Op: opPutUser, User: &user}); err != nil {
+		return err
+	}
+	return s.UserRepository.Update(user)
+}
+
+type fileApiKeyStore struct {
+	*ApiKeyRepository
+	store *FileStore
+}
+
+func (s fileApiKeyStore) Create(key model.ApiKey) (model.ApiKey, error) {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	created, err := s.ApiKeyRepository.prepareCreate(key)
+	if err != nil {
+		return created, err
+	}
+	if err := s.store.append(logRecord{Op: opPutApiKey, ApiKey: &created}); err != nil {
+		return created, err
+	}
+	s.ApiKeyRepository.upsert(created)
+	return created, nil
+}
+
+func (s fileApiKeyStore) Update(key model.ApiKey) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	existing, err := s.ApiKeyRepository.GetById(key.Id)
+	if err != nil {
+		return err
+	}
+	key = keepImmutable(key, *existing)
+	if err := s.store.append(logRecord{Op: opPutApiKey, ApiKey: &key}); err != nil {
+		return err
+	}
+	return s.ApiKeyRepository.Update(key)
+}
+
+func (s fileApiKeyStore) Touch(id uint64, at time.Time) error {
+	s.store.mu.Lock()
+	defer s.store.mu.Unlock()
+	defer s.store.compactIfDue()
+	key, err := s.ApiKeyRepository.GetById(id)
+	if err != nil {
+		return err
+	}
+	key.LastUsedAt = &at
+	if err := s.store.append(logRecord{Op: opPutApiKey, ApiKey: key}); err != nil {
+		return err
+	}
+	return s.ApiKeyRepository.Touch(id, at)
+}
@@ -0,0 +1,574 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func reopenFileStore(t *testing.T, s *FileStore, dir string) *FileStore {
+	t.Helper()
+	require.NoError(t, s.Close())
+	reopened, err := OpenFileStore(dir, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
+func TestFileStore_Replay(t *testing.T) {
+	var (
+		post1    = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0).UTC()}
+		post2    = model.Post{Id: 102, Title: "post2", Slug: "post2", Content: "content", CreationDate: time.Unix(10012, 0).UTC()}
+		updated  = model.Post{Id: 101, Title: "updated", Slug: "updated", Content: "new content", CreationDate: time.Unix(10011, 0).UTC()}
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "author1", CreationDate: time.Unix(10013, 0).UTC()}
+		comment2 = model.Comment{Id: 2, PostId: 101, Comment: "comment2", Author: "author2", CreationDate: time.Unix(10014, 0).UTC()}
+	)
+
+	t.Run("writes survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		require.NoError(t, s.Posts().Insert(post2))
+		require.NoError(t, s.Posts().Update(updated))
+		require.NoError(t, s.Posts().Delete(post2.Id))
+		require.NoError(t, s.Comments().Insert(comment1))
+		require.NoError(t, s.Comments().Insert(comment2))
+		require.NoError(t, s.Comments().Delete(comment1.Id))
+		require.NoError(t, s.Comments().Insert(model.Comment{Id: 3, PostId: post2.Id}))
+		removed, err := s.Comments().DeleteAllByPostId(post2.Id)
+		require.NoError(t, err)
+		require.Equal(t, 1, removed)
+
+		s = reopenFileStore(t, s, dir)
+
+		post, err := s.Posts().GetById(post1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &updated, post)
+		_, err = s.Posts().GetById(post2.Id)
+		assert.ErrorIs(t, err, PostNotFoundError{post2.Id})
+		assert.Equal(t, []model.Comment{comment2}, s.Comments().GetAllByPostId(post1.Id))
+		assert.Empty(t, s.Comments().GetAllByPostId(post2.Id))
+	})
+
+	t.Run("failed writes are not logged", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		assert.ErrorIs(t, s.Posts().Insert(updated), PostAlreadyExistsError{post1.Id})
+		assert.ErrorIs(t, s.Posts().Update(post2), PostNotFoundError{post2.Id})
+		assert.ErrorIs(t, s.Comments().Delete(comment1.Id), CommentNotFoundError{comment1.Id})
+
+		s = reopenFileStore(t, s, dir)
+
+		post, err := s.Posts().GetById(post1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &post1, post)
+		assert.Equal(t, 1, s.pending)
+	})
+}
+
+func TestFileStore_Compact(t *testing.T) {
+	var (
+		post1    = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0).UTC()}
+		post2    = model.Post{Id: 102, Title: "post2", Slug: "post2", Content: "content", CreationDate: time.Unix(10012, 0).UTC()}
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "author1", CreationDate: time.Unix(10013, 0).UTC()}
+	)
+
+	t.Run("explicit compaction", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		require.NoError(t, s.Comments().Insert(comment1))
+		require.NoError(t, s.Compact())
+
+		info, err := os.Stat(filepath.Join(dir, logFileName))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+
+		require.NoError(t, s.Posts().Insert(post2))
+		s = reopenFileStore(t, s, dir)
+
+		posts := s.posts.all()
+		assert.Equal(t, []model.Post{post1, post2}, posts)
+		assert.Equal(t, []model.Comment{comment1}, s.Comments().GetAllByPostId(post1.Id))
+	})
+
+	t.Run("compaction after threshold", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 2)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		require.NoError(t, s.Posts().Insert(post1))
+		assert.Equal(t, 1, s.pending)
+		require.NoError(t, s.Posts().Insert(post2))
+		assert.Equal(t, 0, s.pending)
+		_, err = os.Stat(filepath.Join(dir, snapshotFileName))
+		assert.NoError(t, err)
+	})
+
+	t.Run("log replayed on top of a snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		require.NoError(t, s.Posts().Delete(post1.Id))
+		logData, err := os.ReadFile(filepath.Join(dir, logFileName))
+		require.NoError(t, err)
+		require.NoError(t, s.Compact())
+		// simulate a crash between writing the snapshot and truncating the log
+		require.NoError(t, os.WriteFile(filepath.Join(dir, logFileName), logData, 0o644))
+
+		s = reopenFileStore(t, s, dir)
+		_, err = s.Posts().GetById(post1.Id)
+		assert.ErrorIs(t, err, PostNotFoundError{post1.Id})
+	})
+
+	t.Run("slug redirects survive compaction", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		renamed := post1
+		renamed.Title = "Renamed post"
+		require.NoError(t, s.Posts().Update(renamed))
+		require.NoError(t, s.Compact())
+
+		s = reopenFileStore(t, s, dir)
+		post, err := s.Posts().GetBySlug("post1")
+		require.NoError(t, err)
+		assert.Equal(t, "renamed-post", post.Slug)
+		// the former slug stays taken
+		created, err := s.Posts().Create(model.Post{Title: "post1", Content: "content"})
+		require.NoError(t, err)
+		assert.Equal(t, "post1-2", created.Slug)
+	})
+}
+
+func TestFileStore_PublishDue(t *testing.T) {
+	var (
+		now      = time.Unix(10011, 0).UTC()
+		past     = now.Add(-time.Minute)
+		post     = model.Post{Id: 101, Title: "due", Slug: "due", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now}
+		draft    = model.Post{Id: 102, Title: "draft", Slug: "draft", Status: model.StatusDraft, CreationDate: now}
+		expected = post
+	)
+	expected.Status = model.StatusPublished
+
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Posts().Insert(post))
+	require.NoError(t, s.Posts().Insert(draft))
+
+	published, ok, err := s.Posts().PublishDue(post.Id, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, expected, published)
+	_, ok, err = s.Posts().PublishDue(draft.Id, now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, _, err = s.Posts().PublishDue(103, now)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 3, s.pending)
+
+	s = reopenFileStore(t, s, dir)
+	assert.Equal(t, []model.Post{expected, draft}, s.posts.all())
+}
+
+func TestFileStore_Slugs(t *testing.T) {
+	for _, compact := range []bool{false, true} {
+		// GIVEN
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		create := func(title string) model.Post {
+			created, err := s.Posts().Create(model.Post{Title: title, Content: "content"})
+			require.NoError(t, err)
+			return created
+		}
+		create("Hello")
+		deleted := create("Hello")
+		renamed := create("Other")
+		require.NoError(t, s.Posts().Delete(deleted.Id))
+		renamed.Title = "Hello"
+		require.NoError(t, s.Posts().Update(renamed))
+		stored, err := s.Posts().GetById(renamed.Id)
+		require.NoError(t, err)
+		require.Equal(t, "hello-3", stored.Slug)
+		create("Bye")
+		last := create("Bye")
+		require.NoError(t, s.Posts().Delete(last.Id))
+		if compact {
+			require.NoError(t, s.Compact())
+		}
+		before := s.posts.all()
+
+		// WHEN
+		s = reopenFileStore(t, s, dir)
+
+		// THEN
+		assert.Equal(t, before, s.posts.all(), "compact: %v", compact)
+		post, err := s.Posts().GetBySlug("other")
+		require.NoError(t, err, "compact: %v", compact)
+		assert.Equal(t, renamed.Id, post.Id, "compact: %v", compact)
+		// suffixes of deleted posts are not handed out again
+		assert.Equal(t, "hello-4", create("Hello").Slug, "compact: %v", compact)
+		assert.Equal(t, "bye-3", create("Bye").Slug, "compact: %v", compact)
+	}
+}
+
+func TestFileStore_FailedAppend(t *testing.T) {
+	// GIVEN
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	first, err := s.Posts().Create(model.Post{Title: "Hello", Content: "content"})
+	require.NoError(t, err)
+	writable := s.log
+	readOnly, err := os.Open(filepath.Join(dir, logFileName))
+	require.NoError(t, err)
+	t.Cleanup(func() { readOnly.Close() })
+	s.log = readOnly
+
+	// WHEN
+	_, postErr := s.Posts().Create(model.Post{Title: "Hello", Content: "content"})
+	insertErr := s.Posts().Insert(model.Post{Id: 42, Title: "Inserted"})
+	_, commentErr := s.Comments().Create(model.Comment{PostId: first.Id, Comment: "comment"})
+	_, revisionErr := s.Revisions().Add(model.Revision{PostId: first.Id, Title: "Hello"})
+	_, userErr := s.Users().Create(model.User{Username: "alice"})
+	_, keyErr := s.ApiKeys().Create(model.ApiKey{UserId: 1, Hash: "hash"})
+
+	// THEN
+	for _, err := range []error{postErr, insertErr, commentErr, revisionErr, userErr, keyErr} {
+		assert.Error(t, err)
+	}
+	assert.Equal(t, []model.Post{first}, s.posts.all())
+	_, err = s.Posts().GetBySlug("hello-2")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, s.Comments().GetAllByPostId(first.Id))
+	assert.Empty(t, s.Revisions().GetAllByPostId(first.Id))
+	_, err = s.Users().GetByUsername("alice")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.ApiKeys().GetByHash("hash")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// nothing was used up by the failed writes
+	s.log = writable
+	post, err := s.Posts().Create(model.Post{Title: "Hello", Content: "content"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), post.Id)
+	assert.Equal(t, "hello-2", post.Slug)
+	comment, err := s.Comments().Create(model.Comment{PostId: first.Id, Comment: "comment"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), comment.Id)
+	revision, err := s.Revisions().Add(model.Revision{PostId: first.Id, Title: "Hello"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, revision.Number)
+	user, err := s.Users().Create(model.User{Username: "alice"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), user.Id)
+	key, err := s.ApiKeys().Create(model.ApiKey{UserId: 1, Hash: "hash"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), key.Id)
+}
+
+func TestFileStore_CompactionBoundary(t *testing.T) {
+	var (
+		post     = model.Post{Id: 101, Title: "a", Slug: "a", Content: "content", CreationDate: time.Unix(10011, 0).UTC()}
+		comment  = model.Comment{Id: 1, PostId: 101, Comment: "comment", Author: "author", CreationDate: time.Unix(10013, 0).UTC()}
+		renamed  = model.Post{Id: 101, Title: "b", Slug: "b", Content: "content", CreationDate: time.Unix(10011, 0).UTC()}
+		reworded = model.Comment{Id: 1, PostId: 101, Comment: "edited", Author: "author", CreationDate: time.Unix(10013, 0).UTC()}
+	)
+
+	// every write below is the record that triggers compaction
+	tests := []struct {
+		name  string
+		write func(s *FileStore) error
+		check func(t *testing.T, s *FileStore)
+	}{
+		{
+			name:  "post insert",
+			write: func(s *FileStore) error { return s.Posts().Insert(model.Post{Id: 102, Title: "c", Slug: "c"}) },
+			check: func(t *testing.T, s *FileStore) {
+				_, err := s.Posts().GetById(102)
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:  "post update",
+			write: func(s *FileStore) error { return s.Posts().Update(renamed) },
+			check: func(t *testing.T, s *FileStore) {
+				got, err := s.Posts().GetById(post.Id)
+				require.NoError(t, err)
+				assert.Equal(t, "b", got.Title)
+			},
+		},
+		{
+			name:  "post delete",
+			write: func(s *FileStore) error { return s.Posts().Delete(post.Id) },
+			check: func(t *testing.T, s *FileStore) {
+				_, err := s.Posts().GetById(post.Id)
+				assert.ErrorIs(t, err, PostNotFoundError{post.Id})
+			},
+		},
+		{
+			name:  "comment insert",
+			write: func(s *FileStore) error { return s.Comments().Insert(comment) },
+			check: func(t *testing.T, s *FileStore) {
+				assert.Equal(t, []model.Comment{comment}, s.Comments().GetAllByPostId(post.Id))
+			},
+		},
+		{
+			name: "comment update",
+			write: func(s *FileStore) error {
+				require.NoError(t, s.Compact())
+				require.NoError(t, s.Comments().Insert(comment))
+				return s.Comments().Update(reworded)
+			},
+			check: func(t *testing.T, s *FileStore) {
+				assert.Equal(t, []model.Comment{reworded}, s.Comments().GetAllByPostId(post.Id))
+			},
+		},
+		{
+			name: "comment delete",
+			write: func(s *FileStore) error {
+				require.NoError(t, s.Compact())
+				require.NoError(t, s.Comments().Insert(comment))
+				return s.Comments().Delete(comment.Id)
+			},
+			check: func(t *testing.T, s *FileStore) {
+				assert.Empty(t, s.Comments().GetAllByPostId(post.Id))
+			},
+		},
+		{
+			name: "comments of post deleted",
+			write: func(s *FileStore) error {
+				require.NoError(t, s.Compact())
+				require.NoError(t, s.Comments().Insert(comment))
+				_, err := s.Comments().DeleteAllByPostId(post.Id)
+				return err
+			},
+			check: func(t *testing.T, s *FileStore) {
+				assert.Empty(t, s.Comments().GetAllByPostId(post.Id))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			dir := t.TempDir()
+			s, err := OpenFileStore(dir, 2)
+			require.NoError(t, err)
+			require.NoError(t, s.Posts().Insert(post))
+
+			// WHEN
+			require.NoError(t, tt.write(s))
+
+			// THEN
+			assert.Equal(t, 0, s.pending)
+			s = reopenFileStore(t, s, dir)
+			tt.check(t, s)
+		})
+	}
+}
+
+func TestFileStore_Create(t *testing.T) {
+	var fresh = model.Post{Title: "fresh", Content: "content"}
+
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir, 0)
+	require.NoError(t, err)
+	first, err := s.Posts().Create(fresh)
+	require.NoError(t, err)
+	second, err := s.Posts().Create(fresh)
+	require.NoError(t, err)
+	require.NoError(t, s.Posts().Delete(second.Id))
+	require.NoError(t, s.Compact())
+
+	s = reopenFileStore(t, s, dir)
+
+	post, err := s.Posts().GetById(first.Id)
+	require.NoError(t, err)
+	assert.Equal(t, first.CreationDate.UTC(), post.CreationDate)
+	third, err := s.Posts().Create(fresh)
+	require.NoError(t, err)
+	assert.Equal(t, second.Id+1, third.Id)
+}
+
+func TestFileStore_Recovery(t *testing.T) {
+	var (
+		post1 = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0).UTC()}
+		post2 = model.Post{Id: 102, Title: "post2", Slug: "post2", Content: "content", CreationDate: time.Unix(10012, 0).UTC()}
+	)
+
+	writeLog := func(t *testing.T, dir string, tail []byte) {
+		t.Helper()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Posts().Insert(post1))
+		require.NoError(t, s.Close())
+		f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0o644)
+		require.NoError(t, err)
+		_, err = f.Write(tail)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+	}
+
+	t.Run("truncated final record", func(t *testing.T) {
+		dir := t.TempDir()
+		line, err := encodeLogRecord(logRecord{Op: opPutPost, Post: &post2})
+		require.NoError(t, err)
+		writeLog(t, dir, line[:len(line)/2])
+
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		assert.Equal(t, []model.Post{post1}, s.posts.all())
+
+		require.NoError(t, s.Posts().Insert(post2))
+		s = reopenFileStore(t, s, dir)
+		assert.Equal(t, []model.Post{post1, post2}, s.posts.all())
+	})
+
+	t.Run("corrupted final record", func(t *testing.T) {
+		dir := t.TempDir()
+		writeLog(t, dir, []byte("00000000 {\"op\":\"put-post\"}\n"))
+
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		assert.Equal(t, []model.Post{post1}, s.posts.all())
+	})
+
+	t.Run("corrupted record in the middle", func(t *testing.T) {
+		dir := t.TempDir()
+		line, err := encodeLogRecord(logRecord{Op: opPutPost, Post: &post2})
+		require.NoError(t, err)
+		writeLog(t, dir, append([]byte("garbage\n"), line...))
+
+		_, err = OpenFileStore(dir, 0)
+		assert.Error(t, err)
+		assert.IsType(t, CorruptLogError{}, err)
+	})
+}
+
+func TestFileStore_Revisions(t *testing.T) {
+	var (
+		rev1 = model.Revision{PostId: 101, Author: "author1", CreatedAt: time.Unix(10011, 0).UTC(), Title: "post1", Content: "content"}
+		rev2 = model.Revision{PostId: 101, Author: "author2", CreatedAt: time.Unix(10012, 0).UTC(), Title: "post1", Content: "new content"}
+		rev3 = model.Revision{PostId: 102, Author: "author1", CreatedAt: time.Unix(10013, 0).UTC(), Title: "post2", Content: "content"}
+	)
+
+	for _, compact := range []bool{false, true} {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		for _, rev := range []model.Revision{rev1, rev2, rev3} {
+			_, err := s.Revisions().Add(rev)
+			require.NoError(t, err)
+		}
+		removed, err := s.Revisions().DeleteAllByPostId(rev3.PostId)
+		require.NoError(t, err)
+		require.Equal(t, 1, removed)
+		if compact {
+			require.NoError(t, s.Compact())
+		}
+
+		s = reopenFileStore(t, s, dir)
+
+		rev1.Number, rev2.Number = 1, 2
+		assert.Equal(t, []model.Revision{rev1, rev2}, s.Revisions().GetAllByPostId(rev1.PostId), "compact: %v", compact)
+		assert.Empty(t, s.Revisions().GetAllByPostId(rev3.PostId), "compact: %v", compact)
+	}
+}
+
+func TestFileStore_Users(t *testing.T) {
+	var (
+		alice = model.User{Username: "alice", PasswordHash: "hash1", CreationDate: time.Unix(10011, 0).UTC()}
+		bob   = model.User{Username: "bob", PasswordHash: "hash2", CreationDate: time.Unix(10012, 0).UTC()}
+	)
+
+	for _, compact := range []bool{false, true} {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		for _, user := range []model.User{alice, bob} {
+			_, err := s.Users().Create(user)
+			require.NoError(t, err)
+		}
+		promoted := bob
+		promoted.Id, promoted.Role = 2, model.RoleEditor
+		require.NoError(t, s.Users().Update(promoted))
+		if compact {
+			require.NoError(t, s.Compact())
+		}
+
+		s = reopenFileStore(t, s, dir)
+
+		got, err := s.Users().GetByUsername("Alice")
+		require.NoError(t, err, "compact: %v", compact)
+		alice.Id = 1
+		assert.Equal(t, &alice, got, "compact: %v", compact)
+		got, err = s.Users().GetById(2)
+		require.NoError(t, err, "compact: %v", compact)
+		assert.Equal(t, &promoted, got, "compact: %v", compact)
+		created, err := s.Users().Create(model.User{Username: "carol", PasswordHash: "hash3"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), created.Id, "compact: %v", compact)
+		_, err = s.Users().Create(model.User{Username: "BOB", PasswordHash: "hash4"})
+		assert.ErrorIs(t, err, ErrAlreadyExists, "compact: %v", compact)
+	}
+}
+
+func TestFileStore_ApiKeys(t *testing.T) {
+	var (
+		usedAt = time.Unix(10013, 0).UTC()
+		key1   = model.ApiKey{UserId: 1, Name: "ci", Hash: "hash1", Scopes: []string{model.ScopePostsWrite}, CreationDate: time.Unix(10011, 0).UTC()}
+		key2   = model.ApiKey{UserId: 1, Name: "importer", Hash: "hash2", CreationDate: time.Unix(10012, 0).UTC()}
+	)
+
+	for _, compact := range []bool{false, true} {
+		dir := t.TempDir()
+		s, err := OpenFileStore(dir, 0)
+		require.NoError(t, err)
+		for _, key := range []model.ApiKey{key1, key2} {
+			_, err := s.ApiKeys().Create(key)
+			require.NoError(t, err)
+		}
+		used := key1
+		used.Id, used.LastUsedAt = 1, &usedAt
+		require.NoError(t, s.ApiKeys().Touch(used.Id, usedAt))
+		revoked := key2
+		revoked.Id, revoked.RevokedAt = 2, &usedAt
+		require.NoError(t, s.ApiKeys().Update(revoked))
+		require.NoError(t, s.ApiKeys().Update(model.ApiKey{Id: 2, UserId: 1, Name: "importer"}))
+		assert.ErrorIs(t, s.ApiKeys().Touch(3, usedAt), ErrNotFound)
+		if compact {
+			require.NoError(t, s.Compact())
+		}
+
+		s = reopenFileStore(t, s, dir)
+
+		got, err := s.ApiKeys().GetByHash("hash1")
+		require.NoError(t, err, "compact: %v", compact)
+		assert.Equal(t, &used, got, "compact: %v", compact)
+		got, err = s.ApiKeys().GetById(2)
+		require.NoError(t, err)
+		assert.True(t, got.Revoked(), "compact: %v", compact)
+		assert.Len(t, s.ApiKeys().GetAllByUserId(1), 2, "compact: %v", compact)
+		created, err := s.ApiKeys().Create(model.ApiKey{UserId: 2, Name: "new", Hash: "hash3"})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), created.Id, "compact: %v", compact)
+	}
+}
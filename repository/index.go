@@ -0,0 +1,54 @@
+package repository
+
+// idList keeps ids in insertion order while allowing O(1) removal.
+// Removed ids leave a tombstone that is skipped on iteration; the
+// backing slice is compacted once tombstones outnumber live entries.
+type idList struct {
+	ids []uint64
+	pos map[uint64]int
+}
+
+func newIdList() *idList {
+	return &idList{pos: make(map[uint64]int)}
+}
+
+func (l *idList) add(id uint64) {
+	if _, ok := l.pos[id]; ok {
+		return
+	}
+	l.pos[id] = len(l.ids)
+	l.ids = append(l.ids, id)
+}
+
+func (l *idList) remove(id uint64) {
+	if _, ok := l.pos[id]; !ok {
+		return
+	}
+	delete(l.pos, id)
+	if len(l.ids) > 2*len(l.pos)+16 {
+		l.compact()
+	}
+}
+
+func (l *idList) len() int {
+	return len(l.pos)
+}
+
+func (l *idList) each(fn func(id uint64)) {
+	for i, id := range l.ids {
+		if p, ok := l.pos[id]; ok && p == i {
+			fn(id)
+		}
+	}
+}
+
+func (l *idList) compact() {
+	ids := make([]uint64, 0, len(l.pos))
+	for i, id := range l.ids {
+		if p, ok := l.pos[id]; ok && p == i {
+			l.pos[id] = len(ids)
+			ids = append(ids, id)
+		}
+	}
+	l.ids = ids
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+type PostSortField string
+
+const (
+	SortByCreationDate    PostSortField = "creationDate"
+	SortByTitle           PostSortField = "title"
+	SortByPublicationDate PostSortField = "publicationDate"
+)
+
+// sortableTimeLayout formats UTC times so that their lexical order matches
+// their chronological order.
+const sortableTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// PostQuery selects a page of posts. A zero From or To leaves that end of the
+// creation date range open; both ends are inclusive. Tag and Category are
+// normalized before matching and an empty value matches every post, as does
+// an empty Status. Posts owned by a non-zero AnyStatusOwner match whatever
+// their status. A Limit of zero or less returns all matching posts.
+type PostQuery struct {
+	SortBy     PostSortField
+	Descending bool
+	From       time.Time
+	To         time.Time
+	Tag        string
+	Category   string
+	Status     string
+	// AnyStatusOwner is the id of the user whose posts are exempt from Status.
+	AnyStatusOwner uint64
+	Cursor         string
+	Limit          int
+}
+
+type PostPage struct {
+	Items []model.Post
+	// NextCursor is empty on the last page.
+	NextCursor string
+	// Total is the number of posts matching the query across all pages.
+	Total int
+}
+
+type InvalidCursorError struct {
+	cursor string
+}
+
+func (e InvalidCursorError) Error() string {
+	return fmt.Sprintf("Error: cursor: %q is not valid for this query!", e.cursor)
+}
+
+type InvalidSortFieldError struct {
+	field string
+}
+
+func (e InvalidSortFieldError) Error() string {
+	return fmt.Sprintf("Error: cannot sort by: %q!", e.field)
+}
+
+func (c *PostRepository) Query(q PostQuery) (PostPage, error) {
+	if q.SortBy == "" {
+		q.SortBy = SortByCreationDate
+	}
+	var key func(post model.Post) string
+	switch q.SortBy {
+	case SortByCreationDate:
+		key = func(post model.Post) string { return timeSortKey(post.CreationDate) }
+	case SortByTitle:
+		key = func(post model.Post) string { return strings.ToLower(post.Title) }
+	case SortByPublicationDate:
+		key = func(post model.Post) string { return timeSortKey(post.PublicationDate()) }
+	default:
+		return PostPage{}, InvalidSortFieldError{string(q.SortBy)}
+	}
+
+	candidates := c.all
+	if q.Tag != "" {
+		tag := NormalizeTag(q.Tag)
+		candidates = func() []model.Post { return c.allTagged(tag) }
+	}
+	category := NormalizeTag(q.Category)
+
+	var (
+		posts   []model.Post
+		entries []cursorEntry
+	)
+	for _, post := range candidates() {
+		if !q.From.IsZero() && post.CreationDate.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && post.CreationDate.After(q.To) {
+			continue
+		}
+		if category != "" && post.Category != category {
+			continue
+		}
+		if !matchesStatus(post, q.Status, q.AnyStatusOwner) {
+			continue
+		}
+		posts = append(posts, post)
+		entries = append(entries, cursorEntry{key: key(post), id: post.Id})
+	}
+	sortEntries(entries, q.Descending, func(i, j int) {
+		posts[i], posts[j] = posts[j], posts[i]
+	})
+
+	start, end, next, err := paginate(entries, string(q.SortBy), q.Descending, q.Cursor, q.Limit)
+	if err != nil {
+		return PostPage{}, err
+	}
+	return PostPage{Items: posts[start:end], NextCursor: next, Total: len(posts)}, nil
+}
+
+// CommentQuery selects a page of the comments of a post ordered by creation
+// date. An empty Author matches every author, otherwise the match ignores
+// case. A Limit of zero or less returns all matching comments.
+type CommentQuery struct {
+	Author     string
+	Descending bool
+	Cursor     string
+	Limit      int
+}
+
+type CommentPage struct {
+	Items []model.Comment
+	// NextCursor is empty on the last page.
+	NextCursor string
+	// Total is the number of comments matching the query across all pages.
+	Total int
+}
+
+func (c *CommentRepository) Query(postId uint64, q CommentQuery) (CommentPage, error) {
+	var (
+		comments []model.Comment
+		entries  []cursorEntry
+	)
+	for _, comment := range c.GetAllByPostId(postId) {
+		if q.Author != "" && !strings.EqualFold(comment.Author, q.Author) {
+			continue
+		}
+		comments = append(comments, comment)
+		entries = append(entries, cursorEntry{key: timeSortKey(comment.CreationDate), id: comment.Id})
+	}
+	sortEntries(entries, q.Descending, func(i, j int) {
+		comments[i], comments[j] = comments[j], comments[i]
+	})
+
+	start, end, next, err := paginate(entries, string(SortByCreationDate), q.Descending, q.Cursor, q.Limit)
+	if err != nil {
+		return CommentPage{}, err
+	}
+	return CommentPage{Items: comments[start:end], NextCursor: next, Total: len(comments)}, nil
+}
+
+// matchesStatus reports whether the post has the status, any post matching
+// an empty one, or is owned by a non-zero anyStatusOwner.
+func matchesStatus(post model.Post, status string, anyStatusOwner uint64) bool {
+	return status == "" || post.PublicationStatus() == status || anyStatusOwner != 0 && post.OwnerId == anyStatusOwner
+}
+
+func timeSortKey(t time.Time) string {
+	return t.UTC().Format(sortableTimeLayout)
+}
+
+type cursorEntry struct {
+	key string
+	id  uint64
+}
+
+func (e cursorEntry) compare(other cursorEntry) int {
+	if c := strings.Compare(e.key, other.key); c != 0 {
+		return c
+	}
+	switch {
+	case e.id < other.id:
+		return -1
+	case e.id > other.id:
+		return 1
+	}
+	return 0
+}
+
+type pageCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Key        string `json:"k"`
+	Id         uint64 `json:"i"`
+}
+
+// sortEntries orders entries by key and id, mirroring every swap on the
+// slice of items the entries were built from through swap.
+func sortEntries(entries []cursorEntry, descending bool, swap func(i, j int)) {
+	sort.Sort(entrySorter{entries: entries, descending: descending, swap: swap})
+}
+
+type entrySorter struct {
+	entries    []cursorEntry
+	descending bool
+	swap       func(i, j int)
+}
+
+func (s entrySorter) Len() int { return len(s.entries) }
+
+func (s entrySorter) Less(i, j int) bool {
+	c := s.entries[i].compare(s.entries[j])
+	if s.descending {
+		return c > 0
+	}
+	return c < 0
+}
+
+func (s entrySorter) Swap(i, j int) {
+	s.entries[i], s.entries[j] = s.entries[j], s.entries[i]
+	s.swap(i, j)
+}
+
+// paginate returns the bounds of the page within the sorted entries that
+// starts right after the cursor, along with the cursor of the next page.
+func paginate(entries []cursorEntry, sortBy string, descending bool, cursor string, limit int) (int, int, string, error) {
+	start := 0
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil || after.Sort != sortBy || after.Descending != descending {
+			return 0, 0, "", InvalidCursorError{cursor}
+		}
+		last := cursorEntry{key: after.Key, id: after.Id}
+		start = sort.Search(len(entries), func(i int) bool {
+			c := entries[i].compare(last)
+			if descending {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+	end := len(entries)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	next := ""
+	if end < len(entries) && end > start {
+		last := entries[end-1]
+		next = encodeCursor(pageCursor{Sort: sortBy, Descending: descending, Key: last.key, Id: last.id})
+	}
+	return start, end, next, nil
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
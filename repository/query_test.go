@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func postIds(posts []model.Post) []uint64 {
+	ids := make([]uint64, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.Id)
+	}
+	return ids
+}
+
+func TestPostRepository_Query(t *testing.T) {
+	var (
+		post1 = model.Post{Id: 1, Title: "banana", Content: "content", CreationDate: time.Unix(10030, 0)}
+		post2 = model.Post{Id: 2, Title: "Apple", Content: "content", CreationDate: time.Unix(10010, 0)}
+		post3 = model.Post{Id: 3, Title: "cherry", Content: "content", CreationDate: time.Unix(10020, 0)}
+		post4 = model.Post{Id: 4, Title: "apple", Content: "content", CreationDate: time.Unix(10020, 0)}
+		posts = []model.Post{post1, post2, post3, post4}
+	)
+
+	tests := []struct {
+		name        string
+		query       PostQuery
+		expectedIds []uint64
+		expectNext  bool
+	}{
+		{name: "default sort is creation date ascending", query: PostQuery{}, expectedIds: []uint64{2, 3, 4, 1}},
+		{name: "creation date descending", query: PostQuery{SortBy: SortByCreationDate, Descending: true}, expectedIds: []uint64{1, 4, 3, 2}},
+		{name: "title ignores case", query: PostQuery{SortBy: SortByTitle}, expectedIds: []uint64{2, 4, 1, 3}},
+		{name: "title descending", query: PostQuery{SortBy: SortByTitle, Descending: true}, expectedIds: []uint64{3, 1, 4, 2}},
+		{name: "from is inclusive", query: PostQuery{From: time.Unix(10020, 0)}, expectedIds: []uint64{3, 4, 1}},
+		{name: "to is inclusive", query: PostQuery{To: time.Unix(10020, 0)}, expectedIds: []uint64{2, 3, 4}},
+		{name: "date range", query: PostQuery{From: time.Unix(10015, 0), To: time.Unix(10025, 0)}, expectedIds: []uint64{3, 4}},
+		{name: "limited", query: PostQuery{Limit: 2}, expectedIds: []uint64{2, 3}, expectNext: true},
+		{name: "limit covering everything", query: PostQuery{Limit: 4}, expectedIds: []uint64{2, 3, 4, 1}},
+	}
+	p := CustomPostRepository(posts)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := p.Query(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedIds, postIds(page.Items))
+			assert.Equal(t, len(tt.expectedIds) > 0 && !tt.expectNext, page.NextCursor == "")
+		})
+	}
+
+	t.Run("walking pages with cursors", func(t *testing.T) {
+		for _, sortBy := range []PostSortField{SortByCreationDate, SortByTitle} {
+			for _, descending := range []bool{false, true} {
+				all, err := p.Query(PostQuery{SortBy: sortBy, Descending: descending})
+				require.NoError(t, err)
+
+				var walked []uint64
+				query := PostQuery{SortBy: sortBy, Descending: descending, Limit: 3}
+				for {
+					page, err := p.Query(query)
+					require.NoError(t, err)
+					assert.Equal(t, len(posts), page.Total)
+					walked = append(walked, postIds(page.Items)...)
+					if page.NextCursor == "" {
+						break
+					}
+					query.Cursor = page.NextCursor
+				}
+				assert.Equal(t, postIds(all.Items), walked)
+			}
+		}
+	})
+
+	t.Run("cursor survives deletion of its post", func(t *testing.T) {
+		p := CustomPostRepository(posts)
+		first, err := p.Query(PostQuery{Limit: 2})
+		require.NoError(t, err)
+		require.NoError(t, p.Delete(3))
+		second, err := p.Query(PostQuery{Limit: 2, Cursor: first.NextCursor})
+		require.NoError(t, err)
+		assert.Equal(t, []uint64{4, 1}, postIds(second.Items))
+		assert.Equal(t, 3, second.Total)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := p.Query(PostQuery{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, InvalidCursorError{"not a cursor"})
+	})
+
+	t.Run("cursor from a different ordering", func(t *testing.T) {
+		page, err := p.Query(PostQuery{SortBy: SortByTitle, Limit: 1})
+		require.NoError(t, err)
+		_, err = p.Query(PostQuery{SortBy: SortByCreationDate, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, InvalidCursorError{page.NextCursor})
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		_, err := p.Query(PostQuery{SortBy: "author"})
+		assert.ErrorIs(t, err, InvalidSortFieldError{"author"})
+	})
+}
+
+func TestPostRepository_QueryByStatus(t *testing.T) {
+	var (
+		legacy    = model.Post{Id: 1, Title: "legacy", Tags: []string{"go"}, CreationDate: time.Unix(10010, 0)}
+		published = model.Post{Id: 2, Title: "published", Status: model.StatusPublished, Tags: []string{"go"}, CreationDate: time.Unix(10020, 0)}
+		draft     = model.Post{Id: 3, Title: "draft", Status: model.StatusDraft, OwnerId: 7, Tags: []string{"go", "rust"}, CreationDate: time.Unix(10030, 0)}
+		scheduled = model.Post{Id: 4, Title: "scheduled", Status: model.StatusScheduled, CreationDate: time.Unix(10040, 0)}
+	)
+	p := CustomPostRepository([]model.Post{legacy, published, draft, scheduled})
+
+	tests := []struct {
+		status         string
+		anyStatusOwner uint64
+		expectedIds    []uint64
+	}{
+		{status: "", expectedIds: []uint64{1, 2, 3, 4}},
+		{status: model.StatusPublished, expectedIds: []uint64{1, 2}},
+		{status: model.StatusDraft, expectedIds: []uint64{3}},
+		{status: model.StatusArchived, expectedIds: []uint64{}},
+		{status: model.StatusPublished, anyStatusOwner: 7, expectedIds: []uint64{1, 2, 3}},
+		{status: model.StatusPublished, anyStatusOwner: 8, expectedIds: []uint64{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.status, tt.anyStatusOwner), func(t *testing.T) {
+			page, err := p.Query(PostQuery{Status: tt.status, AnyStatusOwner: tt.anyStatusOwner})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedIds, postIds(page.Items))
+			assert.Equal(t, len(tt.expectedIds), page.Total)
+		})
+	}
+
+	assert.Equal(t, []TagCount{{Tag: "go", Count: 3}, {Tag: "rust", Count: 1}}, p.Tags("", 0))
+	assert.Equal(t, []TagCount{{Tag: "go", Count: 2}}, p.Tags(model.StatusPublished, 0))
+	assert.Equal(t, []TagCount{{Tag: "go", Count: 3}, {Tag: "rust", Count: 1}}, p.Tags(model.StatusPublished, 7))
+}
+
+func TestPostRepository_QueryByPublicationDate(t *testing.T) {
+	var publishAt = time.Unix(10040, 0)
+	p := CustomPostRepository([]model.Post{
+		{Id: 1, Title: "scheduled early", Status: model.StatusPublished, PublishAt: &publishAt, CreationDate: time.Unix(10010, 0)},
+		{Id: 2, Title: "second", CreationDate: time.Unix(10020, 0)},
+		{Id: 3, Title: "third", CreationDate: time.Unix(10030, 0)},
+	})
+
+	page, err := p.Query(PostQuery{SortBy: SortByPublicationDate, Descending: true, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3}, postIds(page.Items))
+
+	page, err = p.Query(PostQuery{SortBy: SortByPublicationDate, Descending: true, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, postIds(page.Items))
+}
+
+func TestCommentRepository_Query(t *testing.T) {
+	var (
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "alice", CreationDate: time.Unix(10030, 0)}
+		comment2 = model.Comment{Id: 2, PostId: 101, Comment: "comment2", Author: "Bob", CreationDate: time.Unix(10010, 0)}
+		comment3 = model.Comment{Id: 3, PostId: 101, Comment: "comment3", Author: "Alice", CreationDate: time.Unix(10020, 0)}
+		comment4 = model.Comment{Id: 4, PostId: 102, Comment: "comment4", Author: "alice", CreationDate: time.Unix(10000, 0)}
+		comments = []model.Comment{comment1, comment2, comment3, comment4}
+	)
+
+	tests := []struct {
+		name          string
+		postId        uint64
+		query         CommentQuery
+		expectedIds   []uint64
+		expectedTotal int
+		expectNext    bool
+	}{
+		{name: "oldest first", postId: 101, query: CommentQuery{}, expectedIds: []uint64{2, 3, 1}, expectedTotal: 3},
+		{name: "newest first", postId: 101, query: CommentQuery{Descending: true}, expectedIds: []uint64{1, 3, 2}, expectedTotal: 3},
+		{name: "author filter ignores case", postId: 101, query: CommentQuery{Author: "ALICE"}, expectedIds: []uint64{3, 1}, expectedTotal: 2},
+		{name: "limited", postId: 101, query: CommentQuery{Limit: 1}, expectedIds: []uint64{2}, expectedTotal: 3, expectNext: true},
+		{name: "no comments", postId: 999, query: CommentQuery{}, expectedIds: []uint64{}, expectedTotal: 0},
+	}
+	c := CustomCommentRepository(comments)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := c.Query(tt.postId, tt.query)
+			require.NoError(t, err)
+			ids := []uint64{}
+			for _, comment := range page.Items {
+				ids = append(ids, comment.Id)
+			}
+			assert.Equal(t, tt.expectedIds, ids)
+			assert.Equal(t, tt.expectedTotal, page.Total)
+			assert.Equal(t, tt.expectNext, page.NextCursor != "")
+		})
+	}
+
+	t.Run("walking pages with cursors", func(t *testing.T) {
+		var walked []uint64
+		query := CommentQuery{Descending: true, Limit: 2}
+		for {
+			page, err := c.Query(101, query)
+			require.NoError(t, err)
+			for _, comment := range page.Items {
+				walked = append(walked, comment.Id)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []uint64{1, 3, 2}, walked)
+	})
+
+	t.Run("cursor from the other direction", func(t *testing.T) {
+		page, err := c.Query(101, CommentQuery{Limit: 1})
+		require.NoError(t, err)
+		_, err = c.Query(101, CommentQuery{Descending: true, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, InvalidCursorError{page.NextCursor})
+	})
+}
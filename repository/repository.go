@@ -2,21 +2,34 @@ package repository
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"bitbucket.org/mindera/go-rest-blog/model"
 )
 
 type CommentRepository struct {
-	repository []model.Comment
+	mu       sync.RWMutex
+	comments map[uint64]model.Comment
+	order    *idList
+	byPost   map[uint64]*idList
+	lastId   uint64
 }
 
 func NewCommentRepository() *CommentRepository {
-	repo := CustomCommentRepository(make([]model.Comment, 0))
-	return &repo
+	return CustomCommentRepository(make([]model.Comment, 0))
 }
 
-func CustomCommentRepository(mockStorage []model.Comment) CommentRepository {
-	return CommentRepository{repository: mockStorage}
+func CustomCommentRepository(mockStorage []model.Comment) *CommentRepository {
+	repo := &CommentRepository{
+		comments: make(map[uint64]model.Comment, len(mockStorage)),
+		order:    newIdList(),
+		byPost:   make(map[uint64]*idList),
+	}
+	for _, comment := range mockStorage {
+		repo.put(comment)
+	}
+	return repo
 }
 
 type CommentAlreadyExistsError struct {
@@ -27,6 +40,18 @@ func (e CommentAlreadyExistsError) Error() string {
 	return fmt.Sprintf("Error: Comment with id: %v already exists in the repository!", e.id)
 }
 
+func (e CommentAlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+func (e CommentAlreadyExistsError) ID() uint64 {
+	return e.id
+}
+
+func (e CommentAlreadyExistsError) Kind() string {
+	return CommentKind
+}
+
 type CommentNotFoundError struct {
 	id uint64
 }
@@ -35,61 +60,252 @@ func (e CommentNotFoundError) Error() string {
 	return fmt.Sprintf("Error: Comment with id: %v was not found in the repository!", e.id)
 }
 
+func (e CommentNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func (e CommentNotFoundError) ID() uint64 {
+	return e.id
+}
+
+func (e CommentNotFoundError) Kind() string {
+	return CommentKind
+}
+
 func (c *CommentRepository) Insert(comment model.Comment) error {
 	// TODO: Insert should insert a comment passed as an argument to the persistent in memory repository.
 	//  The method should return an error as an instance of `CommentAlreadyExistsError` struct
 	//  when a comment with given id already exists in the repository.
-	for _, i := range c.repository {
-		if i.Id == comment.Id {
-			return CommentAlreadyExistsError{comment.Id}
-		}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.comments[comment.Id]; ok {
+		return CommentAlreadyExistsError{comment.Id}
 	}
-	c.repository = append(c.repository, comment)
+	c.put(comment)
 	return nil
 }
 
+// Create inserts the comment, allocating the next free id when comment.Id is
+// zero and stamping CreationDate with the current time when it is unset.
+func (c *CommentRepository) Create(comment model.Comment) (model.Comment, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	comment, err := c.allocate(comment)
+	if err != nil {
+		return comment, err
+	}
+	c.put(comment)
+	return comment, nil
+}
+
+// allocate fills in the id and creation date Create stores the comment
+// with. Callers must hold the lock.
+func (c *CommentRepository) allocate(comment model.Comment) (model.Comment, error) {
+	if comment.Id == 0 {
+		comment.Id = c.lastId + 1
+	} else if _, ok := c.comments[comment.Id]; ok {
+		return comment, CommentAlreadyExistsError{comment.Id}
+	}
+	if comment.CreationDate.IsZero() {
+		comment.CreationDate = time.Now().UTC()
+	}
+	return comment, nil
+}
+
+// prepareCreate returns the comment as Create would store it, without
+// storing it.
+func (c *CommentRepository) prepareCreate(comment model.Comment) (model.Comment, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.allocate(comment)
+}
+
 func (c *CommentRepository) GetById(id uint64) (*model.Comment, error) {
 	// TODO: GetById should return a comment from a repository that has a given id.
 	//  If there's no comment with given id, this function should return a (nil, CommentNotFoundError) pair
 	//  with CommentNotFound instance having id member variable set with id passed to this method.
-	if len(c.repository) <= 0 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	comment, ok := c.comments[id]
+	if !ok {
 		return nil, CommentNotFoundError{id}
 	}
-	for _, i := range c.repository {
-		if i.Id == id {
-			return &i, nil
-		}
-	}
-	return nil, CommentNotFoundError{id}
+	return &comment, nil
 }
 
 func (c *CommentRepository) GetAllByPostId(id uint64) []model.Comment {
 	// TODO: GetAllByPostId should return a slice of all comments that have PostId member variable
 	//  equal to given id.
 	//  The method should return an empty slice when there are no comments with given id in the repository.
-	comments := []model.Comment{}
-	if len(c.repository) <= 0 {
-		return comments
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids, ok := c.byPost[id]
+	if !ok {
+		return []model.Comment{}
+	}
+	comments := make([]model.Comment, 0, ids.len())
+	ids.each(func(id uint64) {
+		comments = append(comments, c.comments[id])
+	})
+	return comments
+}
+
+func (c *CommentRepository) Update(comment model.Comment) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.comments[comment.Id]; !ok {
+		return CommentNotFoundError{comment.Id}
+	}
+	c.put(comment)
+	return nil
+}
+
+func (c *CommentRepository) Delete(id uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.comments[id]; !ok {
+		return CommentNotFoundError{id}
 	}
-	for _, i := range c.repository {
-		if i.PostId == id {
-			comments = append(comments, i)
+	c.remove(id)
+	return nil
+}
+
+func (c *CommentRepository) DeleteAllByPostId(id uint64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeAllByPostId(id), nil
+}
+
+// put stores the comment, keeping the post index in sync when an update
+// moves a comment to a different post. Callers must hold the write lock.
+func (c *CommentRepository) put(comment model.Comment) {
+	if old, ok := c.comments[comment.Id]; ok && old.PostId != comment.PostId {
+		c.unindex(old)
+	}
+	c.comments[comment.Id] = comment
+	c.order.add(comment.Id)
+	c.reserve(comment.Id)
+	ids, ok := c.byPost[comment.PostId]
+	if !ok {
+		ids = newIdList()
+		c.byPost[comment.PostId] = ids
+	}
+	ids.add(comment.Id)
+}
+
+func (c *CommentRepository) remove(id uint64) {
+	comment, ok := c.comments[id]
+	if !ok {
+		return
+	}
+	delete(c.comments, id)
+	c.order.remove(id)
+	c.unindex(comment)
+}
+
+func (c *CommentRepository) removeAllByPostId(postId uint64) int {
+	ids, ok := c.byPost[postId]
+	if !ok {
+		return 0
+	}
+	var removed []uint64
+	ids.each(func(id uint64) {
+		removed = append(removed, id)
+	})
+	for _, id := range removed {
+		c.remove(id)
+	}
+	return len(removed)
+}
+
+func (c *CommentRepository) unindex(comment model.Comment) {
+	if ids, ok := c.byPost[comment.PostId]; ok {
+		ids.remove(comment.Id)
+		if ids.len() == 0 {
+			delete(c.byPost, comment.PostId)
 		}
 	}
+}
+
+// reserve makes sure allocated ids never go back to id or below it, even
+// once the entity holding it is deleted. Callers must hold the write lock.
+func (c *CommentRepository) reserve(id uint64) {
+	if id > c.lastId {
+		c.lastId = id
+	}
+}
+
+func (c *CommentRepository) all() []model.Comment {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	comments := make([]model.Comment, 0, c.order.len())
+	c.order.each(func(id uint64) {
+		comments = append(comments, c.comments[id])
+	})
 	return comments
 }
 
+func (c *CommentRepository) upsert(comment model.Comment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(comment)
+}
+
+func (c *CommentRepository) discard(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(id)
+}
+
+func (c *CommentRepository) discardAllByPostId(postId uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeAllByPostId(postId)
+}
+
+func (c *CommentRepository) lastAllocatedId() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastId
+}
+
+func (c *CommentRepository) reserveUpTo(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reserve(id)
+}
+
 type PostRepository struct {
-	repository []model.Post
+	mu     sync.RWMutex
+	posts  map[uint64]model.Post
+	order  *idList
+	byTag  map[string]*idList
+	bySlug map[string]uint64
+	// redirects maps slugs replaced by a title change to their post.
+	redirects map[string]uint64
+	// nextSuffix holds, per slug generated from a title, the numeric suffix
+	// to try first for the next duplicate.
+	nextSuffix map[string]int
+	lastId     uint64
 }
 
-func CustomPostRepository(mockStorage []model.Post) PostRepository {
-	return PostRepository{repository: mockStorage}
+func CustomPostRepository(mockStorage []model.Post) *PostRepository {
+	repo := &PostRepository{
+		posts:      make(map[uint64]model.Post, len(mockStorage)),
+		order:      newIdList(),
+		byTag:      make(map[string]*idList),
+		bySlug:     make(map[string]uint64),
+		redirects:  make(map[string]uint64),
+		nextSuffix: make(map[string]int),
+	}
+	for _, post := range mockStorage {
+		repo.put(post)
+	}
+	return repo
 }
 
 func NewPostRepository() *PostRepository {
-	repo := CustomPostRepository(make([]model.Post, 0))
-	return &repo
+	return CustomPostRepository(make([]model.Post, 0))
 }
 
 type PostAlreadyExistsError struct {
@@ -100,6 +316,18 @@ func (e PostAlreadyExistsError) Error() string {
 	return fmt.Sprintf("Error: Post with id: %v already exists in the repository!", e.id)
 }
 
+func (e PostAlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+func (e PostAlreadyExistsError) ID() uint64 {
+	return e.id
+}
+
+func (e PostAlreadyExistsError) Kind() string {
+	return PostKind
+}
+
 type PostNotFoundError struct {
 	id uint64
 }
@@ -108,30 +336,245 @@ func (e PostNotFoundError) Error() string {
 	return fmt.Sprintf("Error: Post with id: %v was not found in the repository!", e.id)
 }
 
+func (e PostNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+func (e PostNotFoundError) ID() uint64 {
+	return e.id
+}
+
+func (e PostNotFoundError) Kind() string {
+	return PostKind
+}
+
 func (c *PostRepository) Insert(post model.Post) error {
 	// TODO:  Insert should insert a post passed as an argument to the persistent in memory repository.
 	//  The method should return an error as an instance of `PostAlreadyExistsError` struct
 	//  when a post with given id already exists in the repository.
-	for _, i := range c.repository {
-		if i.Id == post.Id {
-			return PostAlreadyExistsError{post.Id}
-		}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.posts[post.Id]; ok {
+		return PostAlreadyExistsError{post.Id}
 	}
-	c.repository = append(c.repository, post)
+	c.put(post)
 	return nil
 }
 
+// Create inserts the post, allocating the next free id when post.Id is
+// zero and stamping CreationDate with the current time when it is unset.
+func (c *PostRepository) Create(post model.Post) (model.Post, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	post, err := c.allocate(post)
+	if err != nil {
+		return post, err
+	}
+	return c.put(post), nil
+}
+
+// allocate fills in the id and creation date Create stores the post with.
+// Callers must hold the lock.
+func (c *PostRepository) allocate(post model.Post) (model.Post, error) {
+	if post.Id == 0 {
+		post.Id = c.lastId + 1
+	} else if _, ok := c.posts[post.Id]; ok {
+		return post, PostAlreadyExistsError{post.Id}
+	}
+	if post.CreationDate.IsZero() {
+		post.CreationDate = time.Now().UTC()
+	}
+	return post, nil
+}
+
 func (c *PostRepository) GetById(id uint64) (*model.Post, error) {
 	// TODO: GetById should return a post from a repository that has a given id.
 	//  If there's no post with given id, this function should return a (nil, PostNotFoundError) pair
 	//  with PostNotFoundError instance having id member variable set with id passed to this method.
-	if len(c.repository) <= 0 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	post, ok := c.posts[id]
+	if !ok {
 		return nil, PostNotFoundError{id}
 	}
-	for _, i := range c.repository {
-		if i.Id == id {
-			return &i, nil
+	return &post, nil
+}
+
+func (c *PostRepository) Update(post model.Post) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.posts[post.Id]; !ok {
+		return PostNotFoundError{post.Id}
+	}
+	c.put(post)
+	return nil
+}
+
+// PublishDue publishes the post with the id if it is still scheduled and due
+// at now, leaving everything but its status as stored, and reports whether
+// it did.
+func (c *PostRepository) PublishDue(id uint64, now time.Time) (model.Post, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	post, ok := c.posts[id]
+	if !ok {
+		return model.Post{}, false, PostNotFoundError{id}
+	}
+	if !post.Due(now) {
+		return post, false, nil
+	}
+	post.Status = model.StatusPublished
+	return c.put(post), true, nil
+}
+
+func (c *PostRepository) Delete(id uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.posts[id]; !ok {
+		return PostNotFoundError{id}
+	}
+	c.remove(id)
+	return nil
+}
+
+// put normalizes the post and stores it. It returns the post as stored.
+// Callers must hold the write lock.
+func (c *PostRepository) put(post model.Post) model.Post {
+	return c.place(c.normalize(post))
+}
+
+// normalize returns the post as put would store it: with normalized tags and
+// category and its slug picked. Callers must hold the lock.
+func (c *PostRepository) normalize(post model.Post) model.Post {
+	post.Tags = NormalizeTags(post.Tags)
+	post.Category = NormalizeTag(post.Category)
+	post.Slug = c.pickSlug(post)
+	return post
+}
+
+// place stores the normalized post in insertion order, keeping the slug and
+// tag indexes in sync. Callers must hold the write lock.
+func (c *PostRepository) place(post model.Post) model.Post {
+	c.claimSlug(post)
+	if old, ok := c.posts[post.Id]; ok {
+		c.untag(old)
+	}
+	c.posts[post.Id] = post
+	c.order.add(post.Id)
+	c.reserve(post.Id)
+	for _, tag := range post.Tags {
+		ids, ok := c.byTag[tag]
+		if !ok {
+			ids = newIdList()
+			c.byTag[tag] = ids
 		}
+		ids.add(post.Id)
+	}
+	return post
+}
+
+func (c *PostRepository) untag(post model.Post) {
+	for _, tag := range post.Tags {
+		if ids, ok := c.byTag[tag]; ok {
+			ids.remove(post.Id)
+			if ids.len() == 0 {
+				delete(c.byTag, tag)
+			}
+		}
+	}
+}
+
+// reserve makes sure allocated ids never go back to id or below it, even
+// once the entity holding it is deleted. Callers must hold the write lock.
+func (c *PostRepository) reserve(id uint64) {
+	if id > c.lastId {
+		c.lastId = id
+	}
+}
+
+func (c *PostRepository) remove(id uint64) {
+	post, ok := c.posts[id]
+	if !ok {
+		return
+	}
+	delete(c.posts, id)
+	c.order.remove(id)
+	c.untag(post)
+	c.releaseSlugs(post)
+}
+
+func (c *PostRepository) all() []model.Post {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	posts := make([]model.Post, 0, c.order.len())
+	c.order.each(func(id uint64) {
+		posts = append(posts, c.posts[id])
+	})
+	return posts
+}
+
+// upsert stores a post as it was logged or snapshotted, keeping the slug it
+// was stored with so that replaying does not hand out different slugs.
+func (c *PostRepository) upsert(post model.Post) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keep := c.keepsSlug(post)
+	slug := post.Slug
+	post = c.normalize(post)
+	if keep {
+		post.Slug = slug
+	}
+	c.place(post)
+}
+
+// prepareCreate returns the post as Create would store it, without storing
+// it.
+func (c *PostRepository) prepareCreate(post model.Post) (model.Post, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	post, err := c.allocate(post)
+	if err != nil {
+		return post, err
 	}
-	return nil, PostNotFoundError{id}
+	return c.normalize(post), nil
+}
+
+// prepareInsert returns the post as Insert would store it, without storing
+// it.
+func (c *PostRepository) prepareInsert(post model.Post) (model.Post, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.posts[post.Id]; ok {
+		return post, PostAlreadyExistsError{post.Id}
+	}
+	return c.normalize(post), nil
+}
+
+// prepareUpdate returns the post as Update would store it, without storing
+// it.
+func (c *PostRepository) prepareUpdate(post model.Post) (model.Post, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.posts[post.Id]; !ok {
+		return post, PostNotFoundError{post.Id}
+	}
+	return c.normalize(post), nil
+}
+
+func (c *PostRepository) discard(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(id)
+}
+
+func (c *PostRepository) lastAllocatedId() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastId
+}
+
+func (c *PostRepository) reserveUpTo(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reserve(id)
 }
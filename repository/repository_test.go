@@ -112,7 +112,7 @@ func TestCommentRepository_GetById(t *testing.T) {
 
 func TestPostRepository_Insert(t *testing.T) {
 	var (
-		post1 = model.Post{Id: 101, Title: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
+		post1 = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
 	)
 
 	t.Run("insert new Post", func(t *testing.T) {
@@ -134,9 +134,9 @@ func TestPostRepository_Insert(t *testing.T) {
 func TestPostRepository_GetById(t *testing.T) {
 	var (
 		NonExistentPostId = uint64(10101010)
-		post1             = model.Post{Id: 101, Title: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
-		post2             = model.Post{Id: 102, Title: "post2", Content: "content", CreationDate: time.Unix(10012, 0)}
-		post3             = model.Post{Id: 103, Title: "post3", Content: "content", CreationDate: time.Unix(10013, 0)}
+		post1             = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
+		post2             = model.Post{Id: 102, Title: "post2", Slug: "post2", Content: "content", CreationDate: time.Unix(10012, 0)}
+		post3             = model.Post{Id: 103, Title: "post3", Slug: "post3", Content: "content", CreationDate: time.Unix(10013, 0)}
 		posts             = []model.Post{post1, post2, post3}
 	)
 
@@ -166,3 +166,258 @@ func TestPostRepository_GetById(t *testing.T) {
 		})
 	}
 }
+
+func TestPostRepository_Update(t *testing.T) {
+	var (
+		post1   = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
+		updated = model.Post{Id: 101, Title: "updated", Slug: "updated", Content: "new content", CreationDate: time.Unix(10011, 0)}
+		missing = model.Post{Id: 10101010, Title: "missing"}
+	)
+
+	t.Run("update existing post", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1})
+		err := p.Update(updated)
+		require.NoError(t, err)
+		res, err := p.GetById(post1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &updated, res)
+	})
+
+	t.Run("post not found", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1})
+		err := p.Update(missing)
+		assert.ErrorIs(t, err, PostNotFoundError{missing.Id})
+	})
+}
+
+func TestPostRepository_PublishDue(t *testing.T) {
+	var now = time.Unix(10011, 0).UTC()
+	var past, future = now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name          string
+		post          model.Post
+		wantPublished bool
+	}{
+		{name: "due", post: model.Post{Id: 1, Title: "due", Status: model.StatusScheduled, PublishAt: &past}, wantPublished: true},
+		{name: "due now", post: model.Post{Id: 1, Title: "due", Status: model.StatusScheduled, PublishAt: &now}, wantPublished: true},
+		{name: "rescheduled", post: model.Post{Id: 1, Title: "later", Status: model.StatusScheduled, PublishAt: &future}},
+		{name: "back to draft", post: model.Post{Id: 1, Title: "draft", Status: model.StatusDraft, PublishAt: &past}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			p := CustomPostRepository([]model.Post{tt.post})
+
+			// WHEN
+			post, published, err := p.PublishDue(tt.post.Id, now)
+
+			// THEN
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPublished, published)
+			stored, err := p.GetById(tt.post.Id)
+			require.NoError(t, err)
+			assert.Equal(t, *stored, post)
+			assert.Equal(t, tt.post.Title, stored.Title)
+			assert.Equal(t, tt.wantPublished, stored.Published())
+		})
+	}
+
+	t.Run("post not found", func(t *testing.T) {
+		_, _, err := NewPostRepository().PublishDue(1, now)
+		assert.ErrorIs(t, err, PostNotFoundError{1})
+	})
+}
+
+func TestPostRepository_Delete(t *testing.T) {
+	var (
+		post1 = model.Post{Id: 101, Title: "post1", Slug: "post1", Content: "content", CreationDate: time.Unix(10011, 0)}
+		post2 = model.Post{Id: 102, Title: "post2", Slug: "post2", Content: "content", CreationDate: time.Unix(10012, 0)}
+	)
+
+	t.Run("delete existing post", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1, post2})
+		err := p.Delete(post1.Id)
+		require.NoError(t, err)
+		_, err = p.GetById(post1.Id)
+		assert.ErrorIs(t, err, PostNotFoundError{post1.Id})
+		res, err := p.GetById(post2.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &post2, res)
+	})
+
+	t.Run("post not found", func(t *testing.T) {
+		p := NewPostRepository()
+		err := p.Delete(post1.Id)
+		assert.ErrorIs(t, err, PostNotFoundError{post1.Id})
+	})
+}
+
+func TestCommentRepository_Update(t *testing.T) {
+	var (
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "author1", CreationDate: time.Unix(10011, 0)}
+		updated  = model.Comment{Id: 1, PostId: 101, Comment: "edited", Author: "author1", CreationDate: time.Unix(10011, 0)}
+	)
+
+	t.Run("update existing comment", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{comment1})
+		err := c.Update(updated)
+		require.NoError(t, err)
+		res, err := c.GetById(comment1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, &updated, res)
+	})
+
+	t.Run("comment not found", func(t *testing.T) {
+		c := NewCommentRepository()
+		err := c.Update(updated)
+		assert.ErrorIs(t, err, CommentNotFoundError{updated.Id})
+	})
+}
+
+func TestCommentRepository_Delete(t *testing.T) {
+	var (
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "author1", CreationDate: time.Unix(10011, 0)}
+		comment2 = model.Comment{Id: 2, PostId: 101, Comment: "comment2", Author: "author2", CreationDate: time.Unix(10012, 0)}
+	)
+
+	t.Run("delete existing comment", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{comment1, comment2})
+		err := c.Delete(comment1.Id)
+		require.NoError(t, err)
+		_, err = c.GetById(comment1.Id)
+		assert.ErrorIs(t, err, CommentNotFoundError{comment1.Id})
+		assert.ElementsMatch(t, []model.Comment{comment2}, c.GetAllByPostId(comment1.PostId))
+	})
+
+	t.Run("comment not found", func(t *testing.T) {
+		c := NewCommentRepository()
+		err := c.Delete(comment1.Id)
+		assert.ErrorIs(t, err, CommentNotFoundError{comment1.Id})
+	})
+}
+
+func TestCommentRepository_IndexConsistency(t *testing.T) {
+	var (
+		comment1 = model.Comment{Id: 3, PostId: 101, Comment: "first", Author: "author1", CreationDate: time.Unix(10011, 0)}
+		comment2 = model.Comment{Id: 1, PostId: 101, Comment: "second", Author: "author2", CreationDate: time.Unix(10012, 0)}
+		comment3 = model.Comment{Id: 2, PostId: 101, Comment: "third", Author: "author3", CreationDate: time.Unix(10013, 0)}
+	)
+
+	t.Run("listing keeps insertion order", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{comment1, comment2, comment3})
+		assert.Equal(t, []model.Comment{comment1, comment2, comment3}, c.GetAllByPostId(101))
+	})
+
+	t.Run("re-inserted comment goes to the end", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{comment1, comment2, comment3})
+		require.NoError(t, c.Delete(comment1.Id))
+		require.NoError(t, c.Insert(comment1))
+		assert.Equal(t, []model.Comment{comment2, comment3, comment1}, c.GetAllByPostId(101))
+	})
+
+	t.Run("update moves comment to another post", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{comment1, comment2})
+		moved := comment1
+		moved.PostId = 202
+		require.NoError(t, c.Update(moved))
+		assert.Equal(t, []model.Comment{comment2}, c.GetAllByPostId(101))
+		assert.Equal(t, []model.Comment{moved}, c.GetAllByPostId(202))
+	})
+
+	t.Run("many deletions compact the index", func(t *testing.T) {
+		c := NewCommentRepository()
+		for id := uint64(1); id <= 100; id++ {
+			require.NoError(t, c.Insert(model.Comment{Id: id, PostId: 7}))
+		}
+		for id := uint64(1); id < 100; id++ {
+			require.NoError(t, c.Delete(id))
+		}
+		assert.Equal(t, []model.Comment{{Id: 100, PostId: 7}}, c.GetAllByPostId(7))
+		assert.Len(t, c.GetAllByPostId(1), 0)
+	})
+}
+
+func TestPostRepository_Create(t *testing.T) {
+	var (
+		explicit = model.Post{Id: 101, Title: "imported", Slug: "imported", Content: "content", CreationDate: time.Unix(10011, 0)}
+		fresh    = model.Post{Title: "fresh", Content: "content"}
+	)
+
+	t.Run("allocates increasing ids", func(t *testing.T) {
+		p := NewPostRepository()
+		first, err := p.Create(fresh)
+		require.NoError(t, err)
+		second, err := p.Create(fresh)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), first.Id)
+		assert.Equal(t, uint64(2), second.Id)
+		assert.False(t, first.CreationDate.IsZero())
+	})
+
+	t.Run("honors explicit ids", func(t *testing.T) {
+		p := NewPostRepository()
+		created, err := p.Create(explicit)
+		require.NoError(t, err)
+		assert.Equal(t, explicit, created)
+		next, err := p.Create(fresh)
+		require.NoError(t, err)
+		assert.Equal(t, explicit.Id+1, next.Id)
+		_, err = p.Create(explicit)
+		assert.ErrorIs(t, err, PostAlreadyExistsError{explicit.Id})
+	})
+
+	t.Run("does not reuse deleted ids", func(t *testing.T) {
+		p := NewPostRepository()
+		created, err := p.Create(fresh)
+		require.NoError(t, err)
+		require.NoError(t, p.Delete(created.Id))
+		next, err := p.Create(fresh)
+		require.NoError(t, err)
+		assert.Equal(t, created.Id+1, next.Id)
+	})
+}
+
+func TestCommentRepository_Create(t *testing.T) {
+	var (
+		explicit = model.Comment{Id: 7, PostId: 101, Comment: "imported", Author: "author", CreationDate: time.Unix(10011, 0)}
+		fresh    = model.Comment{PostId: 101, Comment: "fresh", Author: "author"}
+	)
+
+	t.Run("allocates increasing ids", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{explicit})
+		created, err := c.Create(fresh)
+		require.NoError(t, err)
+		assert.Equal(t, explicit.Id+1, created.Id)
+		assert.False(t, created.CreationDate.IsZero())
+		assert.Equal(t, []model.Comment{explicit, created}, c.GetAllByPostId(fresh.PostId))
+	})
+
+	t.Run("rejects duplicate explicit ids", func(t *testing.T) {
+		c := CustomCommentRepository([]model.Comment{explicit})
+		_, err := c.Create(explicit)
+		assert.ErrorIs(t, err, CommentAlreadyExistsError{explicit.Id})
+	})
+}
+
+func TestCommentRepository_DeleteAllByPostId(t *testing.T) {
+	var (
+		comment1 = model.Comment{Id: 1, PostId: 101, Comment: "comment1", Author: "author1", CreationDate: time.Unix(10011, 0)}
+		comment2 = model.Comment{Id: 2, PostId: 101, Comment: "comment2", Author: "author2", CreationDate: time.Unix(10012, 0)}
+		comment3 = model.Comment{Id: 3, PostId: 100, Comment: "comment3", Author: "author3", CreationDate: time.Unix(10013, 0)}
+	)
+
+	c := CustomCommentRepository([]model.Comment{comment1, comment2, comment3})
+	removed, err := c.DeleteAllByPostId(101)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Empty(t, c.GetAllByPostId(101))
+	_, err = c.GetById(comment1.Id)
+	assert.ErrorIs(t, err, CommentNotFoundError{comment1.Id})
+	assert.Equal(t, []model.Comment{comment3}, c.GetAllByPostId(100))
+
+	removed, err = c.DeleteAllByPostId(101)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// RevisionRepository keeps the revisions of every post in memory, in the
+// order they were added.
+type RevisionRepository struct {
+	mu     sync.RWMutex
+	byPost map[uint64][]model.Revision
+}
+
+func NewRevisionRepository() *RevisionRepository {
+	return &RevisionRepository{byPost: make(map[uint64][]model.Revision)}
+}
+
+type RevisionNotFoundError struct {
+	postId uint64
+	number int
+}
+
+func (e RevisionNotFoundError) Error() string {
+	return fmt.Sprintf("Error: Revision: %v of post with id: %v was not found in the repository!", e.number, e.postId)
+}
+
+func (e RevisionNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Add stores the revision as the latest one of its post, numbering it and
+// stamping CreatedAt with the current time when it is unset.
+func (c *RevisionRepository) Add(revision model.Revision) (model.Revision, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	revision = c.allocate(revision)
+	c.put(revision)
+	return revision, nil
+}
+
+// allocate fills in the number and creation time Add stores the revision
+// with. Callers must hold the lock.
+func (c *RevisionRepository) allocate(revision model.Revision) model.Revision {
+	revision.Number = len(c.byPost[revision.PostId]) + 1
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now().UTC()
+	}
+	return revision
+}
+
+// prepareAdd returns the revision as Add would store it, without storing it.
+func (c *RevisionRepository) prepareAdd(revision model.Revision) model.Revision {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.allocate(revision)
+}
+
+func (c *RevisionRepository) Get(postId uint64, number int) (*model.Revision, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	revisions := c.byPost[postId]
+	if number < 1 || number > len(revisions) {
+		return nil, RevisionNotFoundError{postId, number}
+	}
+	revision := revisions[number-1]
+	return &revision, nil
+}
+
+// GetAllByPostId returns the revisions of the post, oldest first.
+func (c *RevisionRepository) GetAllByPostId(id uint64) []model.Revision {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]model.Revision{}, c.byPost[id]...)
+}
+
+func (c *RevisionRepository) DeleteAllByPostId(id uint64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.removeAllByPostId(id), nil
+}
+
+// put stores the revision under its number, replacing a revision already
+// stored under it, so replaying a revision is harmless. Callers must hold the
+// write lock.
+func (c *RevisionRepository) put(revision model.Revision) {
+	revisions := c.byPost[revision.PostId]
+	if revision.Number <= len(revisions) {
+		revisions[revision.Number-1] = revision
+		return
+	}
+	c.byPost[revision.PostId] = append(revisions, revision)
+}
+
+func (c *RevisionRepository) removeAllByPostId(id uint64) int {
+	removed := len(c.byPost[id])
+	delete(c.byPost, id)
+	return removed
+}
+
+// all returns every revision ordered by post id and revision number.
+func (c *RevisionRepository) all() []model.Revision {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	postIds := make([]uint64, 0, len(c.byPost))
+	for id := range c.byPost {
+		postIds = append(postIds, id)
+	}
+	sort.Slice(postIds, func(i, j int) bool { return postIds[i] < postIds[j] })
+	var revisions []model.Revision
+	for _, id := range postIds {
+		revisions = append(revisions, c.byPost[id]...)
+	}
+	return revisions
+}
+
+func (c *RevisionRepository) upsert(revision model.Revision) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(revision)
+}
+
+func (c *RevisionRepository) discardAllByPostId(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.removeAllByPostId(id)
+}
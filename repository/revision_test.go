@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestRevisionRepository(t *testing.T) {
+	var (
+		createdAt = time.Unix(10011, 0).UTC()
+		rev1      = model.Revision{PostId: 1, Author: "author1", CreatedAt: createdAt, Title: "post1", Content: "content"}
+		rev2      = model.Revision{PostId: 1, Author: "author2", Title: "post1", Content: "new content"}
+		rev3      = model.Revision{PostId: 2, Author: "author1", CreatedAt: createdAt, Title: "post2", Content: "content"}
+	)
+
+	// GIVEN
+	c := NewRevisionRepository()
+
+	// WHEN
+	added1, err := c.Add(rev1)
+	require.NoError(t, err)
+	added2, err := c.Add(rev2)
+	require.NoError(t, err)
+	added3, err := c.Add(rev3)
+	require.NoError(t, err)
+
+	// THEN
+	assert.Equal(t, 1, added1.Number)
+	assert.Equal(t, createdAt, added1.CreatedAt)
+	assert.Equal(t, 2, added2.Number)
+	assert.False(t, added2.CreatedAt.IsZero())
+	assert.Equal(t, 1, added3.Number)
+	assert.Equal(t, []model.Revision{added1, added2}, c.GetAllByPostId(1))
+
+	got, err := c.Get(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, &added2, got)
+	for _, number := range []int{0, 3} {
+		_, err = c.Get(1, number)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, err, RevisionNotFoundError{1, number})
+	}
+
+	// returned revisions are copies
+	c.GetAllByPostId(1)[0].Title = "changed"
+	got, err = c.Get(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "post1", got.Title)
+
+	removed, err := c.DeleteAllByPostId(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Empty(t, c.GetAllByPostId(1))
+	assert.Equal(t, []model.Revision{added3}, c.GetAllByPostId(2))
+}
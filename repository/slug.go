@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// defaultSlug is used for titles without a single transliterable character.
+const defaultSlug = "post"
+
+// transliterations spells letters outside of ASCII with ASCII letters.
+// Letters missing from the table are dropped from slugs.
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g", 'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k", 'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o", 'œ': "oe",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r", 'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s", 'ß': "ss",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i",
+	'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s",
+	'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+}
+
+// Slugify turns a title into a URL path segment of lower case ASCII letters
+// and digits separated by single hyphens, transliterating other letters
+// where possible.
+func Slugify(title string) string {
+	var sb strings.Builder
+	pendingHyphen := false
+	write := func(s string) {
+		if s == "" {
+			return
+		}
+		if pendingHyphen && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		pendingHyphen = false
+		sb.WriteString(s)
+	}
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			write(string(r))
+		case r == '\'' || r == '’':
+			// Apostrophes are dropped so "Don't" becomes "dont".
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			write(transliterations[r])
+		default:
+			pendingHyphen = true
+		}
+	}
+	return sb.String()
+}
+
+type SlugNotFoundError struct {
+	slug string
+}
+
+func (e SlugNotFoundError) Error() string {
+	return fmt.Sprintf("Error: Post with slug: %v was not found in the repository!", e.slug)
+}
+
+func (e SlugNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// GetBySlug finds the post by its current slug or by one it had before its
+// title was changed. Callers can tell the two apart by comparing the slug of
+// the returned post.
+func (c *PostRepository) GetBySlug(slug string) (*model.Post, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.bySlug[slug]
+	if !ok {
+		id, ok = c.redirects[slug]
+	}
+	if !ok {
+		return nil, SlugNotFoundError{slug}
+	}
+	post := c.posts[id]
+	return &post, nil
+}
+
+// pickSlug picks the slug of the post about to be stored: the slug it was
+// stored with while its title is unchanged, otherwise one generated from the
+// title. New posts keep a slug they already carry when it is well formed and
+// free, so restoring a snapshot reproduces the slugs handed out before.
+// Callers must hold the lock.
+func (c *PostRepository) pickSlug(post model.Post) string {
+	old, exists := c.posts[post.Id]
+	if exists && old.Title == post.Title && old.Slug != "" {
+		return old.Slug
+	}
+	if !exists && c.keepsSlug(post) {
+		return post.Slug
+	}
+	return c.uniqueSlug(slugBase(post.Title), post.Id)
+}
+
+// keepsSlug reports whether the slug the post carries is well formed and
+// free for it.
+func (c *PostRepository) keepsSlug(post model.Post) bool {
+	return post.Slug != "" && Slugify(post.Slug) == post.Slug && c.slugFree(post.Slug, post.Id)
+}
+
+// claimSlug makes the slug of the post point to it. A replaced slug keeps
+// redirecting to the post, and a numeric suffix is never handed out again
+// for the same title. Callers must hold the write lock.
+func (c *PostRepository) claimSlug(post model.Post) {
+	if old, ok := c.posts[post.Id]; ok && old.Slug != "" && old.Slug != post.Slug {
+		delete(c.bySlug, old.Slug)
+		c.redirects[old.Slug] = post.Id
+	}
+	delete(c.redirects, post.Slug)
+	c.bySlug[post.Slug] = post.Id
+	base := slugBase(post.Title)
+	if suffix := strings.TrimPrefix(post.Slug, base+"-"); suffix != post.Slug {
+		if n, err := strconv.Atoi(suffix); err == nil && n >= c.nextSuffix[base] {
+			c.nextSuffix[base] = n + 1
+		}
+	}
+}
+
+// uniqueSlug returns the base itself when it is free for the post, otherwise
+// the base with a numeric suffix. Suffixes count up from 2 per base so that
+// duplicate titles do not scan every suffix handed out before; a suffix
+// released by a deletion is therefore not reused.
+func (c *PostRepository) uniqueSlug(base string, id uint64) string {
+	if c.slugFree(base, id) {
+		return base
+	}
+	n := c.nextSuffix[base]
+	if n < 2 {
+		n = 2
+	}
+	slug := base + "-" + strconv.Itoa(n)
+	for !c.slugFree(slug, id) {
+		n++
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+func slugBase(title string) string {
+	if base := Slugify(title); base != "" {
+		return base
+	}
+	return defaultSlug
+}
+
+// slugFree reports whether no other post uses the slug, currently or as a
+// redirect.
+func (c *PostRepository) slugFree(slug string, id uint64) bool {
+	if owner, ok := c.bySlug[slug]; ok && owner != id {
+		return false
+	}
+	if owner, ok := c.redirects[slug]; ok && owner != id {
+		return false
+	}
+	return true
+}
+
+// releaseSlugs forgets the slug of a removed post and every slug
+// redirecting to it. Callers must hold the write lock.
+func (c *PostRepository) releaseSlugs(post model.Post) {
+	delete(c.bySlug, post.Slug)
+	for slug, id := range c.redirects {
+		if id == post.Id {
+			delete(c.redirects, slug)
+		}
+	}
+}
+
+func (c *PostRepository) slugRedirects() map[string]uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	redirects := make(map[string]uint64, len(c.redirects))
+	for slug, id := range c.redirects {
+		redirects[slug] = id
+	}
+	return redirects
+}
+
+func (c *PostRepository) slugSuffixes() map[string]int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	suffixes := make(map[string]int, len(c.nextSuffix))
+	for base, n := range c.nextSuffix {
+		suffixes[base] = n
+	}
+	return suffixes
+}
+
+// restoreSuffixes raises the next suffix of every base to at least the
+// stored one, which also counts suffixes of posts deleted since.
+func (c *PostRepository) restoreSuffixes(suffixes map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for base, n := range suffixes {
+		if n > c.nextSuffix[base] {
+			c.nextSuffix[base] = n
+		}
+	}
+}
+
+func (c *PostRepository) restoreRedirects(redirects map[string]uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for slug, id := range redirects {
+		if _, ok := c.posts[id]; ok && c.slugFree(slug, id) {
+			c.redirects[slug] = id
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title    string
+		expected string
+	}{
+		{title: "Hello, World!", expected: "hello-world"},
+		{title: "  Go 1.17 -- what's new?  ", expected: "go-1-17-whats-new"},
+		{title: "Crème brûlée à la française", expected: "creme-brulee-a-la-francaise"},
+		{title: "Straße über Ærø", expected: "strasse-uber-aero"},
+		{title: "Привет, мир", expected: "privet-mir"},
+		{title: "Καλημέρα", expected: "kalimera"},
+		{title: "東京 2020", expected: "2020"},
+		{title: "東京", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Slugify(tt.title))
+		})
+	}
+}
+
+func TestPostRepository_Slugs(t *testing.T) {
+	var (
+		post1 = model.Post{Id: 1, Title: "Hello World", CreationDate: time.Unix(10011, 0)}
+		post2 = model.Post{Id: 2, Title: "Hello, world!", CreationDate: time.Unix(10012, 0)}
+		post3 = model.Post{Id: 3, Title: "東京", CreationDate: time.Unix(10013, 0)}
+	)
+
+	t.Run("duplicates get a numeric suffix", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1, post2, post3})
+		assert.Equal(t, []string{"hello-world", "hello-world-2", "post"}, postSlugs(p.all()))
+	})
+
+	t.Run("duplicates count up from the last suffix", func(t *testing.T) {
+		p := NewPostRepository()
+		for id := uint64(1); id <= 4; id++ {
+			require.NoError(t, p.Insert(model.Post{Id: id, Title: "Hello World"}))
+		}
+		require.NoError(t, p.Delete(2))
+		require.NoError(t, p.Insert(model.Post{Id: 5, Title: "Hello World"}))
+		// a post titled like a suffixed slug takes the next free suffix
+		require.NoError(t, p.Insert(model.Post{Id: 6, Title: "Hello World 6"}))
+		require.NoError(t, p.Insert(model.Post{Id: 7, Title: "Hello World"}))
+		assert.Equal(t, []string{"hello-world", "hello-world-3", "hello-world-4", "hello-world-5", "hello-world-6", "hello-world-7"}, postSlugs(p.all()))
+		assert.Equal(t, 8, p.nextSuffix["hello-world"])
+	})
+
+	t.Run("title change redirects the former slug", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1})
+		renamed := post1
+		renamed.Title = "Goodbye World"
+		require.NoError(t, p.Update(renamed))
+
+		post, err := p.GetBySlug("goodbye-world")
+		require.NoError(t, err)
+		assert.Equal(t, "goodbye-world", post.Slug)
+		post, err = p.GetBySlug("hello-world")
+		require.NoError(t, err)
+		assert.Equal(t, "goodbye-world", post.Slug)
+
+		created, err := p.Create(post2)
+		require.NoError(t, err)
+		assert.Equal(t, "hello-world-2", created.Slug)
+
+		require.NoError(t, p.Update(post1))
+		post, err = p.GetById(post1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "hello-world", post.Slug)
+	})
+
+	t.Run("unchanged title keeps the slug", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1})
+		edited := post1
+		edited.Slug = "something-else"
+		edited.Content = "edited"
+		require.NoError(t, p.Update(edited))
+		post, err := p.GetById(post1.Id)
+		require.NoError(t, err)
+		assert.Equal(t, "hello-world", post.Slug)
+	})
+
+	t.Run("deletion releases slugs", func(t *testing.T) {
+		p := CustomPostRepository([]model.Post{post1})
+		renamed := post1
+		renamed.Title = "Goodbye World"
+		require.NoError(t, p.Update(renamed))
+		require.NoError(t, p.Delete(post1.Id))
+
+		_, err := p.GetBySlug("hello-world")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = p.GetBySlug("goodbye-world")
+		assert.ErrorIs(t, err, ErrNotFound)
+		created, err := p.Create(post2)
+		require.NoError(t, err)
+		assert.Equal(t, "hello-world", created.Slug)
+	})
+
+	t.Run("stored slugs are kept", func(t *testing.T) {
+		imported := post1
+		imported.Slug = "hello-world-7"
+		invalid := post2
+		invalid.Slug = "Not A Slug"
+		p := CustomPostRepository([]model.Post{imported, invalid})
+		assert.Equal(t, []string{"hello-world-7", "hello-world"}, postSlugs(p.all()))
+	})
+}
+
+func postSlugs(posts []model.Post) []string {
+	slugs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		slugs = append(slugs, post.Slug)
+	}
+	return slugs
+}
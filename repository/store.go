@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// PostStore is implemented by every backend able to persist blog posts.
+type PostStore interface {
+	Insert(post model.Post) error
+	Create(post model.Post) (model.Post, error)
+	GetById(id uint64) (*model.Post, error)
+	// GetBySlug finds a post by its current slug or a slug it had before.
+	GetBySlug(slug string) (*model.Post, error)
+	Query(q PostQuery) (PostPage, error)
+	// Tags lists every tag in use with the number of posts carrying it,
+	// only counting posts with the given status unless it is empty, and
+	// those owned by a non-zero anyStatusOwner.
+	Tags(status string, anyStatusOwner uint64) []TagCount
+	Update(post model.Post) error
+	// PublishDue publishes the post if it is still scheduled and due at now,
+	// without touching anything else, and reports whether it did.
+	PublishDue(id uint64, now time.Time) (model.Post, bool, error)
+	Delete(id uint64) error
+}
+
+// CommentStore is implemented by every backend able to persist post comments.
+type CommentStore interface {
+	Insert(comment model.Comment) error
+	Create(comment model.Comment) (model.Comment, error)
+	GetById(id uint64) (*model.Comment, error)
+	GetAllByPostId(id uint64) []model.Comment
+	Query(postId uint64, q CommentQuery) (CommentPage, error)
+	Update(comment model.Comment) error
+	Delete(id uint64) error
+	// DeleteAllByPostId removes every comment of the post and reports how many were removed.
+	DeleteAllByPostId(id uint64) (int, error)
+}
+
+// RevisionStore is implemented by every backend able to persist post revisions.
+type RevisionStore interface {
+	// Add stores the revision as the latest one of its post and returns it numbered.
+	Add(revision model.Revision) (model.Revision, error)
+	Get(postId uint64, number int) (*model.Revision, error)
+	GetAllByPostId(id uint64) []model.Revision
+	DeleteAllByPostId(id uint64) (int, error)
+}
+
+// UserStore is implemented by every backend able to persist user accounts.
+type UserStore interface {
+	// Create stores the user under the next free id and returns it.
+	Create(user model.User) (model.User, error)
+	GetById(id uint64) (*model.User, error)
+	GetByUsername(username string) (*model.User, error)
+	Update(user model.User) error
+}
+
+// ApiKeyStore is implemented by every backend able to persist API keys.
+type ApiKeyStore interface {
+	// Create stores the key under the next free id and returns it.
+	Create(key model.ApiKey) (model.ApiKey, error)
+	GetById(id uint64) (*model.ApiKey, error)
+	GetByHash(hash string) (*model.ApiKey, error)
+	GetAllByUserId(userId uint64) []model.ApiKey
+	Update(key model.ApiKey) error
+	// Touch sets when the key was last used without touching anything else.
+	Touch(id uint64, at time.Time) error
+}
+
+var (
+	_ PostStore     = (*PostRepository)(nil)
+	_ CommentStore  = (*CommentRepository)(nil)
+	_ RevisionStore = (*RevisionRepository)(nil)
+	_ UserStore     = (*UserRepository)(nil)
+	_ ApiKeyStore   = (*ApiKeyRepository)(nil)
+)
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag folds the case of tag and turns it into a slug where every
+// run of characters other than letters and digits becomes a single hyphen,
+// so "Go  Lang!" and "go-lang" name the same tag.
+func NormalizeTag(tag string) string {
+	var b strings.Builder
+	separate := false
+	for _, r := range strings.ToLower(tag) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separate = true
+			continue
+		}
+		if separate && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		separate = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// NormalizeTags normalizes every tag, dropping the ones left empty and
+// duplicates while keeping the order of the first occurrences.
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Tags lists every tag in use together with the number of posts carrying
+// it, ordered by tag. A non-empty status only counts posts with that status
+// and those owned by a non-zero anyStatusOwner.
+func (c *PostRepository) Tags(status string, anyStatusOwner uint64) []TagCount {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tags := make([]TagCount, 0, len(c.byTag))
+	for tag, ids := range c.byTag {
+		count := ids.len()
+		if status != "" {
+			count = 0
+			ids.each(func(id uint64) {
+				if matchesStatus(c.posts[id], status, anyStatusOwner) {
+					count++
+				}
+			})
+		}
+		if count > 0 {
+			tags = append(tags, TagCount{Tag: tag, Count: count})
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Tag < tags[j].Tag
+	})
+	return tags
+}
+
+func (c *PostRepository) allTagged(tag string) []model.Post {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	ids, ok := c.byTag[tag]
+	if !ok {
+		return nil
+	}
+	posts := make([]model.Post, 0, ids.len())
+	ids.each(func(id uint64) {
+		posts = append(posts, c.posts[id])
+	})
+	return posts
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected string
+	}{
+		{tag: "golang", expected: "golang"},
+		{tag: "GoLang", expected: "golang"},
+		{tag: "  Go  Lang! ", expected: "go-lang"},
+		{tag: "c++", expected: "c"},
+		{tag: "Ünïcode_Tags", expected: "ünïcode-tags"},
+		{tag: "2024 recap", expected: "2024-recap"},
+		{tag: "--", expected: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NormalizeTag(tt.tag))
+		})
+	}
+
+	assert.Equal(t, []string{"go", "rest-api"}, NormalizeTags([]string{"Go", "REST api", "go", "!!"}))
+	assert.Nil(t, NormalizeTags(nil))
+}
+
+func TestPostRepository_Tags(t *testing.T) {
+	var (
+		post1 = model.Post{Id: 1, Title: "post1", Tags: []string{"Go", "REST"}, Category: "Back End", CreationDate: time.Unix(10011, 0)}
+		post2 = model.Post{Id: 2, Title: "post2", Tags: []string{"go"}, Category: "back-end", CreationDate: time.Unix(10012, 0)}
+		post3 = model.Post{Id: 3, Title: "post3", Tags: []string{"Testing"}, Category: "Front End", CreationDate: time.Unix(10013, 0)}
+	)
+
+	c := CustomPostRepository([]model.Post{post1, post2, post3})
+	stored, err := c.GetById(post1.Id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "rest"}, stored.Tags)
+	assert.Equal(t, "back-end", stored.Category)
+	assert.Equal(t, []TagCount{{Tag: "go", Count: 2}, {Tag: "rest", Count: 1}, {Tag: "testing", Count: 1}}, c.Tags("", 0))
+
+	page, err := c.Query(PostQuery{Tag: "GO", Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 1}, postIds(page.Items))
+	page, err = c.Query(PostQuery{Category: "BACK END"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, postIds(page.Items))
+	page, err = c.Query(PostQuery{Tag: "go", Category: "front-end"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	post2.Tags = []string{"testing"}
+	require.NoError(t, c.Update(post2))
+	require.NoError(t, c.Delete(post1.Id))
+	assert.Equal(t, []TagCount{{Tag: "testing", Count: 2}}, c.Tags("", 0))
+	page, err = c.Query(PostQuery{Tag: "go"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	assert.Zero(t, page.Total)
+}
+
+func TestFileStore_Tags(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenFileStore(dir, 0)
+	require.NoError(t, err)
+	created, err := s.Posts().Create(model.Post{Title: "tagged", Tags: []string{"Go Lang"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go-lang"}, created.Tags)
+
+	s = reopenFileStore(t, s, dir)
+
+	assert.Equal(t, []TagCount{{Tag: "go-lang", Count: 1}}, s.Posts().Tags("", 0))
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"sort"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// CommentNode is a comment together with the replies to it.
+type CommentNode struct {
+	model.Comment
+	Replies []CommentNode `json:",omitempty"`
+}
+
+// ThreadedComment is a comment annotated with its nesting depth, zero for
+// top-level comments.
+type ThreadedComment struct {
+	model.Comment
+	Depth int
+}
+
+// BuildThread arranges the comments of a post into trees of replies. Siblings
+// are ordered by creation date. A comment whose parent is not among the
+// given comments is treated as a top-level comment.
+func BuildThread(comments []model.Comment, descending bool) []CommentNode {
+	byId := make(map[uint64]bool, len(comments))
+	for _, comment := range comments {
+		byId[comment.Id] = true
+	}
+	children := make(map[uint64][]model.Comment)
+	for _, comment := range comments {
+		parent := comment.ParentId
+		if !byId[parent] || parent == comment.Id {
+			parent = 0
+		}
+		children[parent] = append(children[parent], comment)
+	}
+
+	var build func(parent uint64) []CommentNode
+	build = func(parent uint64) []CommentNode {
+		siblings := children[parent]
+		if len(siblings) == 0 {
+			return nil
+		}
+		sortComments(siblings, descending)
+		nodes := make([]CommentNode, 0, len(siblings))
+		for _, comment := range siblings {
+			nodes = append(nodes, CommentNode{Comment: comment, Replies: build(comment.Id)})
+		}
+		return nodes
+	}
+	roots := build(0)
+	if roots == nil {
+		roots = []CommentNode{}
+	}
+	return roots
+}
+
+// FlattenThread lists the comments of the trees depth first, so every reply
+// directly follows its parent or a preceding sibling's replies.
+func FlattenThread(nodes []CommentNode) []ThreadedComment {
+	flat := []ThreadedComment{}
+	var walk func(nodes []CommentNode, depth int)
+	walk = func(nodes []CommentNode, depth int) {
+		for _, node := range nodes {
+			flat = append(flat, ThreadedComment{Comment: node.Comment, Depth: depth})
+			walk(node.Replies, depth+1)
+		}
+	}
+	walk(nodes, 0)
+	return flat
+}
+
+func sortComments(comments []model.Comment, descending bool) {
+	sort.Slice(comments, func(i, j int) bool {
+		a := cursorEntry{key: timeSortKey(comments[i].CreationDate), id: comments[i].Id}
+		b := cursorEntry{key: timeSortKey(comments[j].CreationDate), id: comments[j].Id}
+		if descending {
+			return a.compare(b) > 0
+		}
+		return a.compare(b) < 0
+	})
+}
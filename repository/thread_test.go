@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestBuildThread(t *testing.T) {
+	var (
+		root1   = model.Comment{Id: 1, PostId: 101, Comment: "root1", CreationDate: time.Unix(10011, 0)}
+		root2   = model.Comment{Id: 2, PostId: 101, Comment: "root2", CreationDate: time.Unix(10012, 0)}
+		reply1  = model.Comment{Id: 3, PostId: 101, ParentId: 1, Comment: "reply1", CreationDate: time.Unix(10015, 0)}
+		reply2  = model.Comment{Id: 4, PostId: 101, ParentId: 1, Comment: "reply2", CreationDate: time.Unix(10013, 0)}
+		nested  = model.Comment{Id: 5, PostId: 101, ParentId: 3, Comment: "nested", CreationDate: time.Unix(10016, 0)}
+		orphan  = model.Comment{Id: 6, PostId: 101, ParentId: 99, Comment: "orphan", CreationDate: time.Unix(10014, 0)}
+		content = []model.Comment{nested, reply1, root2, orphan, reply2, root1}
+	)
+
+	t.Run("ascending tree", func(t *testing.T) {
+		assert.Equal(t, []CommentNode{
+			{Comment: root1, Replies: []CommentNode{
+				{Comment: reply2},
+				{Comment: reply1, Replies: []CommentNode{{Comment: nested}}},
+			}},
+			{Comment: root2},
+			{Comment: orphan},
+		}, BuildThread(content, false))
+	})
+
+	t.Run("descending tree", func(t *testing.T) {
+		assert.Equal(t, []CommentNode{
+			{Comment: orphan},
+			{Comment: root2},
+			{Comment: root1, Replies: []CommentNode{
+				{Comment: reply1, Replies: []CommentNode{{Comment: nested}}},
+				{Comment: reply2},
+			}},
+		}, BuildThread(content, true))
+	})
+
+	t.Run("flattened depth first", func(t *testing.T) {
+		assert.Equal(t, []ThreadedComment{
+			{Comment: root1, Depth: 0},
+			{Comment: reply2, Depth: 1},
+			{Comment: reply1, Depth: 1},
+			{Comment: nested, Depth: 2},
+			{Comment: root2, Depth: 0},
+			{Comment: orphan, Depth: 0},
+		}, FlattenThread(BuildThread(content, false)))
+	})
+
+	t.Run("no comments", func(t *testing.T) {
+		assert.Equal(t, []CommentNode{}, BuildThread(nil, false))
+		assert.Equal(t, []ThreadedComment{}, FlattenThread(nil))
+	})
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// UserRepository keeps user accounts in memory, indexed by id and by their
+// username ignoring case.
+type UserRepository struct {
+	mu         sync.RWMutex
+	users      map[uint64]model.User
+	byUsername map[string]uint64
+	lastId     uint64
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[uint64]model.User), byUsername: make(map[string]uint64)}
+}
+
+type UserNotFoundError struct {
+	id       uint64
+	username string
+}
+
+func (e UserNotFoundError) Error() string {
+	if e.username != "" {
+		return fmt.Sprintf("Error: User: %q was not found in the repository!", e.username)
+	}
+	return fmt.Sprintf("Error: User with id: %v was not found in the repository!", e.id)
+}
+
+func (e UserNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+type UserAlreadyExistsError struct {
+	username string
+}
+
+func (e UserAlreadyExistsError) Error() string {
+	return fmt.Sprintf("Error: User: %q already exists in the repository!", e.username)
+}
+
+func (e UserAlreadyExistsError) Is(target error) bool {
+	return target == ErrAlreadyExists
+}
+
+// Create stores a new user under the next free id, stamping CreationDate with
+// the current time when it is unset.
+func (c *UserRepository) Create(user model.User) (model.User, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	user, err := c.allocate(user)
+	if err != nil {
+		return user, err
+	}
+	c.put(user)
+	return user, nil
+}
+
+// allocate fills in the id and creation date Create stores the user with.
+// Callers must hold the lock.
+func (c *UserRepository) allocate(user model.User) (model.User, error) {
+	if _, taken := c.byUsername[usernameKey(user.Username)]; taken {
+		return user, UserAlreadyExistsError{user.Username}
+	}
+	user.Id = c.lastId + 1
+	if user.CreationDate.IsZero() {
+		user.CreationDate = time.Now().UTC()
+	}
+	return user, nil
+}
+
+// prepareCreate returns the user as Create would store it, without storing
+// it.
+func (c *UserRepository) prepareCreate(user model.User) (model.User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.allocate(user)
+}
+
+func (c *UserRepository) GetById(id uint64) (*model.User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	user, ok := c.users[id]
+	if !ok {
+		return nil, UserNotFoundError{id: id}
+	}
+	return &user, nil
+}
+
+// Update replaces the stored user with the same id. The username may change
+// as long as no other user has it.
+func (c *UserRepository) Update(user model.User) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.users[user.Id]; !ok {
+		return UserNotFoundError{id: user.Id}
+	}
+	if id, taken := c.byUsername[usernameKey(user.Username)]; taken && id != user.Id {
+		return UserAlreadyExistsError{user.Username}
+	}
+	c.put(user)
+	return nil
+}
+
+// GetByUsername finds a user by username ignoring case.
+func (c *UserRepository) GetByUsername(username string) (*model.User, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, ok := c.byUsername[usernameKey(username)]
+	if !ok {
+		return nil, UserNotFoundError{username: username}
+	}
+	user := c.users[id]
+	return &user, nil
+}
+
+// put stores the user, replacing a user stored under the same id. Callers
+// must hold the write lock.
+func (c *UserRepository) put(user model.User) {
+	if old, ok := c.users[user.Id]; ok {
+		delete(c.byUsername, usernameKey(old.Username))
+	}
+	c.users[user.Id] = user
+	c.byUsername[usernameKey(user.Username)] = user.Id
+	if user.Id > c.lastId {
+		c.lastId = user.Id
+	}
+}
+
+// all returns every user ordered by id.
+func (c *UserRepository) all() []model.User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make([]model.User, 0, len(c.users))
+	for _, user := range c.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Id < users[j].Id })
+	return users
+}
+
+func (c *UserRepository) upsert(user model.User) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.put(user)
+}
+
+func (c *UserRepository) reserveUpTo(id uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id > c.lastId {
+		c.lastId = id
+	}
+}
+
+func (c *UserRepository) lastAllocatedId() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastId
+}
+
+func usernameKey(username string) string {
+	return strings.ToLower(username)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestUserRepository(t *testing.T) {
+	createdAt := time.Unix(10011, 0).UTC()
+
+	// GIVEN
+	c := NewUserRepository()
+
+	// WHEN
+	alice, err := c.Create(model.User{Username: "alice", PasswordHash: "hash1", CreationDate: createdAt})
+	require.NoError(t, err)
+	bob, err := c.Create(model.User{Id: 7, Username: "Bob", PasswordHash: "hash2"})
+	require.NoError(t, err)
+
+	// THEN
+	assert.Equal(t, uint64(1), alice.Id)
+	assert.Equal(t, createdAt, alice.CreationDate)
+	assert.Equal(t, uint64(2), bob.Id)
+	assert.False(t, bob.CreationDate.IsZero())
+
+	got, err := c.GetById(2)
+	require.NoError(t, err)
+	assert.Equal(t, &bob, got)
+	got, err = c.GetByUsername("bob")
+	require.NoError(t, err)
+	assert.Equal(t, &bob, got)
+
+	_, err = c.GetById(3)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = c.GetByUsername("carol")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = c.Create(model.User{Username: "ALICE", PasswordHash: "hash3"})
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.ErrorIs(t, err, UserAlreadyExistsError{"ALICE"})
+
+	bob.Role = model.RoleEditor
+	require.NoError(t, c.Update(bob))
+	got, err = c.GetById(bob.Id)
+	require.NoError(t, err)
+	assert.Equal(t, model.RoleEditor, got.Role)
+	bob.Username = "alice"
+	assert.ErrorIs(t, c.Update(bob), ErrAlreadyExists)
+	assert.ErrorIs(t, c.Update(model.User{Id: 3, Username: "carol"}), ErrNotFound)
+}
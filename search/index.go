@@ -0,0 +1,351 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+// Indexed fields.
+const (
+	FieldTitle   = "title"
+	FieldContent = "content"
+	FieldComment = "comment"
+)
+
+// DefaultTitleBoost weighs title matches against content and comment matches.
+const DefaultTitleBoost = 2.0
+
+// BM25 parameters.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type docKey struct {
+	kind string
+	id   uint64
+}
+
+type document struct {
+	key    docKey
+	postId uint64
+	title  string
+	fields map[string]string
+	length map[string]int
+}
+
+// Index is an in-memory inverted index of posts and comments ranking
+// matches with BM25, computed per field and weighted by field boosts.
+type Index struct {
+	mu     sync.RWMutex
+	boosts map[string]float64
+	docs   map[docKey]*document
+	// hidden holds the ids of unpublished posts, whose comments are left out
+	// of the results.
+	hidden map[uint64]bool
+	// postings maps a term to the positions it occurs at, per document and field.
+	postings map[string]map[docKey]map[string][]int
+	// fieldLength and fieldDocs hold the number of tokens and of documents
+	// per field, from which the average field length is derived.
+	fieldLength map[string]int
+	fieldDocs   map[string]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		boosts:      map[string]float64{FieldTitle: DefaultTitleBoost, FieldContent: 1, FieldComment: 1},
+		docs:        make(map[docKey]*document),
+		hidden:      make(map[uint64]bool),
+		postings:    make(map[string]map[docKey]map[string][]int),
+		fieldLength: make(map[string]int),
+		fieldDocs:   make(map[string]int),
+	}
+}
+
+// SetBoost changes the weight of matches in the given field.
+func (idx *Index) SetBoost(field string, boost float64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.boosts[field] = boost
+}
+
+// IndexPost indexes the post, or removes it from the index while it is not
+// published.
+func (idx *Index) IndexPost(post model.Post) {
+	if !post.Published() {
+		idx.mu.Lock()
+		defer idx.mu.Unlock()
+		idx.remove(docKey{repository.PostKind, post.Id})
+		idx.hidden[post.Id] = true
+		return
+	}
+	idx.mu.Lock()
+	delete(idx.hidden, post.Id)
+	idx.mu.Unlock()
+	idx.put(&document{
+		key:    docKey{repository.PostKind, post.Id},
+		postId: post.Id,
+		title:  post.Title,
+		fields: map[string]string{FieldTitle: post.Title, FieldContent: post.Content},
+	})
+}
+
+// IndexComment indexes the comment, or removes it from the index once it
+// was soft-deleted.
+func (idx *Index) IndexComment(comment model.Comment) {
+	if comment.Deleted {
+		idx.RemoveComment(comment.Id)
+		return
+	}
+	idx.put(&document{
+		key:    docKey{repository.CommentKind, comment.Id},
+		postId: comment.PostId,
+		fields: map[string]string{FieldComment: comment.Comment},
+	})
+}
+
+func (idx *Index) RemovePost(id uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(docKey{repository.PostKind, id})
+	delete(idx.hidden, id)
+}
+
+func (idx *Index) RemoveComment(id uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(docKey{repository.CommentKind, id})
+}
+
+func (idx *Index) put(doc *document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(doc.key)
+	doc.length = make(map[string]int, len(doc.fields))
+	for field, text := range doc.fields {
+		tokens := tokenize(text)
+		doc.length[field] = len(tokens)
+		idx.fieldLength[field] += len(tokens)
+		idx.fieldDocs[field]++
+		for _, t := range tokens {
+			docs, ok := idx.postings[t.term]
+			if !ok {
+				docs = make(map[docKey]map[string][]int)
+				idx.postings[t.term] = docs
+			}
+			fields, ok := docs[doc.key]
+			if !ok {
+				fields = make(map[string][]int)
+				docs[doc.key] = fields
+			}
+			fields[field] = append(fields[field], t.position)
+		}
+	}
+	idx.docs[doc.key] = doc
+}
+
+// remove drops the document from the index. Callers must hold the write lock.
+func (idx *Index) remove(key docKey) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	for field, text := range doc.fields {
+		idx.fieldLength[field] -= doc.length[field]
+		idx.fieldDocs[field]--
+		for _, t := range tokenize(text) {
+			if docs, ok := idx.postings[t.term]; ok {
+				delete(docs, key)
+				if len(docs) == 0 {
+					delete(idx.postings, t.term)
+				}
+			}
+		}
+	}
+	delete(idx.docs, key)
+}
+
+// Query is a search request. Text holds words and double quoted phrases;
+// every phrase must occur in a matching document while the words only
+// contribute to its rank. Kind restricts the results to repository.PostKind
+// or repository.CommentKind. A Limit of zero or less returns all results.
+type Query struct {
+	Text   string
+	Kind   string
+	Offset int
+	Limit  int
+}
+
+type Result struct {
+	Type   string `json:"type"`
+	Id     uint64 `json:"id"`
+	PostId uint64 `json:"postId"`
+	// Title is the title of a matching post.
+	Title string `json:"title,omitempty"`
+	// Snippet is an HTML excerpt of the best matching field with the matches
+	// wrapped in <mark> elements.
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+type Results struct {
+	Items []Result `json:"items"`
+	// Total is the number of matching documents across all pages.
+	Total int `json:"total"`
+}
+
+func (idx *Index) Search(q Query) Results {
+	words, phrases := parseQuery(q.Text)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	terms := append([]string{}, words...)
+	for _, phrase := range phrases {
+		for _, t := range phrase {
+			terms = append(terms, t.term)
+		}
+	}
+	scores := make(map[docKey]float64)
+	for _, term := range uniqueTerms(terms) {
+		for key, fields := range idx.postings[term] {
+			if q.Kind != "" && key.kind != q.Kind || idx.hidden[idx.docs[key].postId] {
+				continue
+			}
+			scores[key] += idx.score(term, idx.docs[key], fields)
+		}
+	}
+
+	var results []Result
+	for key, score := range scores {
+		doc := idx.docs[key]
+		if !idx.matchesPhrases(key, phrases) {
+			continue
+		}
+		results = append(results, Result{
+			Type:    key.kind,
+			Id:      key.id,
+			PostId:  doc.postId,
+			Title:   doc.title,
+			Snippet: doc.snippet(terms),
+			Score:   score,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Type != results[j].Type {
+			return results[i].Type > results[j].Type
+		}
+		return results[i].Id < results[j].Id
+	})
+
+	total := len(results)
+	start := q.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+	items := results[start:end]
+	if items == nil {
+		items = []Result{}
+	}
+	return Results{Items: items, Total: total}
+}
+
+// score is the boosted sum of the per field BM25 scores of the term in the
+// document. Callers must hold the read lock.
+func (idx *Index) score(term string, doc *document, fields map[string][]int) float64 {
+	n := float64(len(idx.docs))
+	df := float64(len(idx.postings[term]))
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	score := 0.0
+	for field, positions := range fields {
+		avgLength := float64(idx.fieldLength[field]) / float64(idx.fieldDocs[field])
+		tf := float64(len(positions))
+		norm := 1 - b + b*float64(doc.length[field])/avgLength
+		score += idx.boosts[field] * idf * tf * (k1 + 1) / (tf + k1*norm)
+	}
+	return score
+}
+
+// matchesPhrases reports whether every phrase occurs in one of the fields
+// of the document. Callers must hold the read lock.
+func (idx *Index) matchesPhrases(key docKey, phrases [][]token) bool {
+	for _, phrase := range phrases {
+		if !idx.matchesPhrase(key, phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+func (idx *Index) matchesPhrase(key docKey, phrase []token) bool {
+	first, ok := idx.postings[phrase[0].term][key]
+	if !ok {
+		return false
+	}
+	for field, starts := range first {
+		for _, start := range starts {
+			offset := start - phrase[0].position
+			matched := true
+			for _, t := range phrase[1:] {
+				if !containsPosition(idx.postings[t.term][key][field], offset+t.position) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsPosition(positions []int, position int) bool {
+	i := sort.SearchInts(positions, position)
+	return i < len(positions) && positions[i] == position
+}
+
+// parseQuery splits the query text into stemmed words and phrases, the
+// latter keeping the relative positions of their words.
+func parseQuery(text string) ([]string, [][]token) {
+	var (
+		words   []string
+		phrases [][]token
+	)
+	for i, part := range strings.Split(text, `"`) {
+		tokens := tokenize(part)
+		if i%2 == 0 {
+			for _, t := range tokens {
+				words = append(words, t.term)
+			}
+			continue
+		}
+		if len(tokens) > 0 {
+			phrases = append(phrases, tokens)
+		}
+	}
+	return words, phrases
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,117 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func resultKeys(results Results) []docKey {
+	keys := make([]docKey, 0, len(results.Items))
+	for _, item := range results.Items {
+		keys = append(keys, docKey{item.Type, item.Id})
+	}
+	return keys
+}
+
+func testIndex() *Index {
+	index := NewIndex()
+	index.IndexPost(model.Post{Id: 1, Title: "Searching with Go", Content: "An inverted index maps terms to the documents containing them."})
+	index.IndexPost(model.Post{Id: 2, Title: "Baking bread", Content: "Bread needs flour, water and time. Go slowly when searching for the perfect crumb."})
+	index.IndexPost(model.Post{Id: 3, Title: "Weekly notes", Content: "Nothing about indexes at all."})
+	index.IndexComment(model.Comment{Id: 10, PostId: 1, Comment: "Great write-up on the inverted index!"})
+	index.IndexComment(model.Comment{Id: 11, PostId: 2, Comment: "<b>Bread</b> & butter"})
+	return index
+}
+
+func TestIndex_Search(t *testing.T) {
+	index := testIndex()
+
+	tests := []struct {
+		name     string
+		query    Query
+		expected []docKey
+	}{
+		{name: "title boost ranks title matches first", query: Query{Text: "searching go"},
+			expected: []docKey{{repository.PostKind, 1}, {repository.PostKind, 2}}},
+		{name: "stemmed terms match", query: Query{Text: "indexes"},
+			expected: []docKey{{repository.PostKind, 3}, {repository.PostKind, 1}, {repository.CommentKind, 10}}},
+		{name: "phrase is required", query: Query{Text: `"inverted index"`},
+			expected: []docKey{{repository.PostKind, 1}, {repository.CommentKind, 10}}},
+		{name: "phrase words must be adjacent", query: Query{Text: `"index inverted"`},
+			expected: []docKey{}},
+		{name: "phrase with free terms", query: Query{Text: `bread "inverted index"`},
+			expected: []docKey{{repository.PostKind, 1}, {repository.CommentKind, 10}}},
+		{name: "kind filter", query: Query{Text: "bread", Kind: repository.CommentKind},
+			expected: []docKey{{repository.CommentKind, 11}}},
+		{name: "only stopwords", query: Query{Text: "the and"},
+			expected: []docKey{}},
+		{name: "pagination", query: Query{Text: "indexes", Offset: 1, Limit: 1},
+			expected: []docKey{{repository.PostKind, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resultKeys(index.Search(tt.query)))
+		})
+	}
+
+	assert.Equal(t, 3, index.Search(Query{Text: "indexes", Limit: 1}).Total)
+}
+
+func TestIndex_Snippets(t *testing.T) {
+	index := testIndex()
+
+	results := index.Search(Query{Text: "bread", Kind: repository.CommentKind})
+	require.Len(t, results.Items, 1)
+	assert.Equal(t, Result{Type: repository.CommentKind, Id: 11, PostId: 2,
+		Snippet: "&lt;b&gt;<mark>Bread</mark>&lt;/b&gt; &amp; butter", Score: results.Items[0].Score}, results.Items[0])
+
+	results = index.Search(Query{Text: "weekly"})
+	require.Len(t, results.Items, 1)
+	assert.Equal(t, "Weekly notes", results.Items[0].Title)
+	assert.Equal(t, "<mark>Weekly</mark> notes", results.Items[0].Snippet)
+
+	long := model.Post{Id: 4, Title: "Long", Content: strings.Repeat("lorem ipsum ", 40) + "needle " + strings.Repeat("dolor sit ", 40)}
+	index.IndexPost(long)
+	results = index.Search(Query{Text: "needle"})
+	require.Len(t, results.Items, 1)
+	snippet := results.Items[0].Snippet
+	assert.Contains(t, snippet, "<mark>needle</mark>")
+	assert.True(t, len(snippet) < len(long.Content))
+	assert.Equal(t, "…", snippet[:len("…")])
+	assert.Equal(t, "…", snippet[len(snippet)-len("…"):])
+}
+
+func TestIndex_Updates(t *testing.T) {
+	index := testIndex()
+
+	index.IndexPost(model.Post{Id: 3, Title: "Weekly notes", Content: "Sourdough bread again."})
+	assert.ElementsMatch(t, []docKey{{repository.PostKind, 2}, {repository.PostKind, 3}},
+		resultKeys(index.Search(Query{Text: "bread", Kind: repository.PostKind})))
+	assert.Equal(t, []docKey{{repository.PostKind, 1}, {repository.CommentKind, 10}},
+		resultKeys(index.Search(Query{Text: "indexes"})))
+
+	index.IndexComment(model.Comment{Id: 10, PostId: 1, Comment: model.DeletedCommentPlaceholder, Deleted: true})
+	index.RemovePost(1)
+	assert.Empty(t, index.Search(Query{Text: "indexes"}).Items)
+	assert.Empty(t, index.Search(Query{Text: "inverted"}).Items)
+}
+
+func TestIndex_UnpublishedPosts(t *testing.T) {
+	index := testIndex()
+
+	index.IndexPost(model.Post{Id: 1, Title: "Searching with Go", Content: "An inverted index maps terms to the documents containing them.", Status: model.StatusDraft})
+	assert.Empty(t, index.Search(Query{Text: "inverted"}).Items)
+
+	index.IndexComment(model.Comment{Id: 12, PostId: 1, Comment: "Inverted again"})
+	assert.Empty(t, index.Search(Query{Text: "inverted"}).Items)
+
+	index.IndexPost(model.Post{Id: 1, Title: "Searching with Go", Content: "An inverted index maps terms to the documents containing them.", Status: model.StatusPublished})
+	assert.ElementsMatch(t, []docKey{{repository.PostKind, 1}, {repository.CommentKind, 10}, {repository.CommentKind, 12}},
+		resultKeys(index.Search(Query{Text: "inverted"})))
+}
@@ -0,0 +1,81 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	snippetLength = 200
+	ellipsis      = "…"
+)
+
+// snippetFields lists the fields in the order they are preferred for snippets.
+var snippetFields = []string{FieldContent, FieldComment, FieldTitle}
+
+// snippet excerpts the first field of the document containing any of the
+// terms, around the first match, escaping the text and highlighting every
+// match with <mark>. Documents without matches fall back to the start of
+// their first field.
+func (doc *document) snippet(terms []string) string {
+	wanted := toSet(strings.Join(terms, " "))
+	for _, field := range snippetFields {
+		text, ok := doc.fields[field]
+		if !ok {
+			continue
+		}
+		var matches []token
+		for _, t := range tokenize(text) {
+			if wanted[t.term] {
+				matches = append(matches, t)
+			}
+		}
+		if len(matches) > 0 {
+			return highlight(text, matches)
+		}
+	}
+	for _, field := range snippetFields {
+		if text, ok := doc.fields[field]; ok {
+			return highlight(text, nil)
+		}
+	}
+	return ""
+}
+
+func highlight(text string, matches []token) string {
+	start := 0
+	if len(matches) > 0 {
+		start = matches[0].start - snippetLength/4
+	}
+	if start < 0 {
+		start = 0
+	}
+	start = runeBoundary(text, start)
+	end := start + snippetLength
+	if end >= len(text) {
+		end = len(text)
+	} else {
+		end = runeBoundary(text, end)
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString(ellipsis)
+	}
+	offset := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		sb.WriteString(html.EscapeString(text[offset:m.start]))
+		sb.WriteString("<mark>")
+		sb.WriteString(html.EscapeString(text[m.start:m.end]))
+		sb.WriteString("</mark>")
+		offset = m.end
+	}
+	sb.WriteString(html.EscapeString(text[offset:end]))
+	if end < len(text) {
+		sb.WriteString(ellipsis)
+	}
+	return sb.String()
+}
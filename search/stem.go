@@ -0,0 +1,139 @@
+package search
+
+// stem reduces an English word to its stem with the plural, past tense,
+// gerund and trailing "e" steps (1a, 1b, 1c, 5a and 5b) of the Porter
+// stemmer, which is enough to match "posts", "posted" and "posting" with
+// "post". Words that are not plain ASCII are returned unchanged.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	w := []byte(word)
+	w = step1a(w)
+	w = step1b(w)
+	w = step1c(w)
+	w = step5(w)
+	return string(w)
+}
+
+func step1a(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"), hasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+		return w
+	case hasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func step1b(w []byte) []byte {
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+	var stripped []byte
+	switch {
+	case hasSuffix(w, "ed") && containsVowel(w[:len(w)-2]):
+		stripped = w[:len(w)-2]
+	case hasSuffix(w, "ing") && containsVowel(w[:len(w)-3]):
+		stripped = w[:len(w)-3]
+	default:
+		return w
+	}
+	switch {
+	case hasSuffix(stripped, "at"), hasSuffix(stripped, "bl"), hasSuffix(stripped, "iz"):
+		return append(stripped, 'e')
+	case endsWithDoubleConsonant(stripped):
+		last := stripped[len(stripped)-1]
+		if last != 'l' && last != 's' && last != 'z' {
+			return stripped[:len(stripped)-1]
+		}
+	case measure(stripped) == 1 && endsCVC(stripped):
+		return append(stripped, 'e')
+	}
+	return stripped
+}
+
+func step1c(w []byte) []byte {
+	if hasSuffix(w, "y") && containsVowel(w[:len(w)-1]) {
+		w[len(w)-1] = 'i'
+	}
+	return w
+}
+
+func step5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		stemmed := w[:len(w)-1]
+		if m := measure(stemmed); m > 1 || m == 1 && !endsCVC(stemmed) {
+			w = stemmed
+		}
+	}
+	if measure(w) > 1 && endsWithDoubleConsonant(w) && w[len(w)-1] == 'l' {
+		w = w[:len(w)-1]
+	}
+	return w
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+	return len(w) >= len(suffix) && string(w[len(w)-len(suffix):]) == suffix
+}
+
+// isConsonant follows Porter's definition, where "y" is a consonant unless
+// it follows a consonant.
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences of w.
+func measure(w []byte) int {
+	m := 0
+	previousVowel := false
+	for i := range w {
+		vowel := !isConsonant(w, i)
+		if previousVowel && !vowel {
+			m++
+		}
+		previousVowel = vowel
+	}
+	return m
+}
+
+func containsVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsWithDoubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports whether w ends consonant-vowel-consonant where the last
+// consonant is not w, x or y, as in "hop" but not in "snow".
+func endsCVC(w []byte) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+		return false
+	}
+	last := w[n-1]
+	return last != 'w' && last != 'x' && last != 'y'
+}
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+// indexedPostStore keeps the index in sync with every write to the wrapped
+// store. Writes are serialized so the index is updated in the same order as
+// the store.
+type indexedPostStore struct {
+	repository.PostStore
+	mu    sync.Mutex
+	index *Index
+}
+
+type indexedCommentStore struct {
+	repository.CommentStore
+	mu    sync.Mutex
+	index *Index
+}
+
+// Wrap indexes the current content of the stores and returns stores that
+// keep the index up to date on every write.
+func Wrap(posts repository.PostStore, comments repository.CommentStore) (repository.PostStore, repository.CommentStore, *Index, error) {
+	index := NewIndex()
+	page, err := posts.Query(repository.PostQuery{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, post := range page.Items {
+		index.IndexPost(post)
+		for _, comment := range comments.GetAllByPostId(post.Id) {
+			index.IndexComment(comment)
+		}
+	}
+	return &indexedPostStore{PostStore: posts, index: index},
+		&indexedCommentStore{CommentStore: comments, index: index},
+		index, nil
+}
+
+func (s *indexedPostStore) Insert(post model.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PostStore.Insert(post); err != nil {
+		return err
+	}
+	s.reindex(post.Id)
+	return nil
+}
+
+func (s *indexedPostStore) Create(post model.Post) (model.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created, err := s.PostStore.Create(post)
+	if err != nil {
+		return created, err
+	}
+	s.reindex(created.Id)
+	return created, nil
+}
+
+func (s *indexedPostStore) Update(post model.Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PostStore.Update(post); err != nil {
+		return err
+	}
+	s.reindex(post.Id)
+	return nil
+}
+
+func (s *indexedPostStore) PublishDue(id uint64, now time.Time) (model.Post, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, published, err := s.PostStore.PublishDue(id, now)
+	if published {
+		s.index.IndexPost(post)
+	}
+	return post, published, err
+}
+
+func (s *indexedPostStore) Delete(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.PostStore.Delete(id); err != nil {
+		return err
+	}
+	s.index.RemovePost(id)
+	return nil
+}
+
+// reindex indexes the post as stored, so normalization done by the store is
+// reflected in the index.
+func (s *indexedPostStore) reindex(id uint64) {
+	if post, err := s.PostStore.GetById(id); err == nil {
+		s.index.IndexPost(*post)
+	}
+}
+
+func (s *indexedCommentStore) Insert(comment model.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.CommentStore.Insert(comment); err != nil {
+		return err
+	}
+	s.reindex(comment.Id)
+	return nil
+}
+
+func (s *indexedCommentStore) Create(comment model.Comment) (model.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	created, err := s.CommentStore.Create(comment)
+	if err != nil {
+		return created, err
+	}
+	s.reindex(created.Id)
+	return created, nil
+}
+
+func (s *indexedCommentStore) Update(comment model.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.CommentStore.Update(comment); err != nil {
+		return err
+	}
+	s.reindex(comment.Id)
+	return nil
+}
+
+func (s *indexedCommentStore) Delete(id uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.CommentStore.Delete(id); err != nil {
+		return err
+	}
+	s.index.RemoveComment(id)
+	return nil
+}
+
+func (s *indexedCommentStore) DeleteAllByPostId(id uint64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comments := s.CommentStore.GetAllByPostId(id)
+	removed, err := s.CommentStore.DeleteAllByPostId(id)
+	if err != nil {
+		return removed, err
+	}
+	for _, comment := range comments {
+		s.index.RemoveComment(comment.Id)
+	}
+	return removed, nil
+}
+
+func (s *indexedCommentStore) reindex(id uint64) {
+	if comment, err := s.CommentStore.GetById(id); err == nil {
+		s.index.IndexComment(*comment)
+	}
+}
+
+var (
+	_ repository.PostStore    = (*indexedPostStore)(nil)
+	_ repository.CommentStore = (*indexedCommentStore)(nil)
+)
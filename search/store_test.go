@@ -0,0 +1,66 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestWrap(t *testing.T) {
+	// GIVEN
+	existingPost := model.Post{Id: 1, Title: "Existing", Content: "Seeded from the store", CreationDate: time.Unix(10011, 0)}
+	existingComment := model.Comment{Id: 1, PostId: 1, Comment: "seeded comment", CreationDate: time.Unix(10012, 0)}
+	posts, comments, index, err := Wrap(
+		repository.CustomPostRepository([]model.Post{existingPost}),
+		repository.CustomCommentRepository([]model.Comment{existingComment}))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []docKey{{repository.CommentKind, 1}, {repository.PostKind, 1}}, resultKeys(index.Search(Query{Text: "seeded"})))
+
+	// WHEN
+	post, err := posts.Create(model.Post{Title: "Fresh", Content: "Gardening tips"})
+	require.NoError(t, err)
+	comment, err := comments.Create(model.Comment{PostId: post.Id, Comment: "More gardening please"})
+	require.NoError(t, err)
+
+	// THEN
+	assert.Equal(t, 2, index.Search(Query{Text: "gardening"}).Total)
+
+	post.Content = "Cooking tips"
+	require.NoError(t, posts.Update(post))
+	assert.Equal(t, []docKey{{repository.CommentKind, comment.Id}}, resultKeys(index.Search(Query{Text: "gardening"})))
+
+	comment.Deleted = true
+	comment.Comment = model.DeletedCommentPlaceholder
+	require.NoError(t, comments.Update(comment))
+	assert.Empty(t, index.Search(Query{Text: "gardening"}).Items)
+
+	_, err = comments.DeleteAllByPostId(existingPost.Id)
+	require.NoError(t, err)
+	require.NoError(t, posts.Delete(existingPost.Id))
+	assert.Empty(t, index.Search(Query{Text: "seeded"}).Items)
+
+	assert.Error(t, posts.Update(model.Post{Id: 99, Title: "missing"}))
+	assert.Empty(t, index.Search(Query{Text: "missing"}).Items)
+}
+
+func TestWrap_publishDue(t *testing.T) {
+	// GIVEN
+	now := time.Unix(10011, 0)
+	scheduled := model.Post{Id: 1, Title: "Scheduled", Content: "Gardening tips", Status: model.StatusScheduled, PublishAt: &now, CreationDate: now}
+	posts, _, index, err := Wrap(repository.CustomPostRepository([]model.Post{scheduled}), repository.NewCommentRepository())
+	require.NoError(t, err)
+	assert.Empty(t, index.Search(Query{Text: "gardening"}).Items)
+
+	// WHEN
+	_, published, err := posts.PublishDue(scheduled.Id, now)
+
+	// THEN
+	require.NoError(t, err)
+	assert.True(t, published)
+	assert.Equal(t, []docKey{{repository.PostKind, scheduled.Id}}, resultKeys(index.Search(Query{Text: "gardening"})))
+}
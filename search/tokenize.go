@@ -0,0 +1,70 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is an indexed word: its stemmed term, its position among all words
+// of the text (stopwords included, so phrases keep their spacing) and its
+// byte offsets in the original text.
+type token struct {
+	term     string
+	position int
+	start    int
+	end      int
+}
+
+var stopwords = toSet(`a about above after again against all am an and any are as at be because been before being
+below between both but by can could did do does doing down during each few for from further had has have having he
+her here hers herself him himself his how i if in into is it its itself just me more most my myself no nor not now
+of off on once only or other our ours ourselves out over own same she should so some such than that the their theirs
+them themselves then there these they this those through to too under until up very was we were what when where
+which while who whom why will with would you your yours yourself yourselves`)
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// tokenize splits text into lower cased words of letters and digits,
+// dropping stopwords and stemming the rest.
+func tokenize(text string) []token {
+	var tokens []token
+	position := 0
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.ToLower(text[start:end])
+		if !stopwords[word] {
+			tokens = append(tokens, token{term: stem(word), position: position, start: start, end: end})
+		}
+		position++
+		start = -1
+	}
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
+
+// runeBoundary moves i back to the start of the rune it falls into.
+func runeBoundary(text string, i int) int {
+	for i > 0 && i < len(text) && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,44 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word     string
+		expected string
+	}{
+		{word: "caresses", expected: "caress"},
+		{word: "ponies", expected: "poni"},
+		{word: "cats", expected: "cat"},
+		{word: "plastered", expected: "plaster"},
+		{word: "motoring", expected: "motor"},
+		{word: "hopping", expected: "hop"},
+		{word: "filing", expected: "file"},
+		{word: "happy", expected: "happi"},
+		{word: "controlling", expected: "control"},
+		{word: "searches", expected: "search"},
+		{word: "searching", expected: "search"},
+		{word: "über", expected: "über"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			assert.Equal(t, tt.expected, stem(tt.word))
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []token{
+		{term: "quick", position: 1, start: 4, end: 9},
+		{term: "brown", position: 2, start: 10, end: 15},
+		{term: "fox", position: 3, start: 16, end: 19},
+		{term: "jump", position: 4, start: 21, end: 26},
+		{term: "über", position: 5, start: 28, end: 33},
+		{term: "42", position: 6, start: 34, end: 36},
+	}, tokenize("The Quick brown-fox, jumps; Über 42"))
+	assert.Empty(t, tokenize("  the and of "))
+}
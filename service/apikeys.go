@@ -0,0 +1,256 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/auth"
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+// apiKeyHeader carries the API key of machine clients.
+const apiKeyHeader = "X-Api-Key"
+
+// apiKeyUsageResolution is how stale the last use of a key may get before it
+// is stored again, so that busy keys do not write on every request.
+const apiKeyUsageResolution = time.Minute
+
+const maxApiKeyNameLength = 100
+
+const (
+	apiKeysPath = "/api/keys"
+	apiKeyPath  = apiKeysPath + "/{id:[0-9]+}"
+)
+
+// noScope marks routes API keys may not be used for at all.
+const noScope = ""
+
+var apiKeyScopes = []string{model.ScopePostsRead, model.ScopePostsWrite, model.ScopeCommentsRead, model.ScopeCommentsWrite}
+
+const apiKeyContextKey contextKey = userContextKey + 1
+
+// WithApiKeys keeps API keys in the store instead of in memory.
+func WithApiKeys(keys repository.ApiKeyStore) Option {
+	return func(svc *RestApiService) {
+		svc.apiKeyRepository = keys
+	}
+}
+
+// ApiKeyRequest is the payload creating an API key.
+type ApiKeyRequest struct {
+	Name   string
+	Scopes []string
+}
+
+// ApiKeyResponse is an API key as served by the API, without the key itself.
+type ApiKeyResponse struct {
+	Id           uint64
+	Name         string
+	Prefix       string
+	Scopes       []string
+	CreationDate time.Time
+	LastUsedAt   *time.Time `json:",omitempty"`
+	RevokedAt    *time.Time `json:",omitempty"`
+}
+
+// CreatedApiKeyResponse answers the creation of a key. The key is only ever
+// served here.
+type CreatedApiKeyResponse struct {
+	ApiKeyResponse
+	Key string
+}
+
+func newApiKeyResponse(key model.ApiKey) ApiKeyResponse {
+	return ApiKeyResponse{
+		Id:           key.Id,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Scopes:       key.Scopes,
+		CreationDate: key.CreationDate,
+		LastUsedAt:   key.LastUsedAt,
+		RevokedAt:    key.RevokedAt,
+	}
+}
+
+// apiKeyUser looks up the key and the user owning it, recording that the key
+// was used.
+func (svc *RestApiService) apiKeyUser(secret string) (*model.User, *model.ApiKey, error) {
+	if svc.apiKeyRepository == nil {
+		return nil, nil, newApiError(http.StatusUnauthorized, "API key is invalid")
+	}
+	key, err := svc.apiKeyRepository.GetByHash(auth.HashApiKey(secret))
+	if err != nil || key.Revoked() {
+		return nil, nil, newApiError(http.StatusUnauthorized, "API key is invalid")
+	}
+	user, err := svc.userRepository.GetById(key.UserId)
+	if err != nil {
+		return nil, nil, newApiError(http.StatusUnauthorized, "API key is invalid")
+	}
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyUsageResolution {
+		key.LastUsedAt = &now
+		if err := svc.apiKeyRepository.Touch(key.Id, now); err != nil {
+			log.Printf("could not record the use of API key id: %d: %v", key.Id, err)
+		}
+	}
+	return user, key, nil
+}
+
+// currentApiKey returns the API key the caller authenticated with, nil when
+// the caller did not use one.
+func currentApiKey(r *http.Request) *model.ApiKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*model.ApiKey)
+	return key
+}
+
+// scoped turns away callers authenticated with an API key lacking the scope,
+// or with any API key when the scope is noScope. Other callers are let
+// through to the handler.
+func (svc *RestApiService) scoped(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := currentApiKey(r)
+		switch {
+		case key == nil:
+			h(w, r)
+		case scope == noScope:
+			svc.writeError(w, r, newApiError(http.StatusForbidden, "API keys cannot be used to %s %s", r.Method, r.URL.Path))
+		case !key.HasScope(scope):
+			svc.writeError(w, r, newApiError(http.StatusForbidden, "API key: %s lacks the %s scope", key.Prefix, scope))
+		default:
+			h(w, r)
+		}
+	}
+}
+
+// handleCreateApiKey serves POST /api/keys, creating a key for the caller.
+func (svc *RestApiService) handleCreateApiKey(w http.ResponseWriter, r *http.Request) {
+	user, err := requireUser(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	var request ApiKeyRequest
+	_, fieldErrors, err := decodeRequestPayload(r, &request)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize API key json payload"))
+		return
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validateApiKey(request)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	secret, err := auth.GenerateApiKey()
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	key, err := svc.apiKeyRepository.Create(model.ApiKey{
+		UserId: user.Id,
+		Name:   request.Name,
+		Prefix: secret[:auth.ApiKeyPrefixLength],
+		Hash:   auth.HashApiKey(secret),
+		Scopes: request.Scopes,
+	})
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "API key could not be created"))
+		return
+	}
+	w.Header().Set("Location", apiKeyLocation(key.Id))
+	writeJsonResponse(w, http.StatusOK, CreatedApiKeyResponse{ApiKeyResponse: newApiKeyResponse(key), Key: secret})
+}
+
+// handleListApiKeys serves GET /api/keys, listing the keys of the caller
+// including revoked ones.
+func (svc *RestApiService) handleListApiKeys(w http.ResponseWriter, r *http.Request) {
+	user, err := requireUser(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	keys := []ApiKeyResponse{}
+	for _, key := range svc.apiKeyRepository.GetAllByUserId(user.Id) {
+		keys = append(keys, newApiKeyResponse(key))
+	}
+	writeJsonResponse(w, http.StatusOK, keys)
+}
+
+func (svc *RestApiService) handleGetApiKey(w http.ResponseWriter, r *http.Request) {
+	key, err := svc.ownApiKey(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, newApiKeyResponse(*key))
+}
+
+// handleRevokeApiKey serves DELETE /api/keys/{id}. Revoked keys are kept,
+// and listed, but no longer accepted.
+func (svc *RestApiService) handleRevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	key, err := svc.ownApiKey(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if !key.Revoked() {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+		if err := svc.apiKeyRepository.Update(*key); err != nil {
+			svc.writeError(w, r, storeError(err, "API key with id: %d does not exist", key.Id))
+			return
+		}
+	}
+	writeAckResponse(w, http.StatusOK, fmt.Sprintf("API key id: %d successfully revoked", key.Id))
+}
+
+// ownApiKey looks up the key with the {id} path variable, failing with 404
+// unless it belongs to the caller or the caller manages users.
+func (svc *RestApiService) ownApiKey(w http.ResponseWriter, r *http.Request) (*model.ApiKey, error) {
+	user, err := requireUser(w, r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		return nil, err
+	}
+	key, err := svc.apiKeyRepository.GetById(id)
+	if err != nil {
+		return nil, storeError(err, "API key with id: %d does not exist", id)
+	}
+	if key.UserId != user.Id && !allowed(*user, manageUsers, 0) {
+		return nil, newApiError(http.StatusNotFound, "API key with id: %d does not exist", id)
+	}
+	return key, nil
+}
+
+func validateApiKey(request ApiKeyRequest) []FieldError {
+	fieldErrors := validateText("Name", request.Name, maxApiKeyNameLength)
+	if len(request.Scopes) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Scopes", Reason: "is required"})
+	}
+	for _, scope := range request.Scopes {
+		if !knownScope(scope) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "Scopes", Reason: fmt.Sprintf("must only hold %s", strings.Join(apiKeyScopes, ", "))})
+			break
+		}
+	}
+	return fieldErrors
+}
+
+func knownScope(scope string) bool {
+	for _, known := range apiKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+func apiKeyLocation(id uint64) string {
+	return strings.Replace(apiKeyPath, "{id:[0-9]+}", strconv.FormatUint(id, 10), 1)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// createApiKey creates a key with the scopes for the user of the token and
+// returns it.
+func createApiKey(t *testing.T, serve func(method, path, token, body string) *httptest.ResponseRecorder, token string, scopes ...string) CreatedApiKeyResponse {
+	payload, err := json.Marshal(ApiKeyRequest{Name: "ci", Scopes: scopes})
+	require.NoError(t, err)
+	w := serve(http.MethodPost, apiKeysPath, token, string(payload))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var key CreatedApiKeyResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&key))
+	return key
+}
+
+// serveWithApiKey serves the request behind the authentication middleware
+// with the API key.
+func serveWithApiKey(svc *RestApiService, method, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(apiKeyHeader, key)
+	w := httptest.NewRecorder()
+	svc.handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestRestApiService_apiKeyLifecycle(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	serve := authServer(&svc)
+	admin := loginAdmin(t, &svc, serve)
+
+	// WHEN
+	created := createApiKey(t, serve, admin, model.ScopePostsWrite)
+
+	// THEN
+	assert.Equal(t, uint64(1), created.Id)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	stored, err := svc.apiKeyRepository.GetById(created.Id)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.Hash, created.Key)
+	assert.Nil(t, stored.LastUsedAt)
+
+	w := serveWithApiKey(&svc, http.MethodPost, postsPath, created.Key, `{"Title": "imported", "Content": "content"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	post, err := svc.postRepository.GetById(1)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), post.OwnerId)
+
+	w = serve(http.MethodGet, apiKeysPath, admin, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Key)
+	var keys []ApiKeyResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&keys))
+	require.Len(t, keys, 1)
+	require.NotNil(t, keys[0].LastUsedAt)
+	assert.WithinDuration(t, time.Now(), *keys[0].LastUsedAt, time.Minute)
+	assert.Equal(t, []string{model.ScopePostsWrite}, keys[0].Scopes)
+
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, apiKeyLocation(created.Id), admin, "").Code)
+	w = serve(http.MethodGet, apiKeyLocation(created.Id), admin, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var revoked ApiKeyResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&revoked))
+	assert.NotNil(t, revoked.RevokedAt)
+	w = serveWithApiKey(&svc, http.MethodPost, postsPath, created.Key, `{"Title": "imported", "Content": "content"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRestApiService_apiKeyScopes(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	serve := authServer(&svc)
+	admin := loginAdmin(t, &svc, serve)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, postsPath, admin, `{"Title": "post", "Content": "content"}`).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, commentsPath, admin, `{"PostId": 1, "Comment": "comment"}`).Code)
+	postsKey := createApiKey(t, serve, admin, model.ScopePostsRead, model.ScopePostsWrite).Key
+	commentsKey := createApiKey(t, serve, admin, model.ScopeCommentsRead).Key
+	reader := login(t, serve, "reader")
+	readerKey := createApiKey(t, serve, reader, model.ScopePostsWrite).Key
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "read posts", key: postsKey, method: http.MethodGet, path: postsPath, want: http.StatusOK},
+		{name: "write posts", key: postsKey, method: http.MethodPatch, path: "/api/posts/1", body: `{"Content": "edited"}`, want: http.StatusOK},
+		{name: "read comments without scope", key: postsKey, method: http.MethodGet, path: "/api/posts/comments/1", want: http.StatusForbidden},
+		{name: "read comments", key: commentsKey, method: http.MethodGet, path: "/api/posts/comments/1", want: http.StatusOK},
+		{name: "write comments without scope", key: commentsKey, method: http.MethodPost, path: commentsPath, body: `{"PostId": 1, "Comment": "c"}`, want: http.StatusForbidden},
+		{name: "read posts without scope", key: commentsKey, method: http.MethodGet, path: "/api/posts/1", want: http.StatusForbidden},
+		{name: "create keys", key: postsKey, method: http.MethodPost, path: apiKeysPath, body: `{"Name": "n", "Scopes": ["posts:read"]}`, want: http.StatusForbidden},
+		{name: "change roles", key: postsKey, method: http.MethodPut, path: "/api/users/2/role", body: `{"Role": "admin"}`, want: http.StatusForbidden},
+		{name: "scope beyond role", key: readerKey, method: http.MethodPost, path: postsPath, body: `{"Title": "t", "Content": "c"}`, want: http.StatusForbidden},
+		{name: "public feed", key: commentsKey, method: http.MethodGet, path: rssFeedPath, want: http.StatusOK},
+		{name: "unknown key", key: "grb_unknown", method: http.MethodGet, path: postsPath, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			w := serveWithApiKey(&svc, tt.method, tt.path, tt.key, tt.body)
+
+			// THEN
+			assert.Equal(t, tt.want, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestRestApiService_apiKeyValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{name: "missing name", body: `{"Scopes": ["posts:read"]}`, field: "Name"},
+		{name: "long name", body: `{"Name": "` + strings.Repeat("n", 101) + `", "Scopes": ["posts:read"]}`, field: "Name"},
+		{name: "missing scopes", body: `{"Name": "ci"}`, field: "Scopes"},
+		{name: "unknown scope", body: `{"Name": "ci", "Scopes": ["posts:read", "users:write"]}`, field: "Scopes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiService()
+			serve := authServer(&svc)
+			token := loginAdmin(t, &svc, serve)
+
+			// WHEN
+			w := serve(http.MethodPost, apiKeysPath, token, tt.body)
+
+			// THEN
+			require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			var problem ProblemResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+			require.Len(t, problem.Errors, 1)
+			assert.Equal(t, tt.field, problem.Errors[0].Field)
+		})
+	}
+}
+
+func TestRestApiService_apiKeysOfOtherUsers(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	serve := authServer(&svc)
+	admin := loginAdmin(t, &svc, serve)
+	alice := login(t, serve, "alice")
+	bob := login(t, serve, "bob")
+	key := createApiKey(t, serve, alice, model.ScopePostsRead)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, apiKeyLocation(key.Id), bob, "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, apiKeyLocation(key.Id), bob, "").Code)
+	assert.Equal(t, "[]", strings.TrimSpace(serve(http.MethodGet, apiKeysPath, bob, "").Body.String()))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodGet, apiKeysPath, "", "").Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodDelete, apiKeyLocation(key.Id), admin, "").Code)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const problemContentType = "application/problem+json"
+
+// ProblemResponse is an RFC 7807 problem details object. Validation
+// failures extend it with the list of offending fields.
+type ProblemResponse struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// apiError is an error together with the status code it is answered with.
+type apiError struct {
+	status      int
+	detail      string
+	fieldErrors []FieldError
+}
+
+func (e apiError) Error() string {
+	return e.detail
+}
+
+func newApiError(status int, format string, args ...interface{}) apiError {
+	return apiError{status: status, detail: fmt.Sprintf(format, args...)}
+}
+
+func validationError(fieldErrors []FieldError) apiError {
+	return apiError{status: http.StatusUnprocessableEntity, detail: "payload validation failed", fieldErrors: fieldErrors}
+}
+
+// storeError maps an error returned by a repository to its status code.
+// Missing and duplicate entities are described with the given message,
+// anything else is an internal error reported as is.
+func storeError(err error, format string, args ...interface{}) apiError {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return newApiError(http.StatusNotFound, format, args...)
+	case errors.Is(err, repository.ErrAlreadyExists):
+		return newApiError(http.StatusConflict, format, args...)
+	}
+	return apiError{status: http.StatusInternalServerError, detail: err.Error()}
+}
+
+// writeError is the single place errors are rendered, either as problem
+// details or, for clients that opted in, as the legacy AckJsonResponse.
+func (svc *RestApiService) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var e apiError
+	if !errors.As(err, &e) {
+		e = apiError{status: http.StatusInternalServerError, detail: err.Error()}
+	}
+
+	var (
+		contentType = problemContentType
+		payload     interface{}
+	)
+	switch {
+	case svc.legacyErrors && len(e.fieldErrors) > 0:
+		contentType = "application/json"
+		payload = ValidationErrorResponse{Message: e.detail, Status: e.status, Errors: e.fieldErrors}
+	case svc.legacyErrors:
+		contentType = "application/json"
+		payload = AckJsonResponse{Message: e.detail, Status: e.status}
+	default:
+		payload = ProblemResponse{
+			Type:     "about:blank",
+			Title:    http.StatusText(e.status),
+			Status:   e.status,
+			Detail:   e.detail,
+			Instance: r.URL.RequestURI(),
+			Errors:   e.fieldErrors,
+		}
+	}
+
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(e.status)
+	w.Write(response)
+}
+
+func (svc *RestApiService) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	svc.writeError(w, r, newApiError(http.StatusNotFound, "no resource found at %s", r.URL.Path))
+}
+
+func (svc *RestApiService) handleMethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	svc.writeError(w, r, newApiError(http.StatusMethodNotAllowed, "method %s is not allowed on %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+type failingPostStore struct {
+	repository.PostStore
+	err error
+}
+
+func (s failingPostStore) GetById(id uint64) (*model.Post, error) {
+	return nil, s.err
+}
+
+func TestRestApiService_problemResponses(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var existingPost = model.Post{Id: 3, Title: "title", Content: "content", CreationDate: testDate}
+	var deletedComment = model.Comment{Id: 7, PostId: 3, Comment: model.DeletedCommentPlaceholder, Author: model.DeletedCommentPlaceholder, Deleted: true, CreationDate: testDate}
+
+	tests := []struct {
+		testName        string
+		postStore       repository.PostStore
+		method          string
+		path            string
+		reqBody         string
+		expectedProblem ProblemResponse
+	}{
+		{
+			testName: "testPostNotFound",
+			method:   http.MethodGet,
+			path:     "/api/posts/42",
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Not Found", Status: 404,
+				Detail: "Post with id: 42 does not exist", Instance: "/api/posts/42"},
+		},
+		{
+			testName: "testPostAlreadyExists",
+			method:   http.MethodPost,
+			path:     postsPath,
+			reqBody:  `{"Id": 3, "Title": "title", "Content": "content"}`,
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Conflict", Status: 409,
+				Detail: "Post with id: 3 already exists in the database", Instance: postsPath},
+		},
+		{
+			testName: "testBadPayload",
+			method:   http.MethodPost,
+			path:     postsPath,
+			reqBody:  `invalidJson`,
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Bad Request", Status: 400,
+				Detail: "could not deserialize post json payload", Instance: postsPath},
+		},
+		{
+			testName: "testBadQueryParameter",
+			method:   http.MethodGet,
+			path:     "/api/posts?limit=0",
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Bad Request", Status: 400,
+				Detail: "wrong limit query parameter: 0, expected a number between 1 and 100", Instance: "/api/posts?limit=0"},
+		},
+		{
+			testName: "testDeletedComment",
+			method:   http.MethodPut,
+			path:     "/api/comments/7",
+			reqBody:  `{"Comment": "abc", "Author": "author"}`,
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Gone", Status: 410,
+				Detail: "Comment with id: 7 was deleted", Instance: "/api/comments/7"},
+		},
+		{
+			testName:  "testStoreFailure",
+			postStore: failingPostStore{err: errors.New("disk on fire")},
+			method:    http.MethodGet,
+			path:      "/api/posts/3",
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Internal Server Error", Status: 500,
+				Detail: "disk on fire", Instance: "/api/posts/3"},
+		},
+		{
+			testName:  "testWrappedNotFound",
+			postStore: failingPostStore{err: fmt.Errorf("remote lookup: %w", repository.ErrNotFound)},
+			method:    http.MethodGet,
+			path:      "/api/posts/3",
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Not Found", Status: 404,
+				Detail: "Post with id: 3 does not exist", Instance: "/api/posts/3"},
+		},
+		{
+			testName: "testUnknownRoute",
+			method:   http.MethodGet,
+			path:     "/api/authors",
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Not Found", Status: 404,
+				Detail: "no resource found at /api/authors", Instance: "/api/authors"},
+		},
+		{
+			testName: "testMethodNotAllowed",
+			method:   http.MethodDelete,
+			path:     postsPath,
+			expectedProblem: ProblemResponse{Type: "about:blank", Title: "Method Not Allowed", Status: 405,
+				Detail: "method DELETE is not allowed on /api/posts", Instance: postsPath},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			var postStore repository.PostStore = repository.CustomPostRepository([]model.Post{existingPost})
+			if tc.postStore != nil {
+				postStore = tc.postStore
+			}
+			svc := NewRestApiServiceWithStores(postStore, repository.CustomCommentRepository([]model.Comment{deletedComment}))
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedProblem.Status, response.StatusCode)
+			assert.Equal(t, problemContentType, response.Header.Get("Content-Type"))
+			var problem ProblemResponse
+			require.NoError(t, json.Unmarshal(body, &problem))
+			assert.Equal(t, tc.expectedProblem, problem)
+		})
+	}
+}
+
+func TestRestApiService_legacyErrors(t *testing.T) {
+	// GIVEN
+	var existingPost = model.Post{Id: 3, Title: "title", Content: "content", CreationDate: time.Now()}
+	svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{existingPost}), repository.NewCommentRepository(), WithLegacyErrors())
+	req := httptest.NewRequest(http.MethodPost, postsPath, strings.NewReader(`{"Id": 3, "Title": "title", "Content": "content"}`))
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, req)
+	response := w.Result()
+	body, _ := io.ReadAll(response.Body)
+
+	// THEN
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	var ack AckJsonResponse
+	require.NoError(t, json.Unmarshal(body, &ack))
+	assert.Equal(t, AckJsonResponse{Message: "Post with id: 3 already exists in the database", Status: 409}, ack)
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/markup"
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const (
+	defaultFeedSize = 20
+	feedTitle       = "go-rest-blog"
+)
+
+const (
+	rssFeedPath          = "/feeds/rss.xml"
+	atomFeedPath         = "/feeds/atom.xml"
+	commentsRssFeedPath  = "/feeds/posts/{id}/comments/rss.xml"
+	commentsAtomFeedPath = "/feeds/posts/{id}/comments/atom.xml"
+)
+
+const (
+	rssContentType  = "application/rss+xml; charset=utf-8"
+	atomContentType = "application/atom+xml; charset=utf-8"
+)
+
+// WithFeedSize sets the number of posts, or comments, served in a feed.
+func WithFeedSize(size int) Option {
+	return func(svc *RestApiService) {
+		svc.feedSize = size
+	}
+}
+
+// WithBaseURL sets the absolute URL the API is reachable at, which feeds and
+// the sitemap link to. Without it links are built from the host of each
+// request.
+func WithBaseURL(baseURL string) Option {
+	return func(svc *RestApiService) {
+		svc.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// feed is what the RSS and Atom encodings of a feed are built from. All
+// links are absolute.
+type feed struct {
+	title       string
+	description string
+	link        string
+	self        string
+	// updated is when the newest item was published or edited, or when
+	// the feed was built if it has no items.
+	updated time.Time
+	items   []feedItem
+}
+
+type feedItem struct {
+	id         string
+	title      string
+	link       string
+	author     string
+	html       string
+	categories []string
+	published  time.Time
+	updated    time.Time
+}
+
+type feedBuilder func(r *http.Request, baseURL string) (feed, error)
+
+type feedEncoder func(f feed) ([]byte, string, error)
+
+// feedHandler serves the feed built by build in the encoding of encode,
+// answering conditional requests with 304 Not Modified through the ETag and
+// Last-Modified of the feed.
+func (svc *RestApiService) feedHandler(build feedBuilder, encode feedEncoder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := build(r, svc.requestBaseURL(r))
+		if err != nil {
+			svc.writeError(w, r, err)
+			return
+		}
+		data, contentType, err := encode(f)
+		if err != nil {
+			svc.writeError(w, r, err)
+			return
+		}
+		hash := fnv.New64a()
+		hash.Write(data)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, hash.Sum64()))
+		http.ServeContent(w, r, "", f.updated, bytes.NewReader(data))
+	}
+}
+
+func (svc *RestApiService) requestBaseURL(r *http.Request) string {
+	if svc.baseURL != "" {
+		return svc.baseURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (svc *RestApiService) feedLimit() int {
+	if svc.feedSize <= 0 {
+		return defaultFeedSize
+	}
+	return svc.feedSize
+}
+
+// postsFeed holds the latest published posts, most recently published first.
+func (svc *RestApiService) postsFeed(r *http.Request, baseURL string) (feed, error) {
+	page, err := svc.postRepository.Query(repository.PostQuery{
+		SortBy:     repository.SortByPublicationDate,
+		Status:     model.StatusPublished,
+		Descending: true,
+		Limit:      svc.feedLimit(),
+	})
+	if err != nil {
+		return feed{}, err
+	}
+	f := feed{
+		title:       feedTitle,
+		description: "The latest posts of " + feedTitle,
+		link:        baseURL + postsPath,
+		self:        baseURL + r.URL.Path,
+	}
+	for _, post := range page.Items {
+		item := feedItem{
+			id:         baseURL + postLocation(post.Id),
+			title:      post.Title,
+			link:       baseURL + postLocation(post.Id),
+			html:       svc.renderPost(post).ContentHtml,
+			categories: post.Tags,
+			published:  post.PublicationDate(),
+			updated:    lastChange(post.PublicationDate(), post.EditedAt),
+		}
+		if post.Slug != "" {
+			item.link = baseURL + postSlugLocation(post.Slug)
+		}
+		if post.Category != "" {
+			item.categories = append([]string{post.Category}, post.Tags...)
+		}
+		f.add(item)
+	}
+	f.fallBack(time.Now())
+	return f, nil
+}
+
+// commentsFeed holds the latest comments of a published post, newest first.
+// Deleted comments are left out.
+func (svc *RestApiService) commentsFeed(r *http.Request, baseURL string) (feed, error) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		return feed{}, err
+	}
+	post, err := svc.postRepository.GetById(id)
+	if err != nil {
+		return feed{}, storeError(err, "Post with id: %d does not exist", id)
+	}
+	if !post.Published() {
+		return feed{}, newApiError(http.StatusNotFound, "Post with id: %d does not exist", id)
+	}
+	page, err := svc.commentRepository.Query(id, repository.CommentQuery{Descending: true})
+	if err != nil {
+		return feed{}, err
+	}
+	f := feed{
+		title:       "Comments on " + post.Title,
+		description: "The latest comments on " + post.Title,
+		link:        baseURL + postLocation(post.Id),
+		self:        baseURL + r.URL.Path,
+	}
+	for _, comment := range page.Items {
+		if comment.Deleted {
+			continue
+		}
+		if len(f.items) == svc.feedLimit() {
+			break
+		}
+		f.add(feedItem{
+			id:        baseURL + commentLocation(comment.Id),
+			title:     "Comment by " + comment.Author,
+			link:      baseURL + commentLocation(comment.Id),
+			author:    comment.Author,
+			html:      markup.Render(model.FormatPlain, comment.Comment),
+			published: comment.CreationDate,
+			updated:   lastChange(comment.CreationDate, comment.EditedAt),
+		})
+	}
+	f.fallBack(post.PublicationDate())
+	return f, nil
+}
+
+func (f *feed) add(item feedItem) {
+	f.items = append(f.items, item)
+	if item.updated.After(f.updated) {
+		f.updated = item.updated
+	}
+}
+
+// fallBack dates a feed without items at updated, so that it has a time
+// for Atom and for answering conditional requests.
+func (f *feed) fallBack(updated time.Time) {
+	if f.updated.IsZero() {
+		f.updated = updated
+	}
+}
+
+func lastChange(created time.Time, edited *time.Time) time.Time {
+	if edited != nil && edited.After(created) {
+		return *edited
+	}
+	return created
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// encodeRss encodes the feed as RSS 2.0 with RFC 822 dates.
+func encodeRss(f feed) ([]byte, string, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Dc:      "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.title,
+			Link:        f.link,
+			Description: f.description,
+			Self:        atomLink{Rel: "self", Type: "application/rss+xml", Href: f.self},
+		},
+	}
+	if !f.updated.IsZero() {
+		doc.Channel.LastBuildDate = f.updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, item := range f.items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.title,
+			Link:        item.link,
+			Description: item.html,
+			Creator:     item.author,
+			Categories:  item.categories,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.id},
+			PubDate:     item.published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return encodeXml(doc, rssContentType)
+}
+
+type atomDocument struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Id       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomPerson  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// encodeAtom encodes the feed as Atom 1.0 with RFC 3339 dates.
+func encodeAtom(f feed) ([]byte, string, error) {
+	doc := atomDocument{
+		Title:    f.title,
+		Subtitle: f.description,
+		Id:       f.self,
+		Updated:  f.updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.self},
+			{Rel: "alternate", Href: f.link},
+		},
+		Author: atomPerson{Name: feedTitle},
+	}
+	for _, item := range f.items {
+		entry := atomEntry{
+			Title:     item.title,
+			Id:        item.id,
+			Link:      atomLink{Rel: "alternate", Href: item.link},
+			Published: item.published.UTC().Format(time.RFC3339),
+			Updated:   item.updated.UTC().Format(time.RFC3339),
+			Content:   atomContent{Type: "html", Body: item.html},
+		}
+		if item.author != "" {
+			entry.Author = &atomPerson{Name: item.author}
+		}
+		for _, category := range item.categories {
+			entry.Categories = append(entry.Categories, atomCategory{Term: category})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encodeXml(doc, atomContentType)
+}
+
+func encodeXml(doc interface{}, contentType string) ([]byte, string, error) {
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	return append([]byte(xml.Header), data...), contentType, nil
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func feedTestService(opts ...Option) (RestApiService, time.Time) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var edited = testDate.Add(48 * time.Hour)
+	posts := repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "first", Content: "first", CreationDate: testDate},
+		{Id: 2, Title: "second", Content: "*second*", Format: model.FormatMarkdown, Tags: []string{"go"}, Category: "dev", CreationDate: testDate.Add(time.Hour), EditedAt: &edited},
+		{Id: 3, Title: "third", Content: "third", CreationDate: testDate.Add(2 * time.Hour)},
+		{Id: 4, Title: "draft", Content: "draft", Status: model.StatusDraft, CreationDate: testDate.Add(3 * time.Hour)},
+	})
+	comments := repository.CustomCommentRepository([]model.Comment{
+		{Id: 10, PostId: 2, Comment: "a <comment>", Author: "author1", CreationDate: testDate.Add(4 * time.Hour)},
+		{Id: 11, PostId: 2, Comment: model.DeletedCommentPlaceholder, Author: model.DeletedCommentPlaceholder, Deleted: true, CreationDate: testDate.Add(5 * time.Hour)},
+		{Id: 12, PostId: 4, Comment: "early", Author: "author2", CreationDate: testDate.Add(4 * time.Hour)},
+	})
+	return NewRestApiServiceWithStores(posts, comments, opts...), edited
+}
+
+func TestRestApiService_feeds(t *testing.T) {
+	tests := []struct {
+		testName            string
+		path                string
+		expectedHttpStatus  int
+		expectedContentType string
+	}{
+		{testName: "testRss", path: rssFeedPath, expectedHttpStatus: 200, expectedContentType: rssContentType},
+		{testName: "testAtom", path: atomFeedPath, expectedHttpStatus: 200, expectedContentType: atomContentType},
+		{testName: "testCommentsRss", path: "/feeds/posts/2/comments/rss.xml", expectedHttpStatus: 200, expectedContentType: rssContentType},
+		{testName: "testCommentsAtom", path: "/feeds/posts/2/comments/atom.xml", expectedHttpStatus: 200, expectedContentType: atomContentType},
+		{testName: "testCommentsOfDraft", path: "/feeds/posts/4/comments/rss.xml", expectedHttpStatus: 404},
+		{testName: "testCommentsOfUnknownPost", path: "/feeds/posts/9/comments/atom.xml", expectedHttpStatus: 404},
+		{testName: "testCommentsWrongId", path: "/feeds/posts/abc/comments/rss.xml", expectedHttpStatus: 400},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc, _ := feedTestService()
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, w.Code)
+			if tc.expectedContentType != "" {
+				assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+				assert.NotEmpty(t, w.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestRestApiService_rssFeed(t *testing.T) {
+	// GIVEN
+	svc, edited := feedTestService(WithFeedSize(2), WithBaseURL("https://blog.example.com/"))
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, rssFeedPath, nil))
+
+	// THEN
+	require.Equal(t, 200, w.Code)
+	assert.Equal(t, edited.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	var doc rssDocument
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "2.0", doc.Version)
+	assert.Contains(t, w.Body.String(), "<link>https://blog.example.com/api/posts</link>")
+	assert.Contains(t, w.Body.String(), `<atom:link rel="self" type="application/rss+xml" href="https://blog.example.com/feeds/rss.xml">`)
+	assert.Equal(t, "Tue, 18 Sep 2018 12:00:00 +0000", doc.Channel.LastBuildDate)
+	require.Len(t, doc.Channel.Items, 2)
+	assert.Equal(t, rssItem{
+		Title:       "third",
+		Link:        "https://blog.example.com/api/posts/by-slug/third",
+		Description: "<p>third</p>\n",
+		GUID:        rssGUID{IsPermaLink: true, Value: "https://blog.example.com/api/posts/3"},
+		PubDate:     "Sun, 16 Sep 2018 14:00:00 +0000",
+	}, doc.Channel.Items[0])
+	assert.Equal(t, "<p><em>second</em></p>\n", doc.Channel.Items[1].Description)
+	assert.Equal(t, []string{"dev", "go"}, doc.Channel.Items[1].Categories)
+}
+
+func TestRestApiService_atomFeed(t *testing.T) {
+	// GIVEN
+	svc, _ := feedTestService()
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feeds/posts/2/comments/atom.xml", nil))
+
+	// THEN
+	require.Equal(t, 200, w.Code)
+	var doc atomDocument
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "Comments on second", doc.Title)
+	assert.Equal(t, "http://example.com/feeds/posts/2/comments/atom.xml", doc.Id)
+	assert.Equal(t, "2018-09-16T16:00:00Z", doc.Updated)
+	require.Len(t, doc.Entries, 1)
+	entry := doc.Entries[0]
+	assert.Equal(t, "http://example.com/api/comments/10", entry.Id)
+	assert.Equal(t, &atomPerson{Name: "author1"}, entry.Author)
+	assert.Equal(t, "2018-09-16T16:00:00Z", entry.Published)
+	assert.Equal(t, atomContent{Type: "html", Body: "<p>a &lt;comment&gt;</p>\n"}, entry.Content)
+}
+
+func TestRestApiService_feedConditionalRequests(t *testing.T) {
+	// GIVEN
+	svc, edited := feedTestService()
+	router := svc.router()
+	serve := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, atomFeedPath, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	etag := serve("", "").Header().Get("ETag")
+
+	// WHEN / THEN
+	assert.Equal(t, http.StatusNotModified, serve("If-None-Match", etag).Code)
+	assert.Equal(t, http.StatusOK, serve("If-None-Match", `"other"`).Code)
+	assert.Equal(t, http.StatusNotModified, serve("If-Modified-Since", edited.Format(http.TimeFormat)).Code)
+	assert.Equal(t, http.StatusOK, serve("If-Modified-Since", edited.Add(-time.Second).Format(http.TimeFormat)).Code)
+
+	require.NoError(t, svc.postRepository.Insert(model.Post{Id: 5, Title: "fifth", Content: "fifth", CreationDate: edited.Add(time.Hour)}))
+	w := serve("If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestRestApiService_feedOrderedByPublication(t *testing.T) {
+	// GIVEN
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var publishedAt = testDate.Add(72 * time.Hour)
+	svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "written first", Content: "content", Status: model.StatusPublished, PublishAt: &publishedAt, CreationDate: testDate},
+		{Id: 2, Title: "second", Content: "content", CreationDate: testDate.Add(time.Hour)},
+		{Id: 3, Title: "third", Content: "content", CreationDate: testDate.Add(2 * time.Hour)},
+	}), repository.NewCommentRepository(), WithFeedSize(2))
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, atomFeedPath, nil))
+
+	// THEN
+	require.Equal(t, 200, w.Code)
+	var doc atomDocument
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+	require.Len(t, doc.Entries, 2)
+	assert.Equal(t, "written first", doc.Entries[0].Title)
+	assert.Equal(t, "2018-09-19T12:00:00Z", doc.Entries[0].Published)
+	assert.Equal(t, "third", doc.Entries[1].Title)
+	assert.Equal(t, "2018-09-19T12:00:00Z", doc.Updated)
+}
+
+func TestRestApiService_emptyFeeds(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		testName        string
+		posts           []model.Post
+		path            string
+		expectedUpdated time.Time
+	}{
+		{testName: "testNoPosts", path: atomFeedPath},
+		{testName: "testOnlyDrafts", posts: []model.Post{{Id: 1, Title: "draft", Content: "content", Status: model.StatusDraft, CreationDate: testDate}},
+			path: atomFeedPath},
+		{testName: "testNoComments", posts: []model.Post{{Id: 1, Title: "post", Content: "content", CreationDate: testDate}},
+			path: "/feeds/posts/1/comments/atom.xml", expectedUpdated: testDate},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(tc.posts), repository.NewCommentRepository())
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			// THEN
+			require.Equal(t, 200, w.Code)
+			var doc atomDocument
+			require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+			assert.Empty(t, doc.Entries)
+			updated, err := time.Parse(time.RFC3339, doc.Updated)
+			require.NoError(t, err)
+			lastModified, err := http.ParseTime(w.Header().Get("Last-Modified"))
+			require.NoError(t, err)
+			if tc.expectedUpdated.IsZero() {
+				assert.WithinDuration(t, time.Now(), updated, time.Minute)
+			} else {
+				assert.Equal(t, tc.expectedUpdated, updated)
+			}
+			assert.Equal(t, updated, lastModified)
+			assert.NotEmpty(t, w.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// applyMergePatch applies an RFC 7396 JSON merge patch to the JSON
+// representation of original and decodes the result into target. Keys of the
+// patch match the fields of target ignoring case, as they do when decoding.
+func applyMergePatch(original interface{}, patch []byte, target interface{}) error {
+	data, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	var doc, changes interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return err
+	}
+	merged, err := json.Marshal(mergePatch(doc, canonicalKeys(changes, reflect.TypeOf(target))))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, target)
+}
+
+func mergePatch(doc, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	fields, ok := doc.(map[string]interface{})
+	if !ok {
+		fields = make(map[string]interface{})
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		fields[key] = mergePatch(fields[key], value)
+	}
+	return fields
+}
+
+// canonicalKeys renames the keys of patch objects to the JSON names of the
+// struct fields of t they decode into, so that they replace or remove those
+// fields whatever their case. A key spelled exactly like its field wins over
+// other spellings; keys matching no field are kept as they are.
+func canonicalKeys(patch interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	changes, ok := patch.(map[string]interface{})
+	if !ok || t.Kind() != reflect.Struct {
+		return patch
+	}
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonFieldName(t.Field(i)); ok {
+			fields[name] = t.Field(i).Type
+		}
+	}
+	known := jsonFieldNames(t)
+	renamed := make(map[string]interface{}, len(changes))
+	for key, value := range changes {
+		name, ok := matchFieldName(known, key)
+		if !ok {
+			renamed[key] = value
+			continue
+		}
+		if _, exact := changes[name]; exact && key != name {
+			continue
+		}
+		renamed[name] = canonicalKeys(value, fields[name])
+	}
+	return renamed
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	type nested struct {
+		A string `json:"a,omitempty"`
+		B string `json:"b,omitempty"`
+	}
+	type doc struct {
+		Title  string  `json:"title,omitempty"`
+		Count  int     `json:"count,omitempty"`
+		Nested *nested `json:"nested,omitempty"`
+	}
+	original := doc{Title: "title", Count: 3, Nested: &nested{A: "a", B: "b"}}
+
+	tests := []struct {
+		name     string
+		patch    string
+		expected doc
+		wantErr  bool
+	}{
+		{name: "replace field", patch: `{"title": "new"}`, expected: doc{Title: "new", Count: 3, Nested: &nested{A: "a", B: "b"}}},
+		{name: "remove field", patch: `{"count": null}`, expected: doc{Title: "title", Nested: &nested{A: "a", B: "b"}}},
+		{name: "merge nested object", patch: `{"nested": {"b": "c"}}`, expected: doc{Title: "title", Count: 3, Nested: &nested{A: "a", B: "c"}}},
+		{name: "replace field ignoring case", patch: `{"Title": "new"}`, expected: doc{Title: "new", Count: 3, Nested: &nested{A: "a", B: "b"}}},
+		{name: "remove field ignoring case", patch: `{"COUNT": null}`, expected: doc{Title: "title", Nested: &nested{A: "a", B: "b"}}},
+		{name: "merge nested object ignoring case", patch: `{"Nested": {"B": "c"}}`, expected: doc{Title: "title", Count: 3, Nested: &nested{A: "a", B: "c"}}},
+		{name: "exact key wins", patch: `{"TITLE": "other", "title": "new"}`, expected: doc{Title: "new", Count: 3, Nested: &nested{A: "a", B: "b"}}},
+		{name: "empty patch", patch: `{}`, expected: original},
+		{name: "invalid patch", patch: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result doc
+			err := applyMergePatch(original, []byte(tt.patch), &result)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"net/http"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// action is an operation guarded by the policy, phrased to complete
+// "is not allowed to".
+type action string
+
+const (
+	createPost      action = "create posts"
+	editPost        action = "edit posts"
+	deletePost      action = "delete posts"
+	readUnpublished action = "read unpublished posts"
+	createComment   action = "create comments"
+	editComment     action = "edit comments"
+	deleteComment   action = "delete comments"
+	manageUsers     action = "manage users"
+)
+
+// scope is how far a grant of an action reaches.
+type scope int
+
+const (
+	grantNone scope = iota
+	// grantOwn grants the action on what the user created themselves.
+	grantOwn
+	// grantAll grants the action on everything.
+	grantAll
+)
+
+var readerGrants = map[action]scope{
+	createComment: grantAll,
+	editComment:   grantOwn,
+	deleteComment: grantOwn,
+}
+
+// policy lists what every role may do; actions a role is not listed for are
+// denied.
+var policy = map[string]map[action]scope{
+	model.RoleReader: readerGrants,
+	model.RoleAuthor: withGrants(readerGrants, map[action]scope{
+		createPost:      grantAll,
+		editPost:        grantOwn,
+		deletePost:      grantOwn,
+		readUnpublished: grantOwn,
+	}),
+	model.RoleModerator: withGrants(readerGrants, map[action]scope{
+		deleteComment: grantAll,
+	}),
+	model.RoleEditor: withGrants(readerGrants, map[action]scope{
+		createPost:      grantAll,
+		editPost:        grantAll,
+		deletePost:      grantAll,
+		readUnpublished: grantAll,
+	}),
+	model.RoleAdmin: {
+		createPost:      grantAll,
+		editPost:        grantAll,
+		deletePost:      grantAll,
+		readUnpublished: grantAll,
+		createComment:   grantAll,
+		editComment:     grantAll,
+		deleteComment:   grantAll,
+		manageUsers:     grantAll,
+	},
+}
+
+var roles = []string{model.RoleReader, model.RoleAuthor, model.RoleModerator, model.RoleEditor, model.RoleAdmin}
+
+func withGrants(base map[action]scope, grants map[action]scope) map[action]scope {
+	merged := make(map[action]scope, len(base)+len(grants))
+	for a, s := range base {
+		merged[a] = s
+	}
+	for a, s := range grants {
+		merged[a] = s
+	}
+	return merged
+}
+
+// allowed reports whether the user may perform the action on something
+// owned by the user with ownerId, zero when it has no owner.
+func allowed(user model.User, a action, ownerId uint64) bool {
+	switch policy[user.UserRole()][a] {
+	case grantAll:
+		return true
+	case grantOwn:
+		return ownerId != 0 && ownerId == user.Id
+	}
+	return false
+}
+
+// authorize fails with 403 unless the caller may perform the action on
+// something owned by the user with ownerId. Anonymous callers only reach the
+// handlers of writes when users are disabled, see authenticate, so they are
+// not restricted.
+func (svc *RestApiService) authorize(r *http.Request, a action, ownerId uint64) error {
+	user := currentUser(r)
+	if user == nil || allowed(*user, a, ownerId) {
+		return nil
+	}
+	if policy[user.UserRole()][a] == grantOwn {
+		return newApiError(http.StatusForbidden, "user: %s is not allowed to %s of other users", user.Username, a)
+	}
+	return newApiError(http.StatusForbidden, "user: %s is not allowed to %s", user.Username, a)
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+func TestAllowed(t *testing.T) {
+	const userId, otherId = 1, 2
+
+	// every action with whether it is allowed on the user's own resources and
+	// on the resources of other users, in the order of actions below
+	actions := []action{createPost, editPost, deletePost, readUnpublished, createComment, editComment, deleteComment, manageUsers}
+	type grants struct{ own, other bool }
+	tests := []struct {
+		role string
+		want []grants
+	}{
+		{role: model.RoleReader, want: []grants{
+			{false, false}, {false, false}, {false, false}, {false, false},
+			{true, true}, {true, false}, {true, false}, {false, false},
+		}},
+		{role: "", want: []grants{
+			{false, false}, {false, false}, {false, false}, {false, false},
+			{true, true}, {true, false}, {true, false}, {false, false},
+		}},
+		{role: model.RoleAuthor, want: []grants{
+			{true, true}, {true, false}, {true, false}, {true, false},
+			{true, true}, {true, false}, {true, false}, {false, false},
+		}},
+		{role: model.RoleModerator, want: []grants{
+			{false, false}, {false, false}, {false, false}, {false, false},
+			{true, true}, {true, false}, {true, true}, {false, false},
+		}},
+		{role: model.RoleEditor, want: []grants{
+			{true, true}, {true, true}, {true, true}, {true, true},
+			{true, true}, {true, false}, {true, false}, {false, false},
+		}},
+		{role: model.RoleAdmin, want: []grants{
+			{true, true}, {true, true}, {true, true}, {true, true},
+			{true, true}, {true, true}, {true, true}, {true, true},
+		}},
+		{role: "unknown", want: []grants{
+			{false, false}, {false, false}, {false, false}, {false, false},
+			{false, false}, {false, false}, {false, false}, {false, false},
+		}},
+	}
+
+	for _, tt := range tests {
+		for i, a := range actions {
+			t.Run(tt.role+" may "+string(a), func(t *testing.T) {
+				// GIVEN
+				user := model.User{Id: userId, Username: "user", Role: tt.role}
+
+				// WHEN
+				own := allowed(user, a, userId)
+				other := allowed(user, a, otherId)
+
+				// THEN
+				assert.Equal(t, tt.want[i].own, own, "own")
+				assert.Equal(t, tt.want[i].other, other, "of other users")
+			})
+		}
+	}
+}
+
+func TestAllowed_unownedResources(t *testing.T) {
+	// resources created before users existed have no owner and may only be
+	// changed by roles allowed to change those of every user
+	assert.False(t, allowed(model.User{Id: 1, Role: model.RoleAuthor}, editPost, 0))
+	assert.True(t, allowed(model.User{Id: 1, Role: model.RoleEditor}, editPost, 0))
+}
+
+func TestRestApiService_authorization(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	serve := authServer(&svc)
+	tokens := map[string]string{}
+	for _, user := range []struct{ name, role string }{
+		{"admin", model.RoleAdmin},
+		{"reader", model.RoleReader},
+		{"author", model.RoleAuthor},
+		{"other-author", model.RoleAuthor},
+		{"moderator", model.RoleModerator},
+		{"editor", model.RoleEditor},
+	} {
+		if user.role == model.RoleAdmin {
+			tokens[user.name] = loginAdmin(t, &svc, serve)
+			continue
+		}
+		tokens[user.name] = login(t, serve, user.name)
+		if user.role != model.RoleReader {
+			w := serve(http.MethodPut, userLocation(uint64(len(tokens)))+"/role", tokens["admin"], `{"Role": "`+user.role+`"}`)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, postsPath, tokens["author"], `{"Title": "post", "Content": "content"}`).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, postsPath, tokens["author"], `{"Title": "draft", "Content": "content", "Status": "draft"}`).Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, commentsPath, tokens["reader"], `{"PostId": 1, "Comment": "comment"}`).Code)
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "reader creates post", user: "reader", method: http.MethodPost, path: postsPath, body: `{"Title": "t", "Content": "c"}`, want: http.StatusForbidden},
+		{name: "author creates post", user: "author", method: http.MethodPost, path: postsPath, body: `{"Title": "t", "Content": "c"}`, want: http.StatusOK},
+		{name: "moderator creates post", user: "moderator", method: http.MethodPost, path: postsPath, body: `{"Title": "t", "Content": "c"}`, want: http.StatusForbidden},
+		{name: "owner edits post", user: "author", method: http.MethodPatch, path: "/api/posts/1", body: `{"Content": "edited"}`, want: http.StatusOK},
+		{name: "other author edits post", user: "other-author", method: http.MethodPut, path: "/api/posts/1", body: `{"Title": "t", "Content": "c"}`, want: http.StatusForbidden},
+		{name: "other author restores revision", user: "other-author", method: http.MethodPost, path: "/api/posts/1/revisions/1/restore", want: http.StatusForbidden},
+		{name: "editor edits post", user: "editor", method: http.MethodPatch, path: "/api/posts/1", body: `{"Content": "edited again"}`, want: http.StatusOK},
+		{name: "reader reads draft", user: "reader", method: http.MethodGet, path: "/api/posts/2", want: http.StatusNotFound},
+		{name: "other author reads draft", user: "other-author", method: http.MethodGet, path: "/api/posts/2", want: http.StatusNotFound},
+		{name: "owner reads draft", user: "author", method: http.MethodGet, path: "/api/posts/2", want: http.StatusOK},
+		{name: "editor reads draft", user: "editor", method: http.MethodGet, path: "/api/posts/2", want: http.StatusOK},
+		{name: "author edits comment of other user", user: "author", method: http.MethodPatch, path: "/api/comments/1", body: `{"Comment": "edited"}`, want: http.StatusForbidden},
+		{name: "reader edits own comment", user: "reader", method: http.MethodPatch, path: "/api/comments/1", body: `{"Comment": "edited"}`, want: http.StatusOK},
+		{name: "editor deletes comment of other user", user: "editor", method: http.MethodDelete, path: "/api/comments/1", want: http.StatusForbidden},
+		{name: "moderator deletes comment", user: "moderator", method: http.MethodDelete, path: "/api/comments/1", want: http.StatusOK},
+		{name: "reader changes role", user: "reader", method: http.MethodPut, path: "/api/users/2/role", body: `{"Role": "admin"}`, want: http.StatusForbidden},
+		{name: "other author deletes post", user: "other-author", method: http.MethodDelete, path: "/api/posts/1", want: http.StatusForbidden},
+		{name: "owner deletes post", user: "author", method: http.MethodDelete, path: "/api/posts/1", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// WHEN
+			w := serve(tt.method, tt.path, tokens[tt.user], tt.body)
+
+			// THEN
+			require.Equal(t, tt.want, w.Code, w.Body.String())
+			if tt.want == http.StatusForbidden {
+				var problem ProblemResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&problem))
+				assert.Equal(t, "Forbidden", problem.Title)
+				assert.Contains(t, problem.Detail, "user: "+tt.user+" is not allowed to")
+			}
+		})
+	}
+}
+
+func TestRestApiService_setRole(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{name: "valid role", path: "/api/users/2/role", body: `{"Role": "editor"}`, want: http.StatusOK},
+		{name: "unknown role", path: "/api/users/2/role", body: `{"Role": "owner"}`, want: http.StatusUnprocessableEntity},
+		{name: "unknown user", path: "/api/users/3/role", body: `{"Role": "editor"}`, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiService()
+			serve := authServer(&svc)
+			admin := loginAdmin(t, &svc, serve)
+			user := login(t, serve, "user")
+
+			// WHEN
+			w := serve(http.MethodPut, tt.path, admin, tt.body)
+
+			// THEN
+			require.Equal(t, tt.want, w.Code, w.Body.String())
+			if tt.want == http.StatusOK {
+				w = serve(http.MethodGet, mePath, user, "")
+				var me UserResponse
+				require.NoError(t, json.NewDecoder(w.Body).Decode(&me))
+				assert.Equal(t, model.RoleEditor, me.Role)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const defaultPublishInterval = 30 * time.Second
+
+// schedulerAuthor is recorded as the author of the revisions publishing a
+// scheduled post.
+const schedulerAuthor = "scheduler"
+
+var postStatuses = map[string]bool{
+	model.StatusDraft:     true,
+	model.StatusScheduled: true,
+	model.StatusPublished: true,
+	model.StatusArchived:  true,
+}
+
+// WithPublishInterval sets how often scheduled posts are checked for being
+// due for publication.
+func WithPublishInterval(interval time.Duration) Option {
+	return func(svc *RestApiService) {
+		svc.publishInterval = interval
+	}
+}
+
+// canSeeUnpublished reports whether the caller may read the draft,
+// scheduled and archived posts owned by the user with ownerId, zero asking
+// for the posts of every user. Anonymous callers only see published posts.
+func (svc *RestApiService) canSeeUnpublished(r *http.Request, ownerId uint64) bool {
+	user := currentUser(r)
+	return user != nil && allowed(*user, readUnpublished, ownerId)
+}
+
+func (svc *RestApiService) postVisible(r *http.Request, post model.Post) bool {
+	return post.Published() || svc.canSeeUnpublished(r, post.OwnerId)
+}
+
+// visibleStatus is the status listings filter posts by, empty when the
+// caller may see every post, together with the id of the caller when they
+// may see their own unpublished posts too.
+func (svc *RestApiService) visibleStatus(r *http.Request) (string, uint64) {
+	if svc.canSeeUnpublished(r, 0) {
+		return "", 0
+	}
+	if user := currentUser(r); user != nil && svc.canSeeUnpublished(r, user.Id) {
+		return model.StatusPublished, user.Id
+	}
+	return model.StatusPublished, 0
+}
+
+// readablePost fetches the post with the given id, failing with 404 when it
+// does not exist or the caller may not see it.
+func (svc *RestApiService) readablePost(r *http.Request, id uint64) (*model.Post, error) {
+	post, err := svc.postRepository.GetById(id)
+	if err != nil {
+		return nil, storeError(err, "Post with id: %d does not exist", id)
+	}
+	if !svc.postVisible(r, *post) {
+		return nil, newApiError(http.StatusNotFound, "Post with id: %d does not exist", id)
+	}
+	return post, nil
+}
+
+// runScheduler publishes due posts every publishInterval until stop is closed.
+func (svc *RestApiService) runScheduler(stop <-chan struct{}) {
+	interval := svc.publishInterval
+	if interval <= 0 {
+		interval = defaultPublishInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := svc.publishDue(time.Now()); err != nil {
+			log.Printf("publishing scheduled posts failed, will retry: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue publishes every scheduled post whose PublishAt is not after now
+// and reports how many were published.
+func (svc *RestApiService) publishDue(now time.Time) (int, error) {
+	page, err := svc.postRepository.Query(repository.PostQuery{Status: model.StatusScheduled})
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, candidate := range page.Items {
+		if !candidate.Due(now) {
+			continue
+		}
+		// The post may have been edited, rescheduled or deleted since the
+		// query, so the repository publishes it only if it is still due.
+		post, ok, err := svc.postRepository.PublishDue(candidate.Id, now)
+		if errors.Is(err, repository.ErrNotFound) || err == nil && !ok {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		published++
+		svc.invalidateSitemap()
+		baseline := post
+		baseline.Status = model.StatusScheduled
+		if err := svc.recordBaseline(baseline); err != nil {
+			return published, err
+		}
+		if err := svc.recordRevision(post, schedulerAuthor); err != nil {
+			return published, err
+		}
+	}
+	return published, nil
+}
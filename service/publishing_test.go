@@ -0,0 +1,309 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestRestApiService_hidesUnpublishedPosts(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var publishAt = testDate.Add(24 * time.Hour)
+	var posts = []model.Post{
+		{Id: 1, Title: "published", Content: "content", Status: model.StatusPublished, Tags: []string{"go"}, CreationDate: testDate},
+		{Id: 2, Title: "draft", Content: "content", Status: model.StatusDraft, Tags: []string{"go", "secret"}, CreationDate: testDate},
+		{Id: 3, Title: "scheduled", Content: "content", Status: model.StatusScheduled, PublishAt: &publishAt, CreationDate: testDate},
+		{Id: 4, Title: "archived", Content: "content", Status: model.StatusArchived, CreationDate: testDate},
+	}
+	var comments = []model.Comment{
+		{Id: 10, PostId: 2, Comment: "early bird", Author: "author", CreationDate: testDate},
+	}
+
+	tests := []struct {
+		testName           string
+		method             string
+		path               string
+		reqBody            string
+		expectedHttpStatus int
+		expectedBody       string
+	}{
+		{testName: "testGetPublished", method: http.MethodGet, path: "/api/posts/1", expectedHttpStatus: 200},
+		{testName: "testGetDraft", method: http.MethodGet, path: "/api/posts/2", expectedHttpStatus: 404},
+		{testName: "testGetScheduled", method: http.MethodGet, path: "/api/posts/3", expectedHttpStatus: 404},
+		{testName: "testGetArchived", method: http.MethodGet, path: "/api/posts/4", expectedHttpStatus: 404},
+		{testName: "testGetDraftBySlug", method: http.MethodGet, path: "/api/posts/by-slug/draft", expectedHttpStatus: 404},
+		{testName: "testListPosts", method: http.MethodGet, path: "/api/posts", expectedHttpStatus: 200,
+			expectedBody: `{"items":[{"Id":1,"Title":"published","Slug":"published","Content":"content","CreationDate":"2018-09-16T12:00:00Z","Tags":["go"],"Status":"published"}],"total":1}`},
+		{testName: "testListTags", method: http.MethodGet, path: "/api/tags", expectedHttpStatus: 200,
+			expectedBody: `[{"tag":"go","count":1}]`},
+		{testName: "testListTagPosts", method: http.MethodGet, path: "/api/tags/secret/posts", expectedHttpStatus: 200,
+			expectedBody: `{"items":[],"total":0}`},
+		{testName: "testListDraftComments", method: http.MethodGet, path: "/api/posts/comments/2", expectedHttpStatus: 404},
+		{testName: "testGetDraftComment", method: http.MethodGet, path: "/api/comments/10", expectedHttpStatus: 404},
+		{testName: "testCommentOnDraft", method: http.MethodPost, path: commentsPath,
+			reqBody: `{"PostId": 2, "Comment": "comment", "Author": "author"}`, expectedHttpStatus: 404},
+		{testName: "testEditDraft", method: http.MethodPatch, path: "/api/posts/2",
+			reqBody: `{"Content": "edited"}`, expectedHttpStatus: 200},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.CustomCommentRepository(comments))
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestRestApiService_postStatus(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var publishAt = testDate.Add(24 * time.Hour)
+	var scheduledPost = model.Post{Id: 3, Title: "scheduled", Content: "content", Status: model.StatusScheduled, PublishAt: &publishAt, CreationDate: testDate}
+
+	tests := []struct {
+		testName           string
+		method             string
+		path               string
+		reqBody            string
+		expectedHttpStatus int
+		expectedStatus     string
+		expectedPublishAt  *time.Time
+		expectedFieldError *FieldError
+	}{
+		{testName: "testAddDefaultsToPublished", method: http.MethodPost, path: postsPath,
+			reqBody: `{"Id": 5, "Title": "title", "Content": "content"}`, expectedHttpStatus: 200, expectedStatus: model.StatusPublished},
+		{testName: "testAddDraft", method: http.MethodPost, path: postsPath,
+			reqBody: `{"Id": 5, "Title": "title", "Content": "content", "Status": "draft"}`, expectedHttpStatus: 200, expectedStatus: model.StatusDraft},
+		{testName: "testAddUnknownStatus", method: http.MethodPost, path: postsPath,
+			reqBody: `{"Title": "title", "Content": "content", "Status": "hidden"}`, expectedHttpStatus: 422,
+			expectedFieldError: &FieldError{Field: "Status", Reason: "must be one of draft, scheduled, published or archived"}},
+		{testName: "testAddScheduledWithoutPublishAt", method: http.MethodPost, path: postsPath,
+			reqBody: `{"Title": "title", "Content": "content", "Status": "scheduled"}`, expectedHttpStatus: 422,
+			expectedFieldError: &FieldError{Field: "PublishAt", Reason: "is required for scheduled posts"}},
+		{testName: "testReplaceKeepsStatus", method: http.MethodPut, path: "/api/posts/3",
+			reqBody: `{"Title": "title", "Content": "content"}`, expectedHttpStatus: 200,
+			expectedStatus: model.StatusScheduled, expectedPublishAt: &publishAt},
+		{testName: "testPatchPublishes", method: http.MethodPatch, path: "/api/posts/3",
+			reqBody: `{"Status": "published", "PublishAt": null}`, expectedHttpStatus: 200, expectedStatus: model.StatusPublished},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			postRepository := repository.CustomPostRepository([]model.Post{scheduledPost})
+			svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository())
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			require.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedFieldError != nil {
+				var problem ProblemResponse
+				require.NoError(t, json.Unmarshal(body, &problem))
+				assert.Equal(t, []FieldError{*tc.expectedFieldError}, problem.Errors)
+				return
+			}
+			id := uint64(5)
+			if tc.method != http.MethodPost {
+				id = scheduledPost.Id
+			}
+			post, err := postRepository.GetById(id)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedStatus, post.Status)
+			assert.Equal(t, tc.expectedPublishAt, post.PublishAt)
+		})
+	}
+}
+
+func TestRestApiService_publishDue(t *testing.T) {
+	// GIVEN
+	var now = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var past, future = now.Add(-time.Minute), now.Add(time.Minute)
+	postRepository := repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "due", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now},
+		{Id: 2, Title: "due now", Status: model.StatusScheduled, PublishAt: &now, CreationDate: now},
+		{Id: 3, Title: "later", Status: model.StatusScheduled, PublishAt: &future, CreationDate: now},
+		{Id: 4, Title: "draft", Status: model.StatusDraft, PublishAt: &past, CreationDate: now},
+	})
+	svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository())
+
+	// WHEN
+	published, err := svc.publishDue(now)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	page, err := postRepository.Query(repository.PostQuery{Status: model.StatusPublished})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, []uint64{page.Items[0].Id, page.Items[1].Id})
+	assert.Equal(t, 2, page.Total)
+	revisions := svc.revisionRepository.GetAllByPostId(1)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, model.StatusScheduled, revisions[0].Status)
+	assert.Equal(t, schedulerAuthor, revisions[1].Author)
+	assert.Equal(t, model.StatusPublished, revisions[1].Status)
+
+	published, err = svc.publishDue(future)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+}
+
+// staleQueryPostStore answers queries with the posts as they were when it
+// was created, like a query racing with later writes.
+type staleQueryPostStore struct {
+	*repository.PostRepository
+	page repository.PostPage
+}
+
+func (s staleQueryPostStore) Query(repository.PostQuery) (repository.PostPage, error) {
+	return s.page, nil
+}
+
+func TestRestApiService_publishDueAfterConcurrentWrites(t *testing.T) {
+	// GIVEN
+	var now = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var past, future = now.Add(-time.Minute), now.Add(time.Minute)
+	posts := []model.Post{
+		{Id: 1, Title: "edited", Content: "content", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now},
+		{Id: 2, Title: "back to draft", Content: "content", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now},
+		{Id: 3, Title: "rescheduled", Content: "content", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now},
+		{Id: 4, Title: "deleted", Content: "content", Status: model.StatusScheduled, PublishAt: &past, CreationDate: now},
+	}
+	postRepository := repository.CustomPostRepository(posts)
+	svc := NewRestApiServiceWithStores(staleQueryPostStore{postRepository, repository.PostPage{Items: posts, Total: len(posts)}}, repository.NewCommentRepository())
+	edited, draft, rescheduled := posts[0], posts[1], posts[2]
+	edited.Content = "edited content"
+	draft.Status = model.StatusDraft
+	rescheduled.PublishAt = &future
+	for _, post := range []model.Post{edited, draft, rescheduled} {
+		require.NoError(t, postRepository.Update(post))
+	}
+	require.NoError(t, postRepository.Delete(4))
+
+	// WHEN
+	published, err := svc.publishDue(now)
+
+	// THEN
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	stored, err := postRepository.GetById(1)
+	require.NoError(t, err)
+	assert.True(t, stored.Published())
+	assert.Equal(t, "edited content", stored.Content)
+	for _, id := range []uint64{2, 3} {
+		stored, err := postRepository.GetById(id)
+		require.NoError(t, err)
+		assert.False(t, stored.Published(), "post %d", id)
+	}
+}
+
+func TestRestApiService_runScheduler(t *testing.T) {
+	// GIVEN
+	past := time.Now().Add(-time.Minute)
+	postRepository := repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "due", Status: model.StatusScheduled, PublishAt: &past, CreationDate: past},
+	})
+	svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository(), WithPublishInterval(time.Millisecond))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	// WHEN
+	go func() {
+		svc.runScheduler(stop)
+		close(done)
+	}()
+
+	// THEN
+	assert.Eventually(t, func() bool {
+		post, err := postRepository.GetById(1)
+		return err == nil && post.Published()
+	}, time.Second, time.Millisecond)
+	close(stop)
+	<-done
+}
+
+func TestRestApiService_listsOwnUnpublishedPosts(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	serve := authServer(&svc)
+	tokens := map[string]string{"anonymous": "", "admin": loginAdmin(t, &svc, serve)}
+	for _, user := range []struct{ name, role string }{
+		{"reader", model.RoleReader},
+		{"author", model.RoleAuthor},
+		{"other-author", model.RoleAuthor},
+		{"editor", model.RoleEditor},
+	} {
+		tokens[user.name] = login(t, serve, user.name)
+		if user.role != model.RoleReader {
+			w := serve(http.MethodPut, userLocation(uint64(len(tokens)-1))+"/role", tokens["admin"], `{"Role": "`+user.role+`"}`)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	for _, post := range []struct{ user, body string }{
+		{"author", `{"Title": "published", "Content": "content", "Tags": ["go"]}`},
+		{"author", `{"Title": "draft", "Content": "content", "Status": "draft", "Tags": ["go", "secret"]}`},
+		{"other-author", `{"Title": "other draft", "Content": "content", "Status": "draft", "Tags": ["hidden"]}`},
+	} {
+		require.Equal(t, http.StatusOK, serve(http.MethodPost, postsPath, tokens[post.user], post.body).Code)
+	}
+
+	tests := []struct {
+		user           string
+		expectedTitles []string
+		expectedTags   string
+	}{
+		{user: "anonymous", expectedTitles: []string{"published"}, expectedTags: `[{"tag":"go","count":1}]`},
+		{user: "reader", expectedTitles: []string{"published"}, expectedTags: `[{"tag":"go","count":1}]`},
+		{user: "author", expectedTitles: []string{"published", "draft"}, expectedTags: `[{"tag":"go","count":2},{"tag":"secret","count":1}]`},
+		{user: "other-author", expectedTitles: []string{"published", "other draft"}, expectedTags: `[{"tag":"go","count":1},{"tag":"hidden","count":1}]`},
+		{user: "editor", expectedTitles: []string{"published", "draft", "other draft"}, expectedTags: `[{"tag":"go","count":2},{"tag":"hidden","count":1},{"tag":"secret","count":1}]`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.user, func(t *testing.T) {
+			// WHEN
+			posts := serve(http.MethodGet, postsPath, tokens[tc.user], "")
+			tags := serve(http.MethodGet, "/api/tags", tokens[tc.user], "")
+
+			// THEN
+			require.Equal(t, http.StatusOK, posts.Code)
+			var page PostListResponse
+			require.NoError(t, json.NewDecoder(posts.Body).Decode(&page))
+			var titles []string
+			for _, post := range page.Items {
+				titles = append(titles, post.Title)
+			}
+			assert.ElementsMatch(t, tc.expectedTitles, titles)
+			assert.Equal(t, len(tc.expectedTitles), page.Total)
+			require.Equal(t, http.StatusOK, tags.Code)
+			assert.JSONEq(t, tc.expectedTags, tags.Body.String())
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"net/http"
+
+	"bitbucket.org/mindera/go-rest-blog/markup"
+	"bitbucket.org/mindera/go-rest-blog/model"
+)
+
+// renderCacheSize is the number of posts whose rendered content is cached.
+const renderCacheSize = 1000
+
+const renderHtml = "html"
+
+// PostResponse is a post served with ?render=html, carrying its content
+// rendered as sanitized HTML next to the source.
+type PostResponse struct {
+	model.Post
+	ContentHtml string
+}
+
+type RenderedPostListResponse struct {
+	Items      []PostResponse `json:"items"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+	Total      int            `json:"total"`
+}
+
+// parseRender reports whether the request asks for rendered content.
+func parseRender(r *http.Request) (bool, error) {
+	switch value := r.URL.Query().Get("render"); value {
+	case "":
+		return false, nil
+	case renderHtml:
+		return true, nil
+	default:
+		return false, newApiError(http.StatusBadRequest, "wrong render query parameter: %s, expected %s", value, renderHtml)
+	}
+}
+
+// writePost answers with the post, rendering its content when asked to.
+func (svc *RestApiService) writePost(w http.ResponseWriter, r *http.Request, post model.Post) {
+	render, err := parseRender(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if !render {
+		writeJsonResponse(w, http.StatusOK, post)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, svc.renderPost(post))
+}
+
+func (svc *RestApiService) renderPost(post model.Post) PostResponse {
+	if svc.renderCache == nil {
+		return PostResponse{Post: post, ContentHtml: markup.Render(post.ContentFormat(), post.Content)}
+	}
+	return PostResponse{Post: post, ContentHtml: svc.renderCache.Render(post)}
+}
+
+func (svc *RestApiService) invalidateRendered(postId uint64) {
+	if svc.renderCache != nil {
+		svc.renderCache.Invalidate(postId)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestRestApiService_renderHtml(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 1, Title: "markdown", Content: "*hi* <script>alert(1)</script>", Format: model.FormatMarkdown, CreationDate: testDate},
+		{Id: 2, Title: "plain", Content: "a <b>", CreationDate: testDate.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		testName           string
+		path               string
+		expectedHttpStatus int
+		expectedBody       string
+	}{
+		{testName: "testGetPostRendered", path: "/api/posts/1?render=html", expectedHttpStatus: 200,
+			expectedBody: `{"Id":1,"Title":"markdown","Slug":"markdown","Content":"*hi* <script>alert(1)</script>","Format":"markdown",` +
+				`"CreationDate":"2018-09-16T12:00:00Z","ContentHtml":"<p><em>hi</em> </p>\n"}`},
+		{testName: "testGetPostNotRendered", path: "/api/posts/2", expectedHttpStatus: 200,
+			expectedBody: `{"Id":2,"Title":"plain","Slug":"plain","Content":"a <b>","CreationDate":"2018-09-16T13:00:00Z"}`},
+		{testName: "testGetPostBySlugRendered", path: "/api/posts/by-slug/plain?render=html", expectedHttpStatus: 200,
+			expectedBody: `{"Id":2,"Title":"plain","Slug":"plain","Content":"a <b>","CreationDate":"2018-09-16T13:00:00Z","ContentHtml":"<p>a &lt;b&gt;</p>\n"}`},
+		{testName: "testListPostsRendered", path: "/api/posts?render=html&order=asc", expectedHttpStatus: 200,
+			expectedBody: `{"items":[{"Id":1,"Title":"markdown","Slug":"markdown","Content":"*hi* <script>alert(1)</script>","Format":"markdown",` +
+				`"CreationDate":"2018-09-16T12:00:00Z","ContentHtml":"<p><em>hi</em> </p>\n"},` +
+				`{"Id":2,"Title":"plain","Slug":"plain","Content":"a <b>","CreationDate":"2018-09-16T13:00:00Z","ContentHtml":"<p>a &lt;b&gt;</p>\n"}],"total":2}`},
+		{testName: "testWrongRender", path: "/api/posts/1?render=pdf", expectedHttpStatus: 400},
+		{testName: "testWrongRenderOnList", path: "/api/posts?render=pdf", expectedHttpStatus: 400},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository())
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestRestApiService_renderCacheInvalidatedOnUpdate(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "title", Content: "# old", Format: model.FormatMarkdown, CreationDate: time.Now()},
+	}), repository.NewCommentRepository())
+	router := svc.router()
+	render := func() PostResponse {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/posts/1?render=html", nil))
+		require.Equal(t, 200, w.Code)
+		var post PostResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&post))
+		return post
+	}
+	require.Equal(t, "<h1>old</h1>\n", render().ContentHtml)
+
+	// WHEN
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/posts/1", strings.NewReader(`{"Title": "title", "Content": "# new"}`)))
+	require.Equal(t, 200, w.Code)
+
+	// THEN
+	post := render()
+	assert.Equal(t, model.FormatMarkdown, post.Format)
+	assert.Equal(t, "<h1>new</h1>\n", post.ContentHtml)
+	assert.Equal(t, 1, svc.renderCache.Len())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/posts/1", nil))
+	require.Equal(t, 200, w.Code)
+	assert.Zero(t, svc.renderCache.Len())
+}
@@ -2,78 +2,302 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"bitbucket.org/mindera/go-rest-blog/auth"
+	"bitbucket.org/mindera/go-rest-blog/markup"
 	"bitbucket.org/mindera/go-rest-blog/model"
 	"bitbucket.org/mindera/go-rest-blog/repository"
+	"bitbucket.org/mindera/go-rest-blog/search"
 )
 
 type RestApiService struct {
-	postRepository    *repository.PostRepository
-	commentRepository *repository.CommentRepository
+	postRepository    repository.PostStore
+	commentRepository repository.CommentStore
+	// legacyErrors renders errors as AckJsonResponse instead of problem details.
+	legacyErrors bool
+	// searchIndex serves /api/search when set.
+	searchIndex *search.Index
+	// publishInterval is how often the scheduler publishes due posts.
+	publishInterval time.Duration
+	// revisionRepository keeps every version of every post. Revisions are
+	// neither recorded nor served when it is nil.
+	revisionRepository repository.RevisionStore
+	// renderCache keeps the rendered content of posts; content is rendered on
+	// every request when it is nil.
+	renderCache *markup.Cache
+	// feedSize is the number of items in a feed, defaultFeedSize when unset.
+	feedSize int
+	// baseURL is the absolute URL feeds and the sitemap link to, see WithBaseURL.
+	baseURL string
+	// sitemap caches /sitemap.xml; it is generated on every request when nil.
+	sitemap *sitemapCache
+	// robots are the rules served from /robots.txt, defaultRobots when empty.
+	robots string
+	// userRepository keeps user accounts. Callers are not authenticated and
+	// the user routes are not registered when it is nil.
+	userRepository repository.UserStore
+	// tokens issues and verifies the bearer tokens of users.
+	tokens *auth.Signer
+	// tokenTTL is how long issued tokens are valid, defaultTokenTTL when unset.
+	tokenTTL time.Duration
+	// apiKeyRepository keeps the API keys of users; API keys are not
+	// accepted when it is nil.
+	apiKeyRepository repository.ApiKeyStore
+}
+
+type Option func(*RestApiService)
+
+// WithLegacyErrors answers errors with the AckJsonResponse bodies served
+// before the API switched to RFC 7807 problem details.
+func WithLegacyErrors() Option {
+	return func(svc *RestApiService) {
+		svc.legacyErrors = true
+	}
+}
+
+// WithSearch serves full-text queries from the index. The index is expected
+// to be kept up to date by the stores, see search.Wrap.
+func WithSearch(index *search.Index) Option {
+	return func(svc *RestApiService) {
+		svc.searchIndex = index
+	}
+}
+
+type PostListResponse struct {
+	Items      []model.Post `json:"items"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+	Total      int          `json:"total"`
 }
 
 type AckJsonResponse struct {
 	Message string `json:"message"`
 	Status  int    `json:"status"`
+	Id      uint64 `json:"id,omitempty"`
 }
 
-func NewRestApiService() RestApiService {
-	return RestApiService{
-		postRepository:    repository.NewPostRepository(),
-		commentRepository: repository.NewCommentRepository(),
+func NewRestApiService(opts ...Option) RestApiService {
+	return NewRestApiServiceWithStores(repository.NewPostRepository(), repository.NewCommentRepository(), opts...)
+}
+
+func NewRestApiServiceWithStores(posts repository.PostStore, comments repository.CommentStore, opts ...Option) RestApiService {
+	svc := RestApiService{
+		postRepository:     posts,
+		commentRepository:  comments,
+		revisionRepository: repository.NewRevisionRepository(),
+		renderCache:        markup.NewCache(renderCacheSize),
+		sitemap:            newSitemapCache(),
+		userRepository:     repository.NewUserRepository(),
+		tokens:             auth.NewSigner(randomTokenKey()),
+		apiKeyRepository:   repository.NewApiKeyRepository(),
+	}
+	for _, opt := range opts {
+		opt(&svc)
 	}
+	return svc
 }
 
 func (svc *RestApiService) ServeContent(port int) error {
 	portString := ":" + strconv.Itoa(port)
 	svc.initializeHandlers()
+	stop := make(chan struct{})
+	defer close(stop)
+	go svc.runScheduler(stop)
 	return http.ListenAndServe(portString, nil)
 }
 
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxCommentDepth is the deepest a reply may be nested, top-level comments having depth zero.
+	maxCommentDepth = 5
+)
+
+const (
+	treeView = "tree"
+	flatView = "flat"
+)
+
 const (
 	postsPath      = "/api/posts"
+	tagsPath       = "/api/tags"
+	tagPostsPath   = tagsPath + "/{tag}/posts"
 	getPostPath    = postsPath + "/{id}"
+	postSlugPath   = postsPath + "/by-slug/{slug}"
 	commentsPath   = "/api/posts/comments"
 	getCommentPath = commentsPath + "/{id}"
+	commentPath    = "/api/comments/{id}"
+	searchPath     = "/api/search"
+	revisionsPath  = getPostPath + "/revisions"
+	revisionPath   = revisionsPath + "/{number:[0-9]+}"
+	restorePath    = revisionPath + "/restore"
+	diffPath       = revisionsPath + "/diff"
 )
 
 func (svc *RestApiService) initializeHandlers() {
+	http.Handle("/", svc.handler())
+}
+
+func (svc *RestApiService) router() *mux.Router {
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(svc.handleNotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(svc.handleMethodNotAllowed)
 
-	r.HandleFunc(postsPath, svc.handleAddPost).Methods(http.MethodPost)
-	r.HandleFunc(getPostPath, svc.handleGetPostByPostId).Methods(http.MethodGet)
-	r.HandleFunc(getCommentPath, svc.handleGetCommentsByPostId).Methods(http.MethodGet)
-	r.HandleFunc(commentsPath, svc.handleAddComment).Methods(http.MethodPost)
-	http.Handle("/", r)
+	r.HandleFunc(postsPath, svc.scoped(model.ScopePostsWrite, svc.handleAddPost)).Methods(http.MethodPost)
+	r.HandleFunc(postsPath, svc.scoped(model.ScopePostsRead, svc.handleListPosts)).Methods(http.MethodGet)
+	r.HandleFunc(getPostPath, svc.scoped(model.ScopePostsRead, svc.handleGetPostByPostId)).Methods(http.MethodGet)
+	r.HandleFunc(postSlugPath, svc.scoped(model.ScopePostsRead, svc.handleGetPostBySlug)).Methods(http.MethodGet)
+	r.HandleFunc(getPostPath, svc.scoped(model.ScopePostsWrite, svc.handleUpdatePost)).Methods(http.MethodPut)
+	r.HandleFunc(getPostPath, svc.scoped(model.ScopePostsWrite, svc.handlePatchPost)).Methods(http.MethodPatch)
+	r.HandleFunc(getPostPath, svc.scoped(model.ScopePostsWrite, svc.handleDeletePost)).Methods(http.MethodDelete)
+	r.HandleFunc(getCommentPath, svc.scoped(model.ScopeCommentsRead, svc.handleGetCommentsByPostId)).Methods(http.MethodGet)
+	r.HandleFunc(commentsPath, svc.scoped(model.ScopeCommentsWrite, svc.handleAddComment)).Methods(http.MethodPost)
+	r.HandleFunc(commentPath, svc.scoped(model.ScopeCommentsRead, svc.handleGetComment)).Methods(http.MethodGet)
+	r.HandleFunc(commentPath, svc.scoped(model.ScopeCommentsWrite, svc.handleUpdateComment)).Methods(http.MethodPut)
+	r.HandleFunc(commentPath, svc.scoped(model.ScopeCommentsWrite, svc.handlePatchComment)).Methods(http.MethodPatch)
+	r.HandleFunc(commentPath, svc.scoped(model.ScopeCommentsWrite, svc.handleDeleteComment)).Methods(http.MethodDelete)
+	r.HandleFunc(tagsPath, svc.scoped(model.ScopePostsRead, svc.handleListTags)).Methods(http.MethodGet)
+	r.HandleFunc(tagPostsPath, svc.scoped(model.ScopePostsRead, svc.handleListPostsByTag)).Methods(http.MethodGet)
+	r.HandleFunc(rssFeedPath, svc.feedHandler(svc.postsFeed, encodeRss)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(atomFeedPath, svc.feedHandler(svc.postsFeed, encodeAtom)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(commentsRssFeedPath, svc.feedHandler(svc.commentsFeed, encodeRss)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(commentsAtomFeedPath, svc.feedHandler(svc.commentsFeed, encodeAtom)).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(sitemapPath, svc.handleSitemap).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(sitemapPagePath, svc.handleSitemapPage).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc(robotsPath, svc.handleRobots).Methods(http.MethodGet, http.MethodHead)
+	if svc.searchIndex != nil {
+		r.HandleFunc(searchPath, svc.scoped(model.ScopePostsRead, svc.handleSearch)).Methods(http.MethodGet)
+	}
+	if svc.revisionRepository != nil {
+		r.HandleFunc(revisionsPath, svc.scoped(model.ScopePostsRead, svc.handleListRevisions)).Methods(http.MethodGet)
+		r.HandleFunc(diffPath, svc.scoped(model.ScopePostsRead, svc.handleDiffRevisions)).Methods(http.MethodGet)
+		r.HandleFunc(revisionPath, svc.scoped(model.ScopePostsRead, svc.handleGetRevision)).Methods(http.MethodGet)
+		r.HandleFunc(restorePath, svc.scoped(model.ScopePostsWrite, svc.handleRestoreRevision)).Methods(http.MethodPost)
+	}
+	if svc.userRepository != nil {
+		r.HandleFunc(usersPath, svc.scoped(noScope, svc.handleRegister)).Methods(http.MethodPost)
+		r.HandleFunc(mePath, svc.scoped(noScope, svc.handleGetCurrentUser)).Methods(http.MethodGet)
+		r.HandleFunc(userPath, svc.scoped(noScope, svc.handleGetUser)).Methods(http.MethodGet)
+		r.HandleFunc(rolePath, svc.scoped(noScope, svc.handleSetRole)).Methods(http.MethodPut)
+		r.HandleFunc(tokensPath, svc.scoped(noScope, svc.handleIssueToken)).Methods(http.MethodPost)
+	}
+	if svc.userRepository != nil && svc.apiKeyRepository != nil {
+		r.HandleFunc(apiKeysPath, svc.scoped(noScope, svc.handleCreateApiKey)).Methods(http.MethodPost)
+		r.HandleFunc(apiKeysPath, svc.scoped(noScope, svc.handleListApiKeys)).Methods(http.MethodGet)
+		r.HandleFunc(apiKeyPath, svc.scoped(noScope, svc.handleGetApiKey)).Methods(http.MethodGet)
+		r.HandleFunc(apiKeyPath, svc.scoped(noScope, svc.handleRevokeApiKey)).Methods(http.MethodDelete)
+	}
+	return r
 }
 
 func (svc *RestApiService) handleAddPost(w http.ResponseWriter, r *http.Request) {
+	if err := svc.authorize(r, createPost, 0); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
 	var post model.Post
 
-	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
-		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+	present, fieldErrors, err := decodeRequestPayload(r, &post)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize post json payload"))
 		return
 	}
-	if err := svc.postRepository.Insert(post); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if fieldErrors = mergeFieldErrors(fieldErrors, validatePost(post, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	// Slugs are always generated from the title.
+	post.Slug = ""
+	post.OwnerId = 0
+	post.EditedAt = nil
+	if user := currentUser(r); user != nil {
+		post.OwnerId = user.Id
+	}
+	if post.Status == "" {
+		post.Status = model.StatusPublished
+	}
+	post, err = svc.postRepository.Create(post)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d already exists in the database", post.Id))
+		return
+	}
+	svc.invalidateSitemap()
+	if err := svc.recordRevision(post, revisionAuthor(r)); err != nil {
+		svc.writeError(w, r, revisionError(post.Id, err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	data, err := json.Marshal(&AckJsonResponse{Message: fmt.Sprintf("post id: %d successfully added", post.Id), Status: http.StatusOK})
+	w.Header().Set("Location", postLocation(post.Id))
+	writeJsonResponse(w, http.StatusOK, AckJsonResponse{Message: fmt.Sprintf("post id: %d successfully added", post.Id), Status: http.StatusOK, Id: post.Id})
+}
+
+// handleListPosts serves GET /api/posts?limit=&cursor=&sort=creationDate|title&order=asc|desc&from=&to=&tag=&category=
+// where from and to are RFC 3339 timestamps bounding the creation date.
+func (svc *RestApiService) handleListPosts(w http.ResponseWriter, r *http.Request) {
+	query, err := parsePostQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		svc.writeError(w, r, err)
 		return
 	}
+	svc.writePostPage(w, r, query)
+}
 
-	if _, err := w.Write(data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+func (svc *RestApiService) handleListTags(w http.ResponseWriter, r *http.Request) {
+	status, anyStatusOwner := svc.visibleStatus(r)
+	writeJsonResponse(w, http.StatusOK, svc.postRepository.Tags(status, anyStatusOwner))
+}
+
+// handleListPostsByTag serves GET /api/tags/{tag}/posts, accepting the query
+// parameters of handleListPosts except for tag.
+func (svc *RestApiService) handleListPostsByTag(w http.ResponseWriter, r *http.Request) {
+	tag := mux.Vars(r)["tag"]
+	if repository.NormalizeTag(tag) == "" {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "wrong tag path variable: %s", tag))
+		return
+	}
+	query, err := parsePostQuery(r.URL.Query())
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	query.Tag = tag
+	svc.writePostPage(w, r, query)
+}
+
+func (svc *RestApiService) writePostPage(w http.ResponseWriter, r *http.Request, query repository.PostQuery) {
+	render, err := parseRender(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	query.Status, query.AnyStatusOwner = svc.visibleStatus(r)
+	page, err := svc.postRepository.Query(query)
+	if err != nil {
+		svc.writeError(w, r, queryError(err, query.Cursor))
+		return
+	}
+	if render {
+		rendered := make([]PostResponse, 0, len(page.Items))
+		for _, post := range page.Items {
+			rendered = append(rendered, svc.renderPost(post))
+		}
+		writeJsonResponse(w, http.StatusOK, RenderedPostListResponse{Items: rendered, NextCursor: page.NextCursor, Total: page.Total})
+		return
 	}
+	items := page.Items
+	if items == nil {
+		items = []model.Post{}
+	}
+	writeJsonResponse(w, http.StatusOK, PostListResponse{Items: items, NextCursor: page.NextCursor, Total: page.Total})
 }
 
 func (svc *RestApiService) handleGetPostByPostId(w http.ResponseWriter, r *http.Request) {
@@ -89,33 +313,189 @@ func (svc *RestApiService) handleGetPostByPostId(w http.ResponseWriter, r *http.
 	//  should respond with valid post entity when post with given id exists:
 	//  e.g. GET /api/posts/2 --> {"Id": 2, "Title": "test title", "Content": "this is a post content", "CreationDate": "1970-01-01T03:46:40+01:00"}
 
-	// Given that this project uses gorilla/mux as a router you can access the path params with following code:
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	w.Header().Set("Content-Type", "application/json")
+	id, err := parseIdPathVariable(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: fmt.Sprintf("wrong id path variable: %s", vars["id"]),
-			Status:  http.StatusBadRequest,
-		})
-		w.Write(response)
+		svc.writeError(w, r, err)
 		return
 	}
-	res, err := svc.postRepository.GetById(uint64(id))
+	res, err := svc.readablePost(r, id)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: fmt.Sprintf("Post with id: %d does not exist", id),
-			Status:  http.StatusNotFound,
-		})
-		w.Write(response)
+		svc.writeError(w, r, err)
 		return
 	}
-	response, _ := json.Marshal(res)
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	svc.writePost(w, r, *res)
+}
 
+// handleGetPostBySlug serves GET /api/posts/by-slug/{slug}. A slug the post
+// had before its title was changed is permanently redirected to the current one.
+func (svc *RestApiService) handleGetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	post, err := svc.postRepository.GetBySlug(slug)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with slug: %s does not exist", slug))
+		return
+	}
+	if !svc.postVisible(r, *post) {
+		svc.writeError(w, r, newApiError(http.StatusNotFound, "Post with slug: %s does not exist", slug))
+		return
+	}
+	if post.Slug != slug {
+		location := postSlugLocation(post.Slug)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+	svc.writePost(w, r, *post)
+}
+
+func (svc *RestApiService) handleUpdatePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	var post model.Post
+	present, fieldErrors, err := decodeRequestPayload(r, &post)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize post json payload"))
+		return
+	}
+	if post.Id != 0 && post.Id != id {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "post id: %d does not match id path variable: %d", post.Id, id))
+		return
+	}
+	existing, err := svc.postRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", id))
+		return
+	}
+	if err := svc.authorize(r, editPost, existing.OwnerId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validatePost(post, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	svc.updatePost(w, r, *existing, post)
+}
+
+func (svc *RestApiService) handlePatchPost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	existing, err := svc.postRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", id))
+		return
+	}
+	if err := svc.authorize(r, editPost, existing.OwnerId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not read post json patch"))
+		return
+	}
+	var body model.Post
+	present, fieldErrors, err := decodeJsonPayload(patch, &body)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not apply post json patch"))
+		return
+	}
+	if body.Id != 0 && body.Id != id {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "post id: %d does not match id path variable: %d", body.Id, id))
+		return
+	}
+	var post model.Post
+	if err := applyMergePatch(existing, patch, &post); err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not apply post json patch"))
+		return
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validatePost(post, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	svc.updatePost(w, r, *existing, post)
+}
+
+// updatePost replaces existing with post, keeping the fields clients may
+// not change and stamping the edit time.
+func (svc *RestApiService) updatePost(w http.ResponseWriter, r *http.Request, existing model.Post, post model.Post) {
+	post.Id = existing.Id
+	post.OwnerId = existing.OwnerId
+	if post.CreationDate.IsZero() {
+		post.CreationDate = existing.CreationDate
+	}
+	if post.Format == "" {
+		post.Format = existing.Format
+	}
+	// Replacing a post without a status keeps it in its place in the lifecycle.
+	if post.Status == "" {
+		post.Status = existing.Status
+		if post.PublishAt == nil {
+			post.PublishAt = existing.PublishAt
+		}
+	}
+	if err := svc.recordBaseline(existing); err != nil {
+		svc.writeError(w, r, revisionError(existing.Id, err))
+		return
+	}
+	now := time.Now().UTC()
+	post.EditedAt = &now
+	if err := svc.postRepository.Update(post); err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", post.Id))
+		return
+	}
+	svc.invalidateRendered(post.Id)
+	svc.invalidateSitemap()
+	// The store normalizes tags, so answer with the post as it was stored.
+	if stored, err := svc.postRepository.GetById(post.Id); err == nil {
+		post = *stored
+	}
+	if err := svc.recordRevision(post, revisionAuthor(r)); err != nil {
+		svc.writeError(w, r, revisionError(post.Id, err))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, post)
+}
+
+func (svc *RestApiService) handleDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	existing, err := svc.postRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", id))
+		return
+	}
+	if err := svc.authorize(r, deletePost, existing.OwnerId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if err := svc.postRepository.Delete(id); err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", id))
+		return
+	}
+	svc.invalidateRendered(id)
+	svc.invalidateSitemap()
+	removed, err := svc.commentRepository.DeleteAllByPostId(id)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusInternalServerError, "post id: %d deleted but its comments could not be removed: %v", id, err))
+		return
+	}
+	if err := svc.deleteRevisions(id); err != nil {
+		svc.writeError(w, r, newApiError(http.StatusInternalServerError, "post id: %d deleted but its revisions could not be removed: %v", id, err))
+		return
+	}
+	writeAckResponse(w, http.StatusOK, fmt.Sprintf("post id: %d successfully deleted together with %d comments", id, removed))
 }
 
 func (svc *RestApiService) handleGetCommentsByPostId(w http.ResponseWriter, r *http.Request) {
@@ -130,23 +510,64 @@ func (svc *RestApiService) handleGetCommentsByPostId(w http.ResponseWriter, r *h
 	//	 	{"Id": 5, "PostId": 101, "Comment": "comment3", "Author": "author13", "CreationDate" :"1970-01-01T03:46:40+01:15"}
 	//	 ]'
 
-	// Given that this project uses gorilla/mux as a router you can access the path params with following code:
-	vars := mux.Vars(r)
-	id, err := strconv.Atoi(vars["id"])
-	w.Header().Set("Content-Type", "application/json")
+	id, err := parseIdPathVariable(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: fmt.Sprintf("wrong id path variable: %s", vars["id"]),
-			Status:  http.StatusBadRequest,
-		})
-		w.Write(response)
+		svc.writeError(w, r, err)
 		return
 	}
-	res := svc.commentRepository.GetAllByPostId(uint64(id))
-	response, _ := json.Marshal(res)
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	if _, err := svc.readablePost(r, id); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	params := r.URL.Query()
+	if view := params.Get("view"); view != "" {
+		svc.writeCommentThread(w, r, id, view, params)
+		return
+	}
+	query, err := parseCommentQuery(params)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	page, err := svc.commentRepository.Query(id, query)
+	if err != nil {
+		svc.writeError(w, r, queryError(err, query.Cursor))
+		return
+	}
+	res := page.Items
+	if res == nil {
+		res = []model.Comment{}
+	}
+	setPaginationHeaders(w, r, page.Total, page.NextCursor)
+	writeJsonResponse(w, http.StatusOK, res)
+}
+
+// writeCommentThread responds with all comments of the post arranged by
+// replies, either as nested trees or depth first with depth annotations.
+func (svc *RestApiService) writeCommentThread(w http.ResponseWriter, r *http.Request, postId uint64, view string, params url.Values) {
+	if view != treeView && view != flatView {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "wrong view query parameter: %s, expected %s or %s", view, treeView, flatView))
+		return
+	}
+	for _, name := range []string{"limit", "cursor", "author"} {
+		if params.Get(name) != "" {
+			svc.writeError(w, r, newApiError(http.StatusBadRequest, "%s query parameter is not supported by the %s view", name, view))
+			return
+		}
+	}
+	descending, err := parseSortOrder(params.Get("order"), false)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	comments := svc.commentRepository.GetAllByPostId(postId)
+	thread := repository.BuildThread(comments, descending)
+	setPaginationHeaders(w, r, len(comments), "")
+	if view == flatView {
+		writeJsonResponse(w, http.StatusOK, repository.FlattenThread(thread))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, thread)
 }
 
 func (svc *RestApiService) handleAddComment(w http.ResponseWriter, r *http.Request) {
@@ -166,46 +587,356 @@ func (svc *RestApiService) handleAddComment(w http.ResponseWriter, r *http.Reque
 	//  e.g. POST /api/posts/comments '{"Id": 123, "PostId": 663, "Comment": "this is a comment", "Author": "blogger", "CreationDate" :"1970-01-01T03:46:40+01:00"}' -->
 	//  '{"Message": "comment id: 123 successfully added", Status: 200}'
 
+	if err := svc.authorize(r, createComment, 0); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
 	body := model.Comment{}
 
-	decoder := json.NewDecoder(r.Body)
-	w.Header().Set("Content-Type", "application/json")
-	if err := decoder.Decode(&body); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: "could not deserialize comment json payload",
-			Status:  http.StatusBadRequest,
-		})
-		w.Write(response)
+	present, fieldErrors, err := decodeRequestPayload(r, &body)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize comment json payload"))
 		return
 	}
-
-	if body.Id <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: "could not deserialize comment json payload",
-			Status:  http.StatusBadRequest,
-		})
-		w.Write(response)
+	// New comments are neither deleted nor edited, whatever the client sends.
+	body.AuthorId, body.Deleted, body.EditedAt = 0, false, nil
+	if user := currentUser(r); user != nil {
+		body.Author, body.AuthorId = user.Username, user.Id
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validateComment(body, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	if _, err := svc.readablePost(r, body.PostId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if err := svc.validateParent(body); err != nil {
+		svc.writeError(w, r, err)
 		return
 	}
 
-	err := svc.commentRepository.Insert(body)
-
+	body, err = svc.commentRepository.Create(body)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		response, _ := json.Marshal(AckJsonResponse{
-			Message: fmt.Sprintf("Comment with id: %d already exists in the database", body.Id),
-			Status:  http.StatusBadRequest,
-		})
-		w.Write(response)
+		svc.writeError(w, r, storeError(err, "Comment with id: %d already exists in the database", body.Id))
+		return
+	}
+
+	// The post may have been deleted, together with its comments, while this
+	// comment was being created.
+	if _, err := svc.readablePost(r, body.PostId); err != nil {
+		svc.commentRepository.Delete(body.Id)
+		svc.writeError(w, r, err)
 		return
 	}
 
-	response, _ := json.Marshal(AckJsonResponse{
+	w.Header().Set("Location", commentLocation(body.Id))
+	writeJsonResponse(w, http.StatusOK, AckJsonResponse{
 		Message: fmt.Sprintf("comment id: %d successfully added", body.Id),
 		Status:  http.StatusOK,
+		Id:      body.Id,
 	})
-	w.WriteHeader(http.StatusOK)
+}
+
+func (svc *RestApiService) handleGetComment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	comment, err := svc.commentRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Comment with id: %d does not exist", id))
+		return
+	}
+	if post, err := svc.postRepository.GetById(comment.PostId); err == nil && !svc.postVisible(r, *post) {
+		svc.writeError(w, r, newApiError(http.StatusNotFound, "Comment with id: %d does not exist", id))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, comment)
+}
+
+func (svc *RestApiService) handleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	var comment model.Comment
+	present, fieldErrors, err := decodeRequestPayload(r, &comment)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize comment json payload"))
+		return
+	}
+	if comment.Id != 0 && comment.Id != id {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "comment id: %d does not match id path variable: %d", comment.Id, id))
+		return
+	}
+	existing, err := svc.editableComment(r, id)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if comment.PostId == 0 {
+		comment.PostId = existing.PostId
+	}
+	if comment.ParentId == 0 {
+		comment.ParentId = existing.ParentId
+	}
+	if currentUser(r) != nil {
+		comment.Author = existing.Author
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validateComment(comment, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	svc.updateComment(w, r, *existing, comment)
+}
+
+func (svc *RestApiService) handlePatchComment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	existing, err := svc.editableComment(r, id)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not read comment json patch"))
+		return
+	}
+	present, fieldErrors, err := decodeJsonPayload(patch, &model.Comment{})
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not apply comment json patch"))
+		return
+	}
+	var comment model.Comment
+	if err := applyMergePatch(existing, patch, &comment); err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not apply comment json patch"))
+		return
+	}
+	if currentUser(r) != nil {
+		comment.Author = existing.Author
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validateComment(comment, present)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	svc.updateComment(w, r, *existing, comment)
+}
+
+// editableComment looks up the comment to be changed, failing with 404
+// when it does not exist, 410 when it was already deleted and 403 when the
+// caller may not edit it.
+func (svc *RestApiService) editableComment(r *http.Request, id uint64) (*model.Comment, error) {
+	existing, err := svc.commentRepository.GetById(id)
+	if err != nil {
+		return nil, storeError(err, "Comment with id: %d does not exist", id)
+	}
+	if existing.Deleted {
+		return nil, newApiError(http.StatusGone, "Comment with id: %d was deleted", id)
+	}
+	if err := svc.authorize(r, editComment, existing.AuthorId); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// updateComment replaces existing with comment, keeping the fields clients
+// may not change and stamping the edit time.
+func (svc *RestApiService) updateComment(w http.ResponseWriter, r *http.Request, existing model.Comment, comment model.Comment) {
+	if comment.PostId != existing.PostId {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "comments cannot be moved to another post"))
+		return
+	}
+	if comment.ParentId != existing.ParentId {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "comments cannot be moved to another thread"))
+		return
+	}
+	comment.Id = existing.Id
+	comment.AuthorId = existing.AuthorId
+	comment.CreationDate = existing.CreationDate
+	comment.Deleted = false
+	now := time.Now().UTC()
+	comment.EditedAt = &now
+	if err := svc.commentRepository.Update(comment); err != nil {
+		svc.writeError(w, r, storeError(err, "Comment with id: %d does not exist", comment.Id))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, comment)
+}
+
+// handleDeleteComment soft-deletes the comment: it stays in place with its
+// text and author replaced by a placeholder so replies keep their context.
+func (svc *RestApiService) handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	comment, err := svc.commentRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Comment with id: %d does not exist", id))
+		return
+	}
+	if err := svc.authorize(r, deleteComment, comment.AuthorId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if !comment.Deleted {
+		now := time.Now().UTC()
+		comment.Comment = model.DeletedCommentPlaceholder
+		comment.Author = model.DeletedCommentPlaceholder
+		comment.Deleted = true
+		comment.EditedAt = &now
+		if err := svc.commentRepository.Update(*comment); err != nil {
+			svc.writeError(w, r, storeError(err, "Comment with id: %d does not exist", id))
+			return
+		}
+	}
+	writeAckResponse(w, http.StatusOK, fmt.Sprintf("comment id: %d successfully deleted", id))
+}
+
+func postLocation(id uint64) string {
+	return strings.Replace(getPostPath, "{id}", strconv.FormatUint(id, 10), 1)
+}
+
+func postSlugLocation(slug string) string {
+	return strings.Replace(postSlugPath, "{slug}", url.PathEscape(slug), 1)
+}
+
+func commentLocation(id uint64) string {
+	return strings.Replace(commentPath, "{id}", strconv.FormatUint(id, 10), 1)
+}
+
+func writeJsonResponse(w http.ResponseWriter, status int, payload interface{}) {
+	response, err := json.Marshal(payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(response)
 }
+
+func writeAckResponse(w http.ResponseWriter, status int, message string) {
+	writeJsonResponse(w, status, AckJsonResponse{Message: message, Status: status})
+}
+
+// parseIdPathVariable reads the {id} path variable, failing with 400 when it is not a valid id.
+func parseIdPathVariable(r *http.Request) (uint64, error) {
+	vars := mux.Vars(r)
+	id, err := strconv.ParseUint(vars["id"], 10, 64)
+	if err != nil {
+		return 0, newApiError(http.StatusBadRequest, "wrong id path variable: %s", vars["id"])
+	}
+	return id, nil
+}
+
+// setPaginationHeaders describes the page of a bare JSON array response:
+// X-Total-Count holds the number of matching items across all pages and,
+// unless this is the last page, X-Next-Cursor and a Link header point to
+// the next one.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, total int, nextCursor string) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if nextCursor == "" {
+		return
+	}
+	params := r.URL.Query()
+	params.Set("cursor", nextCursor)
+	next := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+	w.Header().Set("X-Next-Cursor", nextCursor)
+	w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
+
+func parsePostQuery(params url.Values) (repository.PostQuery, error) {
+	query := repository.PostQuery{
+		SortBy:   repository.PostSortField(params.Get("sort")),
+		Tag:      params.Get("tag"),
+		Category: params.Get("category"),
+		Cursor:   params.Get("cursor"),
+	}
+	switch query.SortBy {
+	case "", repository.SortByCreationDate, repository.SortByTitle:
+	default:
+		return query, newApiError(http.StatusBadRequest, "wrong sort query parameter: %s, expected %s or %s", query.SortBy, repository.SortByCreationDate, repository.SortByTitle)
+	}
+	var err error
+	if query.Limit, err = parsePageLimit(params.Get("limit")); err != nil {
+		return query, err
+	}
+	if query.Descending, err = parseSortOrder(params.Get("order"), true); err != nil {
+		return query, err
+	}
+	if query.From, err = parseTimeParam("from", params.Get("from")); err != nil {
+		return query, err
+	}
+	if query.To, err = parseTimeParam("to", params.Get("to")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func parseCommentQuery(params url.Values) (repository.CommentQuery, error) {
+	query := repository.CommentQuery{
+		Author: params.Get("author"),
+		Cursor: params.Get("cursor"),
+	}
+	var err error
+	if query.Limit, err = parsePageLimit(params.Get("limit")); err != nil {
+		return query, err
+	}
+	if query.Descending, err = parseSortOrder(params.Get("order"), false); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// queryError maps an error of a paginated query to its status code.
+func queryError(err error, cursor string) error {
+	var invalidCursor repository.InvalidCursorError
+	if errors.As(err, &invalidCursor) {
+		return newApiError(http.StatusBadRequest, "wrong cursor query parameter: %s", cursor)
+	}
+	return err
+}
+
+func parsePageLimit(value string) (int, error) {
+	if value == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > maxPageSize {
+		return 0, newApiError(http.StatusBadRequest, "wrong limit query parameter: %s, expected a number between 1 and %d", value, maxPageSize)
+	}
+	return limit, nil
+}
+
+// parseSortOrder reports whether the order query parameter asks for descending order.
+func parseSortOrder(value string, descendingByDefault bool) (bool, error) {
+	switch value {
+	case "":
+		return descendingByDefault, nil
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	}
+	return false, newApiError(http.StatusBadRequest, "wrong order query parameter: %s, expected asc or desc", value)
+}
+
+func parseTimeParam(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, newApiError(http.StatusBadRequest, "wrong %s query parameter: %s, expected an RFC 3339 timestamp", name, value)
+	}
+	return t, nil
+}
@@ -22,11 +22,12 @@ import (
 func TestRestApiService_handleAddPost(t *testing.T) {
 	tests := []struct {
 		testName           string
-		commentRepository  repository.CommentRepository
-		postRepository     repository.PostRepository
+		commentRepository  *repository.CommentRepository
+		postRepository     *repository.PostRepository
 		post               interface{}
 		expectedHttpStatus int
 		expectedHeader     string
+		expectedLocation   string
 		expectedResponse   interface{}
 	}{
 		{
@@ -36,7 +37,18 @@ func TestRestApiService_handleAddPost(t *testing.T) {
 			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
 			expectedHttpStatus: 200,
 			expectedHeader:     "application/json",
-			expectedResponse:   AckJsonResponse{Message: "post id: 256 successfully added", Status: http.StatusOK},
+			expectedLocation:   "/api/posts/256",
+			expectedResponse:   AckJsonResponse{Message: "post id: 256 successfully added", Status: http.StatusOK, Id: 256},
+		},
+		{
+			testName:           "testServerAssignedPostId",
+			post:               map[string]string{"Title": "title", "Content": "cntnt"},
+			commentRepository:  repository.CustomCommentRepository(make([]model.Comment, 0)),
+			postRepository:     repository.CustomPostRepository([]model.Post{{Id: 41, Title: "older"}}),
+			expectedHttpStatus: 200,
+			expectedHeader:     "application/json",
+			expectedLocation:   "/api/posts/42",
+			expectedResponse:   AckJsonResponse{Message: "post id: 42 successfully added", Status: http.StatusOK, Id: 42},
 		},
 	}
 
@@ -47,7 +59,7 @@ func TestRestApiService_handleAddPost(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPost, postsPath, bytes.NewReader(data))
 			w := httptest.NewRecorder()
 			router := mux.NewRouter()
-			svc := RestApiService{&tc.postRepository, &tc.commentRepository}
+			svc := RestApiService{postRepository: tc.postRepository, commentRepository: tc.commentRepository, legacyErrors: true}
 
 			// WHEN
 			router.HandleFunc(postsPath, svc.handleAddPost)
@@ -61,6 +73,7 @@ func TestRestApiService_handleAddPost(t *testing.T) {
 			// THEN
 			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
 			assert.Equal(t, tc.expectedHeader, response.Header.Get("Content-Type"))
+			assert.Equal(t, tc.expectedLocation, response.Header.Get("Location"))
 			assert.Equal(t, tc.expectedResponse, ackResponse)
 		})
 	}
@@ -68,13 +81,13 @@ func TestRestApiService_handleAddPost(t *testing.T) {
 
 func TestRestApiService_handleGetPostByPostId(t *testing.T) {
 	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
-	var validPost = model.Post{Id: 34, Title: "happy post", Content: "test content", CreationDate: testDate}
+	var validPost = model.Post{Id: 34, Title: "happy post", Slug: "happy-post", Content: "test content", CreationDate: testDate}
 	var badID = "badID"
 
 	tests := []struct {
 		testName           string
-		commentRepository  repository.CommentRepository
-		postRepository     repository.PostRepository
+		commentRepository  *repository.CommentRepository
+		postRepository     *repository.PostRepository
 		postId             string
 		expectedHttpStatus int
 		expectedHeader     string
@@ -134,8 +147,8 @@ func TestRestApiService_handleGetPostByPostId(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.testName, func(t *testing.T) {
 			// GIVEN
-			svc := RestApiService{commentRepository: &tc.commentRepository,
-				postRepository: &tc.postRepository}
+			svc := RestApiService{commentRepository: tc.commentRepository,
+				postRepository: tc.postRepository, legacyErrors: true}
 
 			path := strings.Replace(getPostPath, "{id}", tc.postId, 1)
 			req := httptest.NewRequest(http.MethodGet, path, nil)
@@ -163,12 +176,16 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 		{Id: 321, PostId: 3, Comment: "def", Author: "cool author2", CreationDate: testDate},
 		{Id: 543, PostId: 3, Comment: "ghi", Author: "cool author3", CreationDate: testDate},
 	}
+	var validPosts = []model.Post{
+		{Id: 1, Title: "quiet post", Content: "test content", CreationDate: testDate},
+		{Id: 3, Title: "popular post", Content: "test content", CreationDate: testDate},
+	}
 	var badID = "badID"
 
 	tests := []struct {
 		testName           string
-		commentRepository  repository.CommentRepository
-		postRepository     repository.PostRepository
+		commentRepository  *repository.CommentRepository
+		postRepository     *repository.PostRepository
 		postId             string
 		expectedHttpStatus int
 		expectedHeader     string
@@ -178,7 +195,7 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 		{
 			testName:           "testSuccessfullyGetComments",
 			commentRepository:  repository.CustomCommentRepository(validComments),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository(validPosts),
 			postId:             "3",
 			expectedHttpStatus: 200,
 			expectedHeader:     "application/json",
@@ -194,7 +211,7 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 		{
 			testName:           "testEmptyComments",
 			commentRepository:  repository.CustomCommentRepository(validComments),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository(validPosts),
 			postId:             "1",
 			expectedHttpStatus: 200,
 			expectedHeader:     "application/json",
@@ -207,10 +224,26 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 				assert.ElementsMatch(t, expectedResponse, commentsList)
 			},
 		},
+		{
+			testName:           "testPostNotFound",
+			commentRepository:  repository.CustomCommentRepository(validComments),
+			postRepository:     repository.CustomPostRepository(validPosts),
+			postId:             "2",
+			expectedHttpStatus: 404,
+			expectedHeader:     "application/json",
+			expectedResponse:   AckJsonResponse{Message: "Post with id: 2 does not exist", Status: 404},
+			verifyResponseFunc: func(t *testing.T, expectedResponse interface{}, body []byte) {
+				t.Helper()
+				var resp AckJsonResponse
+				err := json.Unmarshal(body, &resp)
+				require.NoError(t, err)
+				assert.Equal(t, expectedResponse, resp)
+			},
+		},
 		{
 			testName:           "testBadRequest",
 			commentRepository:  repository.CustomCommentRepository(validComments),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository(validPosts),
 			postId:             badID,
 			expectedHttpStatus: 400,
 			expectedHeader:     "application/json",
@@ -228,8 +261,8 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 	for _, tc := range tests {
 		t.Run(tc.testName, func(t *testing.T) {
 			// GIVEN
-			svc := RestApiService{commentRepository: &tc.commentRepository,
-				postRepository: &tc.postRepository}
+			svc := RestApiService{commentRepository: tc.commentRepository,
+				postRepository: tc.postRepository, legacyErrors: true}
 			path := strings.Replace(getCommentPath, "{id}", tc.postId, 1)
 			req := httptest.NewRequest(http.MethodGet, path, nil)
 			w := httptest.NewRecorder()
@@ -252,59 +285,81 @@ func TestRestApiService_handleGetCommentsByPostId(t *testing.T) {
 func TestRestApiService_handleAddComment(t *testing.T) {
 	var validComment = model.Comment{Id: 123, PostId: 3, Comment: "cool cmnt", Author: "cool auth", CreationDate: time.Now()}
 	var validReqBody, _ = json.Marshal(&validComment)
+	var validPost = model.Post{Id: 3, Title: "commented post", Content: "cntnt", CreationDate: time.Now()}
 
 	tests := []struct {
 		testName           string
-		commentRepository  repository.CommentRepository
-		postRepository     repository.PostRepository
+		commentRepository  *repository.CommentRepository
+		postRepository     *repository.PostRepository
 		reqBody            []byte
 		expectedHttpStatus int
 		expectedHeader     string
+		expectedLocation   string
 		expectedResponse   interface{}
 	}{
 		{
 			testName:           "testSuccessfullyAddComment",
 			commentRepository:  repository.CustomCommentRepository(make([]model.Comment, 0)),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository([]model.Post{validPost}),
 			reqBody:            validReqBody,
 			expectedHttpStatus: 200,
 			expectedHeader:     "application/json",
-			expectedResponse:   AckJsonResponse{Message: "comment id: 123 successfully added", Status: 200},
+			expectedLocation:   "/api/comments/123",
+			expectedResponse:   AckJsonResponse{Message: "comment id: 123 successfully added", Status: 200, Id: 123},
+		},
+		{
+			testName:           "testServerAssignedCommentId",
+			commentRepository:  repository.CustomCommentRepository([]model.Comment{validComment}),
+			postRepository:     repository.CustomPostRepository([]model.Post{validPost}),
+			reqBody:            []byte(`{"PostId": 3, "Comment": "no id", "Author": "cool auth"}`),
+			expectedHttpStatus: 200,
+			expectedHeader:     "application/json",
+			expectedLocation:   "/api/comments/124",
+			expectedResponse:   AckJsonResponse{Message: "comment id: 124 successfully added", Status: 200, Id: 124},
 		},
 		{
 			testName:           "testIncompleteData",
 			commentRepository:  repository.CustomCommentRepository(make([]model.Comment, 0)),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository([]model.Post{validPost}),
 			reqBody:            []byte("{}"),
-			expectedHttpStatus: 400,
+			expectedHttpStatus: 422,
 			expectedHeader:     "application/json",
-			expectedResponse:   AckJsonResponse{Message: "could not deserialize comment json payload", Status: 400},
+			expectedResponse:   AckJsonResponse{Message: "payload validation failed", Status: 422},
 		},
 		{
 			testName:           "testBadPayload",
 			commentRepository:  repository.CustomCommentRepository(make([]model.Comment, 0)),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository([]model.Post{validPost}),
 			reqBody:            []byte("invalidJson"),
 			expectedHttpStatus: 400,
 			expectedHeader:     "application/json",
 			expectedResponse:   AckJsonResponse{Message: "could not deserialize comment json payload", Status: 400},
 		},
+		{
+			testName:           "testPostNotFound",
+			commentRepository:  repository.CustomCommentRepository(make([]model.Comment, 0)),
+			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			reqBody:            validReqBody,
+			expectedHttpStatus: 404,
+			expectedHeader:     "application/json",
+			expectedResponse:   AckJsonResponse{Message: "Post with id: 3 does not exist", Status: 404},
+		},
 		{
 			testName:           "testAlreadyExists",
 			commentRepository:  repository.CustomCommentRepository([]model.Comment{validComment}),
-			postRepository:     repository.CustomPostRepository(make([]model.Post, 0)),
+			postRepository:     repository.CustomPostRepository([]model.Post{validPost}),
 			reqBody:            validReqBody,
-			expectedHttpStatus: 400,
+			expectedHttpStatus: 409,
 			expectedHeader:     "application/json",
-			expectedResponse:   AckJsonResponse{Message: "Comment with id: 123 already exists in the database", Status: 400},
+			expectedResponse:   AckJsonResponse{Message: "Comment with id: 123 already exists in the database", Status: 409},
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.testName, func(t *testing.T) {
 			// GIVEN
-			svc := RestApiService{commentRepository: &tc.commentRepository,
-				postRepository: &tc.postRepository}
+			svc := RestApiService{commentRepository: tc.commentRepository,
+				postRepository: tc.postRepository, legacyErrors: true}
 
 			req := httptest.NewRequest(http.MethodPost, commentsPath, bytes.NewReader(tc.reqBody))
 			w := httptest.NewRecorder()
@@ -322,7 +377,1024 @@ func TestRestApiService_handleAddComment(t *testing.T) {
 			// THEN
 			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
 			assert.Equal(t, tc.expectedHeader, response.Header.Get("Content-Type"))
+			assert.Equal(t, tc.expectedLocation, response.Header.Get("Location"))
+			assert.Equal(t, tc.expectedResponse, resp)
+		})
+	}
+}
+
+type stubPostStore struct {
+	repository.PostStore
+	post model.Post
+}
+
+func (s stubPostStore) GetById(id uint64) (*model.Post, error) {
+	if id != s.post.Id {
+		return nil, repository.PostNotFoundError{}
+	}
+	return &s.post, nil
+}
+
+func TestNewRestApiServiceWithStores(t *testing.T) {
+	// GIVEN
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var post = model.Post{Id: 7, Title: "stubbed", Content: "from a custom store", CreationDate: testDate}
+	svc := NewRestApiServiceWithStores(stubPostStore{post: post}, repository.NewCommentRepository())
+
+	path := strings.Replace(getPostPath, "{id}", "7", 1)
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	router := mux.NewRouter()
+
+	// WHEN
+	router.HandleFunc(getPostPath, svc.handleGetPostByPostId)
+	router.ServeHTTP(w, req)
+	response := w.Result()
+	body, _ := io.ReadAll(response.Body)
+
+	// THEN
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	var got model.Post
+	require.NoError(t, json.Unmarshal(body, &got))
+	assert.Equal(t, post, got)
+}
+
+func TestRestApiService_handleUpdatePost(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var existingPost = model.Post{Id: 34, Title: "happy post", Slug: "happy-post", Content: "test content", CreationDate: testDate}
+
+	tests := []struct {
+		testName           string
+		method             string
+		postId             string
+		reqBody            string
+		expectedHttpStatus int
+		expectedPost       *model.Post
+		expectedResponse   *AckJsonResponse
+	}{
+		{
+			testName:           "testSuccessfullyReplacePost",
+			method:             http.MethodPut,
+			postId:             "34",
+			reqBody:            `{"Title": "new title", "Content": "new content"}`,
+			expectedHttpStatus: 200,
+			expectedPost:       &model.Post{Id: 34, Title: "new title", Slug: "new-title", Content: "new content", CreationDate: testDate},
+		},
+		{
+			testName:           "testReplaceMismatchedId",
+			method:             http.MethodPut,
+			postId:             "34",
+			reqBody:            `{"Id": 35, "Title": "new title", "Content": "new content"}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "post id: 35 does not match id path variable: 34", Status: 400},
+		},
+		{
+			testName:           "testReplaceBadPayload",
+			method:             http.MethodPut,
+			postId:             "34",
+			reqBody:            `invalidJson`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "could not deserialize post json payload", Status: 400},
+		},
+		{
+			testName:           "testReplaceNotFound",
+			method:             http.MethodPut,
+			postId:             "111",
+			reqBody:            `{"Title": "new title"}`,
+			expectedHttpStatus: 404,
+			expectedResponse:   &AckJsonResponse{Message: "Post with id: 111 does not exist", Status: 404},
+		},
+		{
+			testName:           "testSuccessfullyPatchPost",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"Title": "patched title"}`,
+			expectedHttpStatus: 200,
+			expectedPost:       &model.Post{Id: 34, Title: "patched title", Slug: "patched-title", Content: "test content", CreationDate: testDate},
+		},
+		{
+			testName:           "testPatchMatchingId",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"Title": "patched title", "Id": 34}`,
+			expectedHttpStatus: 200,
+			expectedPost:       &model.Post{Id: 34, Title: "patched title", Slug: "patched-title", Content: "test content", CreationDate: testDate},
+		},
+		{
+			testName:           "testPatchMismatchedId",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"Title": "patched title", "Id": 99}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "post id: 99 does not match id path variable: 34", Status: 400},
+		},
+		{
+			testName:           "testPatchMismatchedIdWithLowerCaseKey",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"id": 99}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "post id: 99 does not match id path variable: 34", Status: 400},
+		},
+		{
+			testName:           "testPatchLowerCaseKey",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"title": "patched title"}`,
+			expectedHttpStatus: 200,
+			expectedPost:       &model.Post{Id: 34, Title: "patched title", Slug: "patched-title", Content: "test content", CreationDate: testDate},
+		},
+		{
+			testName:           "testPatchRemovesRequiredField",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"Content": null}`,
+			expectedHttpStatus: 422,
+			expectedResponse:   &AckJsonResponse{Message: "payload validation failed", Status: 422},
+		},
+		{
+			testName:           "testPatchRemovesRequiredFieldWithLowerCaseKey",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"content": null}`,
+			expectedHttpStatus: 422,
+			expectedResponse:   &AckJsonResponse{Message: "payload validation failed", Status: 422},
+		},
+		{
+			testName:           "testPatchBadPayload",
+			method:             http.MethodPatch,
+			postId:             "34",
+			reqBody:            `{"Title": `,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "could not apply post json patch", Status: 400},
+		},
+		{
+			testName:           "testPatchNotFound",
+			method:             http.MethodPatch,
+			postId:             "111",
+			reqBody:            `{}`,
+			expectedHttpStatus: 404,
+			expectedResponse:   &AckJsonResponse{Message: "Post with id: 111 does not exist", Status: 404},
+		},
+		{
+			testName:           "testPatchBadId",
+			method:             http.MethodPatch,
+			postId:             "badID",
+			reqBody:            `{}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong id path variable: badID", Status: 400},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			postRepository := repository.CustomPostRepository([]model.Post{existingPost})
+			svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository(), WithLegacyErrors())
+			path := strings.Replace(getPostPath, "{id}", tc.postId, 1)
+			req := httptest.NewRequest(tc.method, path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			// WHEN
+			router.HandleFunc(getPostPath, svc.handleUpdatePost).Methods(http.MethodPut)
+			router.HandleFunc(getPostPath, svc.handlePatchPost).Methods(http.MethodPatch)
+			router.ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			if tc.expectedResponse != nil {
+				var resp AckJsonResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, *tc.expectedResponse, resp)
+				stored, err := postRepository.GetById(existingPost.Id)
+				require.NoError(t, err)
+				assert.Equal(t, existingPost, *stored)
+				return
+			}
+			var post model.Post
+			require.NoError(t, json.Unmarshal(body, &post))
+			require.NotNil(t, post.EditedAt)
+			stored, err := postRepository.GetById(existingPost.Id)
+			require.NoError(t, err)
+			assert.Equal(t, post, *stored)
+			post.EditedAt = nil
+			assert.Equal(t, *tc.expectedPost, post)
+		})
+	}
+}
+
+func TestRestApiService_handleDeletePost(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 3, Title: "doomed post", Content: "test content", CreationDate: testDate},
+		{Id: 4, Title: "other post", Content: "test content", CreationDate: testDate},
+	}
+	var comments = []model.Comment{
+		{Id: 123, PostId: 3, Comment: "abc", Author: "cool author", CreationDate: testDate},
+		{Id: 321, PostId: 3, Comment: "def", Author: "cool author2", CreationDate: testDate},
+		{Id: 543, PostId: 4, Comment: "ghi", Author: "cool author3", CreationDate: testDate},
+	}
+
+	tests := []struct {
+		testName              string
+		postId                string
+		expectedHttpStatus    int
+		expectedResponse      AckJsonResponse
+		expectedRemainingPost []uint64
+		expectedComments      map[uint64]int
+	}{
+		{
+			testName:              "testSuccessfullyDeletePost",
+			postId:                "3",
+			expectedHttpStatus:    200,
+			expectedResponse:      AckJsonResponse{Message: "post id: 3 successfully deleted together with 2 comments", Status: 200},
+			expectedRemainingPost: []uint64{4},
+			expectedComments:      map[uint64]int{3: 0, 4: 1},
+		},
+		{
+			testName:              "testDeletePostNotFound",
+			postId:                "111",
+			expectedHttpStatus:    404,
+			expectedResponse:      AckJsonResponse{Message: "Post with id: 111 does not exist", Status: 404},
+			expectedRemainingPost: []uint64{3, 4},
+			expectedComments:      map[uint64]int{3: 2, 4: 1},
+		},
+		{
+			testName:              "testDeletePostBadId",
+			postId:                "badID",
+			expectedHttpStatus:    400,
+			expectedResponse:      AckJsonResponse{Message: "wrong id path variable: badID", Status: 400},
+			expectedRemainingPost: []uint64{3, 4},
+			expectedComments:      map[uint64]int{3: 2, 4: 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			postRepository := repository.CustomPostRepository(posts)
+			commentRepository := repository.CustomCommentRepository(comments)
+			svc := NewRestApiServiceWithStores(postRepository, commentRepository, WithLegacyErrors())
+			path := strings.Replace(getPostPath, "{id}", tc.postId, 1)
+			req := httptest.NewRequest(http.MethodDelete, path, nil)
+			w := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			// WHEN
+			router.HandleFunc(getPostPath, svc.handleDeletePost)
+			router.ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+			var resp AckJsonResponse
+			require.NoError(t, json.Unmarshal(body, &resp))
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
 			assert.Equal(t, tc.expectedResponse, resp)
+			for _, id := range tc.expectedRemainingPost {
+				_, err := postRepository.GetById(id)
+				assert.NoError(t, err)
+			}
+			for postId, count := range tc.expectedComments {
+				assert.Len(t, commentRepository.GetAllByPostId(postId), count)
+			}
+		})
+	}
+}
+
+func TestRestApiService_commentResource(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var existingComment = model.Comment{Id: 123, PostId: 3, Comment: "abc", Author: "cool author", CreationDate: testDate}
+	var deletedComment = model.Comment{Id: 124, PostId: 3, Comment: model.DeletedCommentPlaceholder, Author: model.DeletedCommentPlaceholder, CreationDate: testDate, Deleted: true}
+
+	tests := []struct {
+		testName           string
+		method             string
+		commentId          string
+		reqBody            string
+		expectedHttpStatus int
+		expectedComment    *model.Comment
+		expectedResponse   *AckJsonResponse
+		expectEdit         bool
+	}{
+		{
+			testName:           "testSuccessfullyGetComment",
+			method:             http.MethodGet,
+			commentId:          "123",
+			expectedHttpStatus: 200,
+			expectedComment:    &existingComment,
+		},
+		{
+			testName:           "testGetCommentNotFound",
+			method:             http.MethodGet,
+			commentId:          "999",
+			expectedHttpStatus: 404,
+			expectedResponse:   &AckJsonResponse{Message: "Comment with id: 999 does not exist", Status: 404},
+		},
+		{
+			testName:           "testGetCommentBadId",
+			method:             http.MethodGet,
+			commentId:          "badID",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong id path variable: badID", Status: 400},
+		},
+		{
+			testName:           "testSuccessfullyReplaceComment",
+			method:             http.MethodPut,
+			commentId:          "123",
+			reqBody:            `{"Comment": "fixed typo", "Author": "cool author"}`,
+			expectedHttpStatus: 200,
+			expectedComment:    &model.Comment{Id: 123, PostId: 3, Comment: "fixed typo", Author: "cool author", CreationDate: testDate},
+			expectEdit:         true,
+		},
+		{
+			testName:           "testReplaceIncompleteComment",
+			method:             http.MethodPut,
+			commentId:          "123",
+			reqBody:            `{"Comment": "fixed typo"}`,
+			expectedHttpStatus: 422,
+			expectedResponse:   &AckJsonResponse{Message: "payload validation failed", Status: 422},
+		},
+		{
+			testName:           "testReplaceCommentMovesPost",
+			method:             http.MethodPut,
+			commentId:          "123",
+			reqBody:            `{"PostId": 4, "Comment": "moved", "Author": "cool author"}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "comments cannot be moved to another post", Status: 400},
+		},
+		{
+			testName:           "testReplaceCommentMismatchedId",
+			method:             http.MethodPut,
+			commentId:          "123",
+			reqBody:            `{"Id": 5, "Comment": "fixed typo", "Author": "cool author"}`,
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "comment id: 5 does not match id path variable: 123", Status: 400},
+		},
+		{
+			testName:           "testSuccessfullyPatchComment",
+			method:             http.MethodPatch,
+			commentId:          "123",
+			reqBody:            `{"Comment": "patched"}`,
+			expectedHttpStatus: 200,
+			expectedComment:    &model.Comment{Id: 123, PostId: 3, Comment: "patched", Author: "cool author", CreationDate: testDate},
+			expectEdit:         true,
+		},
+		{
+			testName:           "testPatchCommentNotFound",
+			method:             http.MethodPatch,
+			commentId:          "999",
+			reqBody:            `{"Comment": "patched"}`,
+			expectedHttpStatus: 404,
+			expectedResponse:   &AckJsonResponse{Message: "Comment with id: 999 does not exist", Status: 404},
+		},
+		{
+			testName:           "testPatchDeletedComment",
+			method:             http.MethodPatch,
+			commentId:          "124",
+			reqBody:            `{"Comment": "resurrected"}`,
+			expectedHttpStatus: 410,
+			expectedResponse:   &AckJsonResponse{Message: "Comment with id: 124 was deleted", Status: 410},
+		},
+		{
+			testName:           "testSuccessfullyDeleteComment",
+			method:             http.MethodDelete,
+			commentId:          "123",
+			expectedHttpStatus: 200,
+			expectedResponse:   &AckJsonResponse{Message: "comment id: 123 successfully deleted", Status: 200},
+		},
+		{
+			testName:           "testDeleteCommentNotFound",
+			method:             http.MethodDelete,
+			commentId:          "999",
+			expectedHttpStatus: 404,
+			expectedResponse:   &AckJsonResponse{Message: "Comment with id: 999 does not exist", Status: 404},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			commentRepository := repository.CustomCommentRepository([]model.Comment{existingComment, deletedComment})
+			svc := NewRestApiServiceWithStores(repository.NewPostRepository(), commentRepository, WithLegacyErrors())
+			path := strings.Replace(commentPath, "{id}", tc.commentId, 1)
+			req := httptest.NewRequest(tc.method, path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			// WHEN
+			router.HandleFunc(commentPath, svc.handleGetComment).Methods(http.MethodGet)
+			router.HandleFunc(commentPath, svc.handleUpdateComment).Methods(http.MethodPut)
+			router.HandleFunc(commentPath, svc.handlePatchComment).Methods(http.MethodPatch)
+			router.HandleFunc(commentPath, svc.handleDeleteComment).Methods(http.MethodDelete)
+			router.ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			if tc.expectedResponse != nil {
+				var resp AckJsonResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, *tc.expectedResponse, resp)
+				return
+			}
+			var comment model.Comment
+			require.NoError(t, json.Unmarshal(body, &comment))
+			if tc.expectEdit {
+				require.NotNil(t, comment.EditedAt)
+				stored, err := commentRepository.GetById(comment.Id)
+				require.NoError(t, err)
+				assert.Equal(t, comment, *stored)
+				comment.EditedAt = nil
+			}
+			assert.Equal(t, *tc.expectedComment, comment)
+		})
+	}
+
+	t.Run("testDeleteLeavesPlaceholder", func(t *testing.T) {
+		// GIVEN
+		commentRepository := repository.CustomCommentRepository([]model.Comment{existingComment})
+		svc := NewRestApiServiceWithStores(repository.NewPostRepository(), commentRepository, WithLegacyErrors())
+		path := strings.Replace(commentPath, "{id}", "123", 1)
+		router := mux.NewRouter()
+		router.HandleFunc(commentPath, svc.handleDeleteComment)
+
+		// WHEN
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, path, nil))
+
+		// THEN
+		comments := commentRepository.GetAllByPostId(existingComment.PostId)
+		require.Len(t, comments, 1)
+		assert.True(t, comments[0].Deleted)
+		assert.Equal(t, model.DeletedCommentPlaceholder, comments[0].Comment)
+		assert.Equal(t, model.DeletedCommentPlaceholder, comments[0].Author)
+		assert.Equal(t, existingComment.CreationDate, comments[0].CreationDate)
+		assert.NotNil(t, comments[0].EditedAt)
+	})
+}
+
+func TestRestApiService_handleListPosts(t *testing.T) {
+	var posts = []model.Post{
+		{Id: 1, Title: "banana", Content: "content", CreationDate: time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)},
+		{Id: 2, Title: "apple", Content: "content", CreationDate: time.Date(2018, time.September, 17, 12, 0, 0, 0, time.UTC)},
+		{Id: 3, Title: "cherry", Content: "content", CreationDate: time.Date(2018, time.September, 18, 12, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		testName           string
+		query              string
+		expectedHttpStatus int
+		expectedIds        []uint64
+		expectedTotal      int
+		expectNext         bool
+		expectedResponse   *AckJsonResponse
+	}{
+		{testName: "testNewestFirstByDefault", query: "", expectedHttpStatus: 200, expectedIds: []uint64{3, 2, 1}, expectedTotal: 3},
+		{testName: "testSortByTitle", query: "?sort=title&order=asc", expectedHttpStatus: 200, expectedIds: []uint64{2, 1, 3}, expectedTotal: 3},
+		{testName: "testLimit", query: "?limit=2", expectedHttpStatus: 200, expectedIds: []uint64{3, 2}, expectedTotal: 3, expectNext: true},
+		{testName: "testDateRange", query: "?from=2018-09-17T00:00:00Z&to=2018-09-17T23:59:59Z", expectedHttpStatus: 200, expectedIds: []uint64{2}, expectedTotal: 1},
+		{testName: "testNoMatches", query: "?from=2020-01-01T00:00:00Z", expectedHttpStatus: 200, expectedIds: []uint64{}, expectedTotal: 0},
+		{
+			testName:           "testBadLimit",
+			query:              "?limit=1000",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong limit query parameter: 1000, expected a number between 1 and 100", Status: 400},
+		},
+		{
+			testName:           "testBadSort",
+			query:              "?sort=author",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong sort query parameter: author, expected creationDate or title", Status: 400},
+		},
+		{
+			testName:           "testBadOrder",
+			query:              "?order=up",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong order query parameter: up, expected asc or desc", Status: 400},
+		},
+		{
+			testName:           "testBadDate",
+			query:              "?from=yesterday",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong from query parameter: yesterday, expected an RFC 3339 timestamp", Status: 400},
+		},
+		{
+			testName:           "testBadCursor",
+			query:              "?cursor=abc",
+			expectedHttpStatus: 400,
+			expectedResponse:   &AckJsonResponse{Message: "wrong cursor query parameter: abc", Status: 400},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository(), WithLegacyErrors())
+			req := httptest.NewRequest(http.MethodGet, postsPath+tc.query, nil)
+			w := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			// WHEN
+			router.HandleFunc(postsPath, svc.handleListPosts)
+			router.ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+			if tc.expectedResponse != nil {
+				var resp AckJsonResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, *tc.expectedResponse, resp)
+				return
+			}
+			var list PostListResponse
+			require.NoError(t, json.Unmarshal(body, &list))
+			ids := []uint64{}
+			for _, post := range list.Items {
+				ids = append(ids, post.Id)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedTotal, list.Total)
+			assert.Equal(t, tc.expectNext, list.NextCursor != "")
+		})
+	}
+
+	t.Run("testFollowNextCursor", func(t *testing.T) {
+		svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository(), WithLegacyErrors())
+		router := mux.NewRouter()
+		router.HandleFunc(postsPath, svc.handleListPosts)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, postsPath+"?limit=2", nil))
+		var first PostListResponse
+		require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&first))
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, postsPath+"?limit=2&cursor="+first.NextCursor, nil))
+		var second PostListResponse
+		require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&second))
+		require.Len(t, second.Items, 1)
+		assert.Equal(t, uint64(1), second.Items[0].Id)
+		assert.Empty(t, second.NextCursor)
+	})
+}
+
+func TestRestApiService_handleGetCommentsByPostIdPagination(t *testing.T) {
+	var validComments = []model.Comment{
+		{Id: 123, PostId: 3, Comment: "abc", Author: "cool author", CreationDate: time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)},
+		{Id: 321, PostId: 3, Comment: "def", Author: "cool author2", CreationDate: time.Date(2018, time.September, 17, 12, 0, 0, 0, time.UTC)},
+		{Id: 543, PostId: 3, Comment: "ghi", Author: "cool author", CreationDate: time.Date(2018, time.September, 18, 12, 0, 0, 0, time.UTC)},
+	}
+
+	tests := []struct {
+		testName           string
+		query              string
+		expectedHttpStatus int
+		expectedIds        []uint64
+		expectedTotal      string
+		expectedLink       string
+	}{
+		{testName: "testOldestFirstByDefault", query: "", expectedHttpStatus: 200, expectedIds: []uint64{123, 321, 543}, expectedTotal: "3"},
+		{testName: "testNewestFirst", query: "?order=desc", expectedHttpStatus: 200, expectedIds: []uint64{543, 321, 123}, expectedTotal: "3"},
+		{testName: "testAuthorFilter", query: "?author=cool+author", expectedHttpStatus: 200, expectedIds: []uint64{123, 543}, expectedTotal: "2"},
+		{testName: "testLimit", query: "?limit=2", expectedHttpStatus: 200, expectedIds: []uint64{123, 321}, expectedTotal: "3", expectedLink: "rel=\"next\""},
+		{testName: "testBadLimit", query: "?limit=0", expectedHttpStatus: 400},
+		{testName: "testBadCursor", query: "?cursor=xyz", expectedHttpStatus: 400},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{{Id: 3, Title: "popular post"}}), repository.CustomCommentRepository(validComments), WithLegacyErrors())
+			path := strings.Replace(getCommentPath, "{id}", "3", 1) + tc.query
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			w := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			// WHEN
+			router.HandleFunc(getCommentPath, svc.handleGetCommentsByPostId)
+			router.ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedHttpStatus != http.StatusOK {
+				return
+			}
+			var commentsList []model.Comment
+			require.NoError(t, json.Unmarshal(body, &commentsList))
+			ids := []uint64{}
+			for _, comment := range commentsList {
+				ids = append(ids, comment.Id)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedTotal, response.Header.Get("X-Total-Count"))
+			assert.Contains(t, response.Header.Get("Link"), tc.expectedLink)
+			assert.Equal(t, tc.expectedLink != "", response.Header.Get("X-Next-Cursor") != "")
+		})
+	}
+
+	t.Run("testFollowLinkHeader", func(t *testing.T) {
+		svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{{Id: 3, Title: "popular post"}}), repository.CustomCommentRepository(validComments), WithLegacyErrors())
+		router := mux.NewRouter()
+		router.HandleFunc(getCommentPath, svc.handleGetCommentsByPostId)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/posts/comments/3?limit=2", nil))
+		link := w.Result().Header.Get("Link")
+		next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), ">; rel=\"next\"")
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, next, nil))
+		var commentsList []model.Comment
+		require.NoError(t, json.NewDecoder(w.Result().Body).Decode(&commentsList))
+		require.Len(t, commentsList, 1)
+		assert.Equal(t, uint64(543), commentsList[0].Id)
+		assert.Empty(t, w.Result().Header.Get("Link"))
+	})
+}
+
+// vanishingPostStore reports its post as existing only for the first lookup,
+// as if it was deleted right after.
+type vanishingPostStore struct {
+	repository.PostStore
+	lookups int
+}
+
+func (s *vanishingPostStore) GetById(id uint64) (*model.Post, error) {
+	s.lookups++
+	if s.lookups > 1 {
+		return nil, repository.PostNotFoundError{}
+	}
+	return &model.Post{Id: id}, nil
+}
+
+func TestRestApiService_handleAddCommentToVanishingPost(t *testing.T) {
+	// GIVEN
+	commentRepository := repository.NewCommentRepository()
+	svc := NewRestApiServiceWithStores(&vanishingPostStore{}, commentRepository, WithLegacyErrors())
+	req := httptest.NewRequest(http.MethodPost, commentsPath, strings.NewReader(`{"PostId": 3, "Comment": "late", "Author": "slowpoke"}`))
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.handleAddComment(w, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	assert.Empty(t, commentRepository.GetAllByPostId(3))
+}
+
+func TestRestApiService_addIgnoresServerFields(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	router := svc.router()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	const editedAt = `"EditedAt": "2001-01-01T00:00:00Z"`
+
+	// WHEN
+	addedPost := serve(http.MethodPost, postsPath, `{"Title": "title", "Content": "content", `+editedAt+`}`)
+	addedComment := serve(http.MethodPost, commentsPath, `{"PostId": 1, "Comment": "comment", "Author": "author", "Deleted": true, `+editedAt+`}`)
+
+	// THEN
+	require.Equal(t, http.StatusOK, addedPost.Code, addedPost.Body.String())
+	require.Equal(t, http.StatusOK, addedComment.Code, addedComment.Body.String())
+	post, err := svc.postRepository.GetById(1)
+	require.NoError(t, err)
+	assert.Nil(t, post.EditedAt)
+	comment, err := svc.commentRepository.GetById(1)
+	require.NoError(t, err)
+	assert.False(t, comment.Deleted)
+	assert.Nil(t, comment.EditedAt)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPatch, "/api/comments/1", `{"Comment": "edited"}`).Code)
+}
+
+func TestRestApiService_commentReplies(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 3, Title: "popular post", Content: "test content", CreationDate: testDate},
+		{Id: 4, Title: "other post", Content: "test content", CreationDate: testDate},
+	}
+	var comments = []model.Comment{
+		{Id: 1, PostId: 3, Comment: "root", Author: "author", CreationDate: testDate},
+		{Id: 2, PostId: 3, ParentId: 1, Comment: "depth 1", Author: "author", CreationDate: testDate},
+		{Id: 3, PostId: 3, ParentId: 2, Comment: "depth 2", Author: "author", CreationDate: testDate},
+		{Id: 4, PostId: 3, ParentId: 3, Comment: "depth 3", Author: "author", CreationDate: testDate},
+		{Id: 5, PostId: 3, ParentId: 4, Comment: "depth 4", Author: "author", CreationDate: testDate},
+		{Id: 6, PostId: 3, ParentId: 5, Comment: "depth 5", Author: "author", CreationDate: testDate},
+		{Id: 7, PostId: 4, Comment: "elsewhere", Author: "author", CreationDate: testDate},
+		{Id: 8, PostId: 3, Comment: model.DeletedCommentPlaceholder, Author: model.DeletedCommentPlaceholder, Deleted: true, CreationDate: testDate},
+	}
+
+	tests := []struct {
+		testName           string
+		method             string
+		path               string
+		reqBody            string
+		expectedHttpStatus int
+		expectedErrors     []FieldError
+		expectedMessage    string
+	}{
+		{
+			testName:           "testReply",
+			method:             http.MethodPost,
+			path:               commentsPath,
+			reqBody:            `{"PostId": 3, "ParentId": 5, "Comment": "deepest", "Author": "author"}`,
+			expectedHttpStatus: 200,
+		},
+		{
+			testName:           "testReplyTooDeep",
+			method:             http.MethodPost,
+			path:               commentsPath,
+			reqBody:            `{"PostId": 3, "ParentId": 6, "Comment": "too deep", "Author": "author"}`,
+			expectedHttpStatus: 422,
+			expectedErrors:     []FieldError{{Field: "ParentId", Reason: "exceeds the maximum nesting depth of 5"}},
+		},
+		{
+			testName:           "testReplyToMissingComment",
+			method:             http.MethodPost,
+			path:               commentsPath,
+			reqBody:            `{"PostId": 3, "ParentId": 99, "Comment": "reply", "Author": "author"}`,
+			expectedHttpStatus: 422,
+			expectedErrors:     []FieldError{{Field: "ParentId", Reason: "does not exist"}},
+		},
+		{
+			testName:           "testReplyToOtherPost",
+			method:             http.MethodPost,
+			path:               commentsPath,
+			reqBody:            `{"PostId": 3, "ParentId": 7, "Comment": "reply", "Author": "author"}`,
+			expectedHttpStatus: 422,
+			expectedErrors:     []FieldError{{Field: "ParentId", Reason: "must be a comment of the same post"}},
+		},
+		{
+			testName:           "testReplyToDeletedComment",
+			method:             http.MethodPost,
+			path:               commentsPath,
+			reqBody:            `{"PostId": 3, "ParentId": 8, "Comment": "reply", "Author": "author"}`,
+			expectedHttpStatus: 422,
+			expectedErrors:     []FieldError{{Field: "ParentId", Reason: "was deleted"}},
+		},
+		{
+			testName:           "testMoveToAnotherThread",
+			method:             http.MethodPatch,
+			path:               "/api/comments/2",
+			reqBody:            `{"ParentId": null}`,
+			expectedHttpStatus: 400,
+			expectedMessage:    "comments cannot be moved to another thread",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			commentRepository := repository.CustomCommentRepository(comments)
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), commentRepository, WithLegacyErrors())
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			switch {
+			case tc.expectedErrors != nil:
+				var resp ValidationErrorResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, tc.expectedErrors, resp.Errors)
+				assert.Len(t, commentRepository.GetAllByPostId(3), 7)
+			case tc.expectedMessage != "":
+				var resp AckJsonResponse
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, tc.expectedMessage, resp.Message)
+			}
+		})
+	}
+
+	t.Run("testReplaceKeepsParent", func(t *testing.T) {
+		commentRepository := repository.CustomCommentRepository(comments)
+		svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), commentRepository, WithLegacyErrors())
+		w := httptest.NewRecorder()
+		svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/comments/2", strings.NewReader(`{"Comment": "edited", "Author": "author"}`)))
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		stored, err := commentRepository.GetById(2)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), stored.ParentId)
+	})
+}
+
+func TestRestApiService_commentThreadViews(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var (
+		root   = model.Comment{Id: 1, PostId: 3, Comment: "root", Author: "author", CreationDate: testDate}
+		other  = model.Comment{Id: 2, PostId: 3, Comment: "other root", Author: "author", CreationDate: testDate.Add(time.Minute)}
+		reply  = model.Comment{Id: 3, PostId: 3, ParentId: 1, Comment: "reply", Author: "author", CreationDate: testDate.Add(2 * time.Minute)}
+		nested = model.Comment{Id: 4, PostId: 3, ParentId: 3, Comment: "nested", Author: "author", CreationDate: testDate.Add(3 * time.Minute)}
+	)
+
+	tests := []struct {
+		testName           string
+		query              string
+		expectedHttpStatus int
+		expectedBody       interface{}
+	}{
+		{
+			testName:           "testTreeView",
+			query:              "?view=tree",
+			expectedHttpStatus: 200,
+			expectedBody: []repository.CommentNode{
+				{Comment: root, Replies: []repository.CommentNode{{Comment: reply, Replies: []repository.CommentNode{{Comment: nested}}}}},
+				{Comment: other},
+			},
+		},
+		{
+			testName:           "testFlatView",
+			query:              "?view=flat&order=desc",
+			expectedHttpStatus: 200,
+			expectedBody: []repository.ThreadedComment{
+				{Comment: other, Depth: 0},
+				{Comment: root, Depth: 0},
+				{Comment: reply, Depth: 1},
+				{Comment: nested, Depth: 2},
+			},
+		},
+		{testName: "testUnknownView", query: "?view=graph", expectedHttpStatus: 400},
+		{testName: "testPaginatedView", query: "?view=tree&limit=2", expectedHttpStatus: 400},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{{Id: 3, Title: "popular post"}}), repository.CustomCommentRepository([]model.Comment{nested, reply, other, root}))
+			req := httptest.NewRequest(http.MethodGet, "/api/posts/comments/3"+tc.query, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedBody == nil {
+				return
+			}
+			assert.Equal(t, "4", response.Header.Get("X-Total-Count"))
+			expected, err := json.Marshal(tc.expectedBody)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(body))
 		})
 	}
 }
+
+func TestRestApiService_tags(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 1, Title: "first", Content: "content", Tags: []string{"go", "rest"}, Category: "backend", CreationDate: testDate},
+		{Id: 2, Title: "second", Content: "content", Tags: []string{"go"}, Category: "backend", CreationDate: testDate.Add(time.Hour)},
+		{Id: 3, Title: "third", Content: "content", Tags: []string{"css"}, Category: "frontend", CreationDate: testDate.Add(2 * time.Hour)},
+	}
+
+	tests := []struct {
+		testName           string
+		path               string
+		expectedHttpStatus int
+		expectedIds        []uint64
+		expectedTotal      int
+	}{
+		{testName: "testPostsByTag", path: "/api/tags/Go/posts", expectedHttpStatus: 200, expectedIds: []uint64{2, 1}, expectedTotal: 2},
+		{testName: "testPostsByTagPaginated", path: "/api/tags/go/posts?limit=1&order=asc", expectedHttpStatus: 200, expectedIds: []uint64{1}, expectedTotal: 2},
+		{testName: "testPostsByUnknownTag", path: "/api/tags/rust/posts", expectedHttpStatus: 200, expectedIds: []uint64{}, expectedTotal: 0},
+		{testName: "testPostsByEmptyTag", path: "/api/tags/--/posts", expectedHttpStatus: 400},
+		{testName: "testListByTagAndCategory", path: "/api/posts?tag=GO&category=Backend", expectedHttpStatus: 200, expectedIds: []uint64{2, 1}, expectedTotal: 2},
+		{testName: "testListByCategory", path: "/api/posts?category=frontend", expectedHttpStatus: 200, expectedIds: []uint64{3}, expectedTotal: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository())
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedHttpStatus != http.StatusOK {
+				return
+			}
+			var list PostListResponse
+			require.NoError(t, json.Unmarshal(body, &list))
+			ids := []uint64{}
+			for _, post := range list.Items {
+				ids = append(ids, post.Id)
+			}
+			assert.Equal(t, tc.expectedIds, ids)
+			assert.Equal(t, tc.expectedTotal, list.Total)
+		})
+	}
+
+	t.Run("testListTags", func(t *testing.T) {
+		svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository())
+		w := httptest.NewRecorder()
+		svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tagsPath, nil))
+
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+		assert.JSONEq(t, `[{"tag": "css", "count": 1}, {"tag": "go", "count": 2}, {"tag": "rest", "count": 1}]`, w.Body.String())
+	})
+
+	t.Run("testReplaceNormalizesTags", func(t *testing.T) {
+		svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository())
+		w := httptest.NewRecorder()
+		reqBody := `{"Title": "first", "Content": "content", "Tags": ["Web Dev", "web-dev", "Go"], "Category": "Back End"}`
+		svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/api/posts/1", strings.NewReader(reqBody)))
+
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+		var post model.Post
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &post))
+		assert.Equal(t, []string{"web-dev", "go"}, post.Tags)
+		assert.Equal(t, "back-end", post.Category)
+	})
+}
+
+func TestRestApiService_postSlugs(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var existingPost = model.Post{Id: 3, Title: "Crème Brûlée", Content: "content", CreationDate: testDate}
+
+	tests := []struct {
+		testName           string
+		path               string
+		expectedHttpStatus int
+		expectedLocation   string
+		expectedPost       *model.Post
+	}{
+		{testName: "testGetByCurrentSlug", path: "/api/posts/by-slug/dessert-recipes", expectedHttpStatus: 200,
+			expectedPost: &model.Post{Id: 3, Title: "Dessert recipes", Slug: "dessert-recipes", Content: "content", CreationDate: testDate}},
+		{testName: "testGetByFormerSlug", path: "/api/posts/by-slug/creme-brulee", expectedHttpStatus: 301,
+			expectedLocation: "/api/posts/by-slug/dessert-recipes"},
+		{testName: "testGetByUnknownSlug", path: "/api/posts/by-slug/tiramisu", expectedHttpStatus: 404},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{existingPost}), repository.NewCommentRepository())
+			rename := httptest.NewRequest(http.MethodPatch, "/api/posts/3", strings.NewReader(`{"Title": "Dessert recipes"}`))
+			renamed := httptest.NewRecorder()
+			svc.router().ServeHTTP(renamed, rename)
+			require.Equal(t, http.StatusOK, renamed.Code)
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			assert.Equal(t, tc.expectedLocation, response.Header.Get("Location"))
+			if tc.expectedPost != nil {
+				var post model.Post
+				require.NoError(t, json.Unmarshal(body, &post))
+				post.EditedAt = nil
+				assert.Equal(t, *tc.expectedPost, post)
+			}
+		})
+	}
+}
+
+func TestRestApiService_addPostGeneratesSlug(t *testing.T) {
+	// GIVEN
+	postRepository := repository.CustomPostRepository([]model.Post{{Id: 1, Title: "Hello World", CreationDate: time.Now()}})
+	svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository())
+	req := httptest.NewRequest(http.MethodPost, postsPath, strings.NewReader(`{"Title": "Hello, world!", "Content": "content", "Slug": "custom"}`))
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, req)
+
+	// THEN
+	require.Equal(t, http.StatusOK, w.Code)
+	post, err := postRepository.GetById(2)
+	require.NoError(t, err)
+	assert.Equal(t, "hello-world-2", post.Slug)
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"bitbucket.org/mindera/go-rest-blog/diff"
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+// authorHeader names the editor a change to a post is recorded for.
+const authorHeader = "X-Author"
+
+type RevisionDiffResponse struct {
+	PostId  uint64      `json:"postId"`
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Line `json:"title"`
+	Content []diff.Line `json:"content"`
+}
+
+// WithRevisions keeps the revision history of posts in the given store
+// instead of in memory.
+func WithRevisions(revisions repository.RevisionStore) Option {
+	return func(svc *RestApiService) {
+		svc.revisionRepository = revisions
+	}
+}
+
+// handleListRevisions serves GET /api/posts/{id}/revisions, oldest first.
+func (svc *RestApiService) handleListRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if _, err := svc.readablePost(r, id); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, svc.revisionRepository.GetAllByPostId(id))
+}
+
+func (svc *RestApiService) handleGetRevision(w http.ResponseWriter, r *http.Request) {
+	id, number, err := svc.parseRevisionPath(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if _, err := svc.readablePost(r, id); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	revision, err := svc.revisionRepository.Get(id, number)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Revision: %d of post with id: %d does not exist", number, id))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, revision)
+}
+
+// handleDiffRevisions serves GET /api/posts/{id}/revisions/diff?from=&to=
+// comparing the title and content of two revisions line by line. to
+// defaults to the latest revision and from to the one before it; from=0
+// compares against an empty post.
+func (svc *RestApiService) handleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if _, err := svc.readablePost(r, id); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	revisions := svc.revisionRepository.GetAllByPostId(id)
+	if len(revisions) == 0 {
+		svc.writeError(w, r, newApiError(http.StatusNotFound, "Post with id: %d has no revisions", id))
+		return
+	}
+	params := r.URL.Query()
+	to, err := parseRevisionParam("to", params.Get("to"), len(revisions), 1, len(revisions))
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	from, err := parseRevisionParam("from", params.Get("from"), to-1, 0, len(revisions))
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	var old model.Revision
+	if from > 0 {
+		old = revisions[from-1]
+	}
+	current := revisions[to-1]
+	writeJsonResponse(w, http.StatusOK, RevisionDiffResponse{
+		PostId:  id,
+		From:    from,
+		To:      to,
+		Title:   diff.Lines(old.Title, current.Title),
+		Content: diff.Lines(old.Content, current.Content),
+	})
+}
+
+// handleRestoreRevision serves POST /api/posts/{id}/revisions/{number}/restore,
+// bringing back the title, content, format, tags and category of the revision
+// as a new revision. The status of the post is left alone.
+func (svc *RestApiService) handleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	id, number, err := svc.parseRevisionPath(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	existing, err := svc.postRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Post with id: %d does not exist", id))
+		return
+	}
+	if err := svc.authorize(r, editPost, existing.OwnerId); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	revision, err := svc.revisionRepository.Get(id, number)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "Revision: %d of post with id: %d does not exist", number, id))
+		return
+	}
+	post := *existing
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Format = revision.Format
+	post.Tags = revision.Tags
+	post.Category = revision.Category
+	svc.updatePost(w, r, *existing, post)
+}
+
+func (svc *RestApiService) parseRevisionPath(r *http.Request) (uint64, int, error) {
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	value := mux.Vars(r)["number"]
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, 0, newApiError(http.StatusBadRequest, "wrong revision path variable: %s", value)
+	}
+	return id, number, nil
+}
+
+func parseRevisionParam(name string, value string, defaultNumber int, min int, max int) (int, error) {
+	if value == "" {
+		return defaultNumber, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return 0, newApiError(http.StatusBadRequest, "wrong %s query parameter: %s, expected a revision between %d and %d", name, value, min, max)
+	}
+	return number, nil
+}
+
+// revisionAuthor is the authenticated editor or, for anonymous callers, the
+// one named by the X-Author header, empty when the request does not name one.
+func revisionAuthor(r *http.Request) string {
+	if user := currentUser(r); user != nil {
+		return user.Username
+	}
+	author := strings.TrimSpace(r.Header.Get(authorHeader))
+	for utf8.RuneCountInString(author) > maxAuthorLength {
+		_, size := utf8.DecodeLastRuneInString(author)
+		author = author[:len(author)-size]
+	}
+	return author
+}
+
+// recordRevision adds the post as stored as its latest revision.
+func (svc *RestApiService) recordRevision(post model.Post, author string) error {
+	if svc.revisionRepository == nil {
+		return nil
+	}
+	_, err := svc.revisionRepository.Add(newRevision(post, author, time.Time{}))
+	return err
+}
+
+// recordBaseline makes sure a post created before revisions were recorded
+// keeps its current text as the first revision before it is changed. The
+// author of such a revision is unknown.
+func (svc *RestApiService) recordBaseline(post model.Post) error {
+	if svc.revisionRepository == nil || len(svc.revisionRepository.GetAllByPostId(post.Id)) > 0 {
+		return nil
+	}
+	createdAt := post.CreationDate
+	if post.EditedAt != nil {
+		createdAt = *post.EditedAt
+	}
+	_, err := svc.revisionRepository.Add(newRevision(post, "", createdAt))
+	return err
+}
+
+func (svc *RestApiService) deleteRevisions(postId uint64) error {
+	if svc.revisionRepository == nil {
+		return nil
+	}
+	_, err := svc.revisionRepository.DeleteAllByPostId(postId)
+	return err
+}
+
+func newRevision(post model.Post, author string, createdAt time.Time) model.Revision {
+	return model.Revision{
+		PostId:    post.Id,
+		Author:    author,
+		CreatedAt: createdAt,
+		Title:     post.Title,
+		Content:   post.Content,
+		Format:    post.Format,
+		Tags:      post.Tags,
+		Category:  post.Category,
+		Status:    post.PublicationStatus(),
+	}
+}
+
+func revisionError(id uint64, err error) error {
+	return newApiError(http.StatusInternalServerError, "post id: %d was changed but its revision could not be recorded: %v", id, err)
+}
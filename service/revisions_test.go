@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/diff"
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestRestApiService_revisionHistory(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	router := svc.router()
+	serve := func(method, path, author, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if author != "" {
+			req.Header.Set(authorHeader, author)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Result()
+	}
+
+	// WHEN
+	require.Equal(t, 200, serve(http.MethodPost, postsPath, "alice", `{"Id": 1, "Title": "title", "Content": "line1\nline2\nline3"}`).StatusCode)
+	require.Equal(t, 200, serve(http.MethodPut, "/api/posts/1", "  bob ", `{"Title": "new title", "Content": "line1\nchanged\nline3"}`).StatusCode)
+	require.Equal(t, 200, serve(http.MethodPatch, "/api/posts/1", "", `{"Tags": ["go"]}`).StatusCode)
+
+	// THEN
+	response := serve(http.MethodGet, "/api/posts/1/revisions", "", "")
+	require.Equal(t, 200, response.StatusCode)
+	var revisions []model.Revision
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&revisions))
+	require.Len(t, revisions, 3)
+	for i, author := range []string{"alice", "bob", ""} {
+		assert.Equal(t, i+1, revisions[i].Number)
+		assert.Equal(t, author, revisions[i].Author)
+		assert.Equal(t, model.StatusPublished, revisions[i].Status)
+	}
+	assert.Equal(t, "line1\nline2\nline3", revisions[0].Content)
+	assert.Equal(t, []string{"go"}, revisions[2].Tags)
+
+	response = serve(http.MethodGet, "/api/posts/1/revisions/diff?from=1&to=2", "", "")
+	require.Equal(t, 200, response.StatusCode)
+	var delta RevisionDiffResponse
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&delta))
+	assert.Equal(t, RevisionDiffResponse{
+		PostId: 1,
+		From:   1,
+		To:     2,
+		Title: []diff.Line{
+			{Op: diff.Delete, Text: "title", OldNumber: 1},
+			{Op: diff.Insert, Text: "new title", NewNumber: 1},
+		},
+		Content: []diff.Line{
+			{Op: diff.Equal, Text: "line1", OldNumber: 1, NewNumber: 1},
+			{Op: diff.Delete, Text: "line2", OldNumber: 2},
+			{Op: diff.Insert, Text: "changed", NewNumber: 2},
+			{Op: diff.Equal, Text: "line3", OldNumber: 3, NewNumber: 3},
+		},
+	}, delta)
+
+	response = serve(http.MethodPost, "/api/posts/1/revisions/1/restore", "carol", "")
+	require.Equal(t, 200, response.StatusCode)
+	var restored model.Post
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&restored))
+	assert.Equal(t, "title", restored.Title)
+	assert.Equal(t, "line1\nline2\nline3", restored.Content)
+	assert.Empty(t, restored.Tags)
+
+	response = serve(http.MethodGet, "/api/posts/1/revisions/4", "", "")
+	require.Equal(t, 200, response.StatusCode)
+	var latest model.Revision
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&latest))
+	assert.Equal(t, "carol", latest.Author)
+	assert.Equal(t, revisions[0].Content, latest.Content)
+
+	require.Equal(t, 200, serve(http.MethodDelete, "/api/posts/1", "", "").StatusCode)
+	assert.Empty(t, svc.revisionRepository.GetAllByPostId(1))
+}
+
+func TestRestApiService_revisionEndpoints(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 1, Title: "post1", Content: "content", CreationDate: testDate},
+		{Id: 2, Title: "draft", Content: "content", Status: model.StatusDraft, CreationDate: testDate},
+		{Id: 3, Title: "post3", Content: "content", CreationDate: testDate},
+	}
+	var revisions = []model.Revision{
+		{PostId: 1, Title: "post1", Content: "first", CreatedAt: testDate},
+		{PostId: 1, Title: "post1", Content: "content", CreatedAt: testDate},
+		{PostId: 2, Title: "draft", Content: "content", CreatedAt: testDate},
+	}
+
+	tests := []struct {
+		testName           string
+		method             string
+		path               string
+		expectedHttpStatus int
+		expectedBody       string
+	}{
+		{testName: "testGetRevision", method: http.MethodGet, path: "/api/posts/1/revisions/1", expectedHttpStatus: 200,
+			expectedBody: `{"PostId":1,"Number":1,"Author":"","CreatedAt":"2018-09-16T12:00:00Z","Title":"post1","Content":"first"}`},
+		{testName: "testGetUnknownRevision", method: http.MethodGet, path: "/api/posts/1/revisions/3", expectedHttpStatus: 404},
+		{testName: "testGetRevisionZero", method: http.MethodGet, path: "/api/posts/1/revisions/0", expectedHttpStatus: 400},
+		{testName: "testListUnknownPost", method: http.MethodGet, path: "/api/posts/9/revisions", expectedHttpStatus: 404},
+		{testName: "testListDraft", method: http.MethodGet, path: "/api/posts/2/revisions", expectedHttpStatus: 404},
+		{testName: "testGetDraftRevision", method: http.MethodGet, path: "/api/posts/2/revisions/1", expectedHttpStatus: 404},
+		{testName: "testListWithoutRevisions", method: http.MethodGet, path: "/api/posts/3/revisions", expectedHttpStatus: 200,
+			expectedBody: `[]`},
+		{testName: "testDiffDefaults", method: http.MethodGet, path: "/api/posts/1/revisions/diff", expectedHttpStatus: 200,
+			expectedBody: `{"postId":1,"from":1,"to":2,"title":[{"op":"equal","text":"post1","oldLine":1,"newLine":1}],` +
+				`"content":[{"op":"delete","text":"first","oldLine":1},{"op":"insert","text":"content","newLine":1}]}`},
+		{testName: "testDiffFromEmpty", method: http.MethodGet, path: "/api/posts/1/revisions/diff?from=0&to=1", expectedHttpStatus: 200,
+			expectedBody: `{"postId":1,"from":0,"to":1,"title":[{"op":"insert","text":"post1","newLine":1}],` +
+				`"content":[{"op":"insert","text":"first","newLine":1}]}`},
+		{testName: "testDiffOutOfRange", method: http.MethodGet, path: "/api/posts/1/revisions/diff?to=3", expectedHttpStatus: 400},
+		{testName: "testDiffInvalidFrom", method: http.MethodGet, path: "/api/posts/1/revisions/diff?from=abc", expectedHttpStatus: 400},
+		{testName: "testDiffWithoutRevisions", method: http.MethodGet, path: "/api/posts/3/revisions/diff", expectedHttpStatus: 404},
+		{testName: "testRestoreUnknownRevision", method: http.MethodPost, path: "/api/posts/1/revisions/5/restore", expectedHttpStatus: 404},
+		{testName: "testRestoreUnknownPost", method: http.MethodPost, path: "/api/posts/9/revisions/1/restore", expectedHttpStatus: 404},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			revisionRepository := repository.NewRevisionRepository()
+			for _, revision := range revisions {
+				_, err := revisionRepository.Add(revision)
+				require.NoError(t, err)
+			}
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository(), WithRevisions(revisionRepository))
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
+
+func TestRestApiService_recordsBaselineRevision(t *testing.T) {
+	// GIVEN
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	postRepository := repository.CustomPostRepository([]model.Post{{Id: 1, Title: "post1", Content: "original", CreationDate: testDate}})
+	svc := NewRestApiServiceWithStores(postRepository, repository.NewCommentRepository())
+	req := httptest.NewRequest(http.MethodPatch, "/api/posts/1", strings.NewReader(`{"Content": "edited"}`))
+	req.Header.Set(authorHeader, "alice")
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, req)
+
+	// THEN
+	require.Equal(t, 200, w.Result().StatusCode)
+	revisions := svc.revisionRepository.GetAllByPostId(1)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, model.Revision{PostId: 1, Number: 1, CreatedAt: testDate, Title: "post1", Content: "original", Status: model.StatusPublished}, revisions[0])
+	assert.Equal(t, "alice", revisions[1].Author)
+	assert.Equal(t, "edited", revisions[1].Content)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"bitbucket.org/mindera/go-rest-blog/repository"
+	"bitbucket.org/mindera/go-rest-blog/search"
+)
+
+// handleSearch serves GET /api/search?q=&type=post|comment&limit=&offset=
+// where q holds words and double quoted phrases.
+func (svc *RestApiService) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query, err := parseSearchQuery(r.URL.Query())
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, svc.searchIndex.Search(query))
+}
+
+func parseSearchQuery(values url.Values) (search.Query, error) {
+	query := search.Query{Text: values.Get("q")}
+	if strings.TrimSpace(query.Text) == "" {
+		return query, newApiError(http.StatusBadRequest, "missing q query parameter")
+	}
+	switch kind := values.Get("type"); kind {
+	case "", repository.PostKind, repository.CommentKind:
+		query.Kind = kind
+	default:
+		return query, newApiError(http.StatusBadRequest, "wrong type query parameter: %s, expected %s or %s", kind, repository.PostKind, repository.CommentKind)
+	}
+	limit, err := parsePageLimit(values.Get("limit"))
+	if err != nil {
+		return query, err
+	}
+	query.Limit = limit
+	if value := values.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return query, newApiError(http.StatusBadRequest, "wrong offset query parameter: %s, expected a non-negative number", value)
+		}
+		query.Offset = offset
+	}
+	return query, nil
+}
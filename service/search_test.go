@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+	"bitbucket.org/mindera/go-rest-blog/search"
+)
+
+func TestRestApiService_search(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var posts = []model.Post{
+		{Id: 1, Title: "Searching with Go", Content: "An inverted index maps terms to documents.", CreationDate: testDate},
+		{Id: 2, Title: "Baking bread", Content: "Bread needs flour, water and time.", CreationDate: testDate},
+	}
+	var comments = []model.Comment{
+		{Id: 5, PostId: 2, Comment: "My bread never rises", CreationDate: testDate},
+	}
+
+	tests := []struct {
+		testName           string
+		path               string
+		expectedHttpStatus int
+		expectedResults    []search.Result
+		expectedTotal      int
+	}{
+		{testName: "testSearchPhrase", path: `/api/search?q="inverted+index"`, expectedHttpStatus: 200,
+			expectedResults: []search.Result{{Type: "post", Id: 1, PostId: 1, Title: "Searching with Go",
+				Snippet: "An <mark>inverted</mark> <mark>index</mark> maps terms to documents."}},
+			expectedTotal: 1},
+		{testName: "testSearchComments", path: "/api/search?q=bread&type=comment", expectedHttpStatus: 200,
+			expectedResults: []search.Result{{Type: "comment", Id: 5, PostId: 2, Snippet: "My <mark>bread</mark> never rises"}},
+			expectedTotal:   1},
+		{testName: "testSearchPaginated", path: "/api/search?q=bread&limit=1&offset=1", expectedHttpStatus: 200,
+			expectedResults: []search.Result{{Type: "comment", Id: 5, PostId: 2, Snippet: "My <mark>bread</mark> never rises"}},
+			expectedTotal:   2},
+		{testName: "testSearchNoMatch", path: "/api/search?q=rust", expectedHttpStatus: 200, expectedResults: []search.Result{}},
+		{testName: "testSearchMissingQuery", path: "/api/search", expectedHttpStatus: 400},
+		{testName: "testSearchWrongType", path: "/api/search?q=bread&type=user", expectedHttpStatus: 400},
+		{testName: "testSearchWrongOffset", path: "/api/search?q=bread&offset=-1", expectedHttpStatus: 400},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			postStore, commentStore, index, err := search.Wrap(repository.CustomPostRepository(posts), repository.CustomCommentRepository(comments))
+			require.NoError(t, err)
+			svc := NewRestApiServiceWithStores(postStore, commentStore, WithSearch(index))
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, response.StatusCode)
+			if tc.expectedHttpStatus != http.StatusOK {
+				return
+			}
+			var results search.Results
+			require.NoError(t, json.Unmarshal(body, &results))
+			for i := range results.Items {
+				assert.Greater(t, results.Items[i].Score, 0.0)
+				results.Items[i].Score = 0
+			}
+			assert.Equal(t, tc.expectedResults, results.Items)
+			assert.Equal(t, tc.expectedTotal, results.Total)
+		})
+	}
+}
+
+func TestRestApiService_searchDisabled(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=bread", nil)
+	w := httptest.NewRecorder()
+
+	// WHEN
+	svc.router().ServeHTTP(w, req)
+
+	// THEN
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
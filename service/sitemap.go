@@ -0,0 +1,278 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const (
+	sitemapPath     = "/sitemap.xml"
+	sitemapPagePath = "/sitemaps/{page:[0-9]+}.xml"
+	robotsPath      = "/robots.txt"
+)
+
+// maxSitemapURLs is the most URLs a single sitemap may list; larger sites
+// are split into pages listed by a sitemap index.
+const maxSitemapURLs = 50000
+
+// maxSitemapHosts is the most hosts sitemaps are cached for at once when no
+// base URL is configured; the host cached first makes room for a new one.
+const maxSitemapHosts = 8
+
+const defaultRobots = "User-agent: *\nAllow: /\n"
+
+// WithRobots serves the given rules from /robots.txt instead of allowing
+// every crawler everywhere. A Sitemap line pointing to /sitemap.xml is added
+// unless the rules name a sitemap themselves.
+func WithRobots(rules string) Option {
+	return func(svc *RestApiService) {
+		svc.robots = rules
+	}
+}
+
+// sitemapCache keeps the generated sitemaps until a post changes, one per
+// base URL they link to.
+type sitemapCache struct {
+	mu sync.Mutex
+	// pageSize is the number of URLs per sitemap, maxSitemapURLs unless
+	// changed by tests.
+	pageSize int
+	// documents holds /sitemap.xml followed by the pages it indexes, if any,
+	// by base URL.
+	documents map[string][]sitemapDocument
+	// baseURLs lists the keys of documents, oldest first.
+	baseURLs []string
+}
+
+type sitemapDocument struct {
+	data     []byte
+	modified time.Time
+}
+
+func newSitemapCache() *sitemapCache {
+	return &sitemapCache{pageSize: maxSitemapURLs}
+}
+
+func (c *sitemapCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.documents, c.baseURLs = nil, nil
+}
+
+// put caches the documents for the base URL, evicting the oldest base URL
+// when maxSitemapHosts are cached already. Callers must hold the lock.
+func (c *sitemapCache) put(baseURL string, documents []sitemapDocument) {
+	if c.documents == nil {
+		c.documents = make(map[string][]sitemapDocument)
+	}
+	if len(c.baseURLs) >= maxSitemapHosts {
+		delete(c.documents, c.baseURLs[0])
+		c.baseURLs = c.baseURLs[1:]
+	}
+	c.documents[baseURL] = documents
+	c.baseURLs = append(c.baseURLs, baseURL)
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func (svc *RestApiService) handleSitemap(w http.ResponseWriter, r *http.Request) {
+	svc.writeSitemapDocument(w, r, 0)
+}
+
+// handleSitemapPage serves GET /sitemaps/{page}.xml, the pages of a sitemap
+// split by a sitemap index.
+func (svc *RestApiService) handleSitemapPage(w http.ResponseWriter, r *http.Request) {
+	value := mux.Vars(r)["page"]
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 1 {
+		svc.writeError(w, r, newApiError(http.StatusNotFound, "Sitemap: %s does not exist", value))
+		return
+	}
+	svc.writeSitemapDocument(w, r, page)
+}
+
+func (svc *RestApiService) writeSitemapDocument(w http.ResponseWriter, r *http.Request, n int) {
+	if svc.baseURL == "" && !validHost(r.Host) {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "invalid host: %q", r.Host))
+		return
+	}
+	documents, err := svc.sitemapDocuments(svc.requestBaseURL(r))
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if n >= len(documents) {
+		svc.writeError(w, r, newApiError(http.StatusNotFound, "Sitemap: %d does not exist", n))
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	http.ServeContent(w, r, "", documents[n].modified, bytes.NewReader(documents[n].data))
+}
+
+func (svc *RestApiService) handleRobots(w http.ResponseWriter, r *http.Request) {
+	rules := svc.robots
+	if rules == "" {
+		rules = defaultRobots
+	}
+	if !strings.HasSuffix(rules, "\n") {
+		rules += "\n"
+	}
+	if !strings.Contains(strings.ToLower(rules), "sitemap:") {
+		rules += "\nSitemap: " + svc.requestBaseURL(r) + sitemapPath + "\n"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(rules))
+}
+
+// sitemapDocuments returns the cached sitemap for the base URL, generating
+// it when a post changed since it was last generated.
+func (svc *RestApiService) sitemapDocuments(baseURL string) ([]sitemapDocument, error) {
+	if svc.sitemap == nil {
+		return svc.generateSitemap(baseURL, maxSitemapURLs)
+	}
+	c := svc.sitemap
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if documents, ok := c.documents[baseURL]; ok {
+		return documents, nil
+	}
+	documents, err := svc.generateSitemap(baseURL, c.pageSize)
+	if err != nil {
+		return nil, err
+	}
+	c.put(baseURL, documents)
+	return documents, nil
+}
+
+// validHost reports whether host is a host name or IP address, optionally
+// followed by a port, as sent in the Host header.
+func validHost(host string) bool {
+	name, port := host, ""
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		name, port = host[:i], host[i+1:]
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || port[0] == '+' {
+			return false
+		}
+	}
+	if strings.HasPrefix(name, "[") && strings.HasSuffix(name, "]") {
+		ip := net.ParseIP(name[1 : len(name)-1])
+		return ip != nil && ip.To4() == nil
+	}
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (svc *RestApiService) invalidateSitemap() {
+	if svc.sitemap != nil {
+		svc.sitemap.invalidate()
+	}
+}
+
+// generateSitemap lists every published post, oldest first. Up to pageSize
+// posts are listed by a single sitemap, more are split into pages of
+// pageSize posts listed by a sitemap index.
+func (svc *RestApiService) generateSitemap(baseURL string, pageSize int) ([]sitemapDocument, error) {
+	page, err := svc.postRepository.Query(repository.PostQuery{Status: model.StatusPublished})
+	if err != nil {
+		return nil, err
+	}
+	var pages [][]model.Post
+	for start := 0; start < len(page.Items); start += pageSize {
+		end := start + pageSize
+		if end > len(page.Items) {
+			end = len(page.Items)
+		}
+		pages = append(pages, page.Items[start:end])
+	}
+	if len(pages) <= 1 {
+		var posts []model.Post
+		if len(pages) == 1 {
+			posts = pages[0]
+		}
+		document, err := sitemapURLs(baseURL, posts)
+		if err != nil {
+			return nil, err
+		}
+		return []sitemapDocument{document}, nil
+	}
+
+	documents := make([]sitemapDocument, 1, len(pages)+1)
+	index := sitemapIndex{}
+	for i, posts := range pages {
+		document, err := sitemapURLs(baseURL, posts)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{
+			Loc:     fmt.Sprintf("%s/sitemaps/%d.xml", baseURL, i+1),
+			LastMod: document.modified.UTC().Format(time.RFC3339),
+		})
+		if document.modified.After(documents[0].modified) {
+			documents[0].modified = document.modified
+		}
+	}
+	data, _, err := encodeXml(index, "")
+	if err != nil {
+		return nil, err
+	}
+	documents[0].data = data
+	return documents, nil
+}
+
+func sitemapURLs(baseURL string, posts []model.Post) (sitemapDocument, error) {
+	var document sitemapDocument
+	set := sitemapURLSet{}
+	for _, post := range posts {
+		loc := baseURL + postLocation(post.Id)
+		if post.Slug != "" {
+			loc = baseURL + postSlugLocation(post.Slug)
+		}
+		modified := lastChange(post.CreationDate, post.EditedAt)
+		set.URLs = append(set.URLs, sitemapURL{Loc: loc, LastMod: modified.UTC().Format(time.RFC3339)})
+		if modified.After(document.modified) {
+			document.modified = modified
+		}
+	}
+	data, _, err := encodeXml(set, "")
+	document.data = data
+	return document, err
+}
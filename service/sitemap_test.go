@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestRestApiService_sitemap(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var edited = testDate.Add(48 * time.Hour)
+	var posts = []model.Post{
+		{Id: 1, Title: "first", Content: "content", CreationDate: testDate},
+		{Id: 2, Title: "second", Content: "content", CreationDate: testDate.Add(time.Hour), EditedAt: &edited},
+		{Id: 3, Title: "draft", Content: "content", Status: model.StatusDraft, CreationDate: testDate.Add(2 * time.Hour)},
+		{Id: 4, Title: "third", Content: "content", CreationDate: testDate.Add(3 * time.Hour)},
+	}
+
+	tests := []struct {
+		testName           string
+		pageSize           int
+		path               string
+		expectedHttpStatus int
+		expectedBody       string
+	}{
+		{testName: "testSitemap", pageSize: maxSitemapURLs, path: sitemapPath, expectedHttpStatus: 200,
+			expectedBody: `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://blog.example.com/api/posts/by-slug/first</loc>
+    <lastmod>2018-09-16T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://blog.example.com/api/posts/by-slug/second</loc>
+    <lastmod>2018-09-18T12:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://blog.example.com/api/posts/by-slug/third</loc>
+    <lastmod>2018-09-16T15:00:00Z</lastmod>
+  </url>
+</urlset>`},
+		{testName: "testNoPagesWhenNotSplit", pageSize: maxSitemapURLs, path: "/sitemaps/1.xml", expectedHttpStatus: 404},
+		{testName: "testSitemapIndex", pageSize: 2, path: sitemapPath, expectedHttpStatus: 200,
+			expectedBody: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://blog.example.com/sitemaps/1.xml</loc>
+    <lastmod>2018-09-18T12:00:00Z</lastmod>
+  </sitemap>
+  <sitemap>
+    <loc>https://blog.example.com/sitemaps/2.xml</loc>
+    <lastmod>2018-09-16T15:00:00Z</lastmod>
+  </sitemap>
+</sitemapindex>`},
+		{testName: "testSitemapPage", pageSize: 2, path: "/sitemaps/2.xml", expectedHttpStatus: 200,
+			expectedBody: `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://blog.example.com/api/posts/by-slug/third</loc>
+    <lastmod>2018-09-16T15:00:00Z</lastmod>
+  </url>
+</urlset>`},
+		{testName: "testSitemapPageOutOfRange", pageSize: 2, path: "/sitemaps/3.xml", expectedHttpStatus: 404},
+		{testName: "testSitemapPageZero", pageSize: 2, path: "/sitemaps/0.xml", expectedHttpStatus: 404},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiServiceWithStores(repository.CustomPostRepository(posts), repository.NewCommentRepository(), WithBaseURL("https://blog.example.com"))
+			svc.sitemap.pageSize = tc.pageSize
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+
+			// THEN
+			assert.Equal(t, tc.expectedHttpStatus, w.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.Equal(t, xml.Header+tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRestApiService_sitemapRegeneratedOnPostChanges(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiService()
+	router := svc.router()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	locations := func() []string {
+		w := serve(http.MethodGet, sitemapPath, "")
+		require.Equal(t, 200, w.Code)
+		var set sitemapURLSet
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &set))
+		var locs []string
+		for _, url := range set.URLs {
+			locs = append(locs, strings.TrimPrefix(url.Loc, "http://example.com"))
+		}
+		return locs
+	}
+	assert.Empty(t, locations())
+
+	// WHEN / THEN
+	require.Equal(t, 200, serve(http.MethodPost, postsPath, `{"Id": 1, "Title": "first", "Content": "content"}`).Code)
+	assert.Equal(t, []string{"/api/posts/by-slug/first"}, locations())
+
+	require.Equal(t, 200, serve(http.MethodPatch, "/api/posts/1", `{"Title": "renamed"}`).Code)
+	assert.Equal(t, []string{"/api/posts/by-slug/renamed"}, locations())
+
+	require.Equal(t, 200, serve(http.MethodPatch, "/api/posts/1", `{"Status": "draft"}`).Code)
+	assert.Empty(t, locations())
+
+	publishAt := time.Now().Add(-time.Minute)
+	require.NoError(t, svc.postRepository.Insert(model.Post{Id: 2, Title: "scheduled", Status: model.StatusScheduled, PublishAt: &publishAt, CreationDate: publishAt}))
+	assert.Empty(t, locations())
+	_, err := svc.publishDue(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/api/posts/by-slug/scheduled"}, locations())
+
+	require.Equal(t, 200, serve(http.MethodDelete, "/api/posts/2", "").Code)
+	assert.Empty(t, locations())
+}
+
+func TestRestApiService_robots(t *testing.T) {
+	tests := []struct {
+		testName     string
+		opts         []Option
+		expectedBody string
+	}{
+		{testName: "testDefaultRobots", expectedBody: "User-agent: *\nAllow: /\n\nSitemap: http://example.com/sitemap.xml\n"},
+		{testName: "testConfiguredRobots", opts: []Option{WithRobots("User-agent: *\nDisallow: /api/search"), WithBaseURL("https://blog.example.com")},
+			expectedBody: "User-agent: *\nDisallow: /api/search\n\nSitemap: https://blog.example.com/sitemap.xml\n"},
+		{testName: "testConfiguredSitemap", opts: []Option{WithRobots("User-agent: *\nDisallow:\nSitemap: https://cdn.example.com/sitemap.xml\n")},
+			expectedBody: "User-agent: *\nDisallow:\nSitemap: https://cdn.example.com/sitemap.xml\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			svc := NewRestApiService(tc.opts...)
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, robotsPath, nil))
+
+			// THEN
+			assert.Equal(t, 200, w.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRestApiService_sitemapHosts(t *testing.T) {
+	// GIVEN
+	svc := NewRestApiServiceWithStores(repository.CustomPostRepository([]model.Post{
+		{Id: 1, Title: "first", Content: "content", CreationDate: time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)},
+	}), repository.NewCommentRepository())
+	router := svc.router()
+	sitemap := func(host string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, sitemapPath, nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// WHEN
+	first := sitemap("blog.example.com")
+	other := sitemap("evil.example.com")
+	for i := 0; i < maxSitemapHosts; i++ {
+		require.Equal(t, 200, sitemap(fmt.Sprintf("host%d.example.com:8080", i)).Code)
+	}
+	invalid := sitemap("evil.example.com/path?")
+
+	// THEN
+	require.Equal(t, 200, first.Code)
+	assert.Contains(t, first.Body.String(), "<loc>http://blog.example.com/api/posts/by-slug/first</loc>")
+	require.Equal(t, 200, other.Code)
+	assert.Contains(t, other.Body.String(), "<loc>http://evil.example.com/api/posts/by-slug/first</loc>")
+	assert.Contains(t, sitemap("blog.example.com").Body.String(), "<loc>http://blog.example.com/")
+	assert.Equal(t, 400, invalid.Code)
+	assert.Len(t, svc.sitemap.documents, maxSitemapHosts)
+	assert.Len(t, svc.sitemap.baseURLs, maxSitemapHosts)
+}
+
+func TestValidHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{host: "example.com", expected: true},
+		{host: "Blog.Example.com:8080", expected: true},
+		{host: "localhost", expected: true},
+		{host: "127.0.0.1:80", expected: true},
+		{host: "[::1]", expected: true},
+		{host: "[::1]:8080", expected: true},
+		{host: "", expected: false},
+		{host: "example.com:", expected: false},
+		{host: "example.com:0", expected: false},
+		{host: "example.com:70000", expected: false},
+		{host: "example..com", expected: false},
+		{host: "-example.com", expected: false},
+		{host: "example.com/evil", expected: false},
+		{host: "user@example.com", expected: false},
+		{host: "[127.0.0.1]", expected: false},
+		{host: "[not-an-ip]", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			assert.Equal(t, tc.expected, validHost(tc.host))
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/mindera/go-rest-blog/auth"
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const defaultTokenTTL = 24 * time.Hour
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+	minPasswordLength = 8
+)
+
+const (
+	usersPath  = "/api/users"
+	userPath   = usersPath + "/{id:[0-9]+}"
+	rolePath   = userPath + "/role"
+	mePath     = usersPath + "/me"
+	tokensPath = "/api/tokens"
+)
+
+type contextKey int
+
+const userContextKey contextKey = iota
+
+// WithUsers keeps user accounts in the store. Without users every caller is
+// anonymous and may write.
+func WithUsers(users repository.UserStore) Option {
+	return func(svc *RestApiService) {
+		svc.userRepository = users
+	}
+}
+
+// WithTokenKey signs bearer tokens with the key. Tokens signed with a random
+// key are only valid until the service restarts.
+func WithTokenKey(key []byte) Option {
+	return func(svc *RestApiService) {
+		svc.tokens = auth.NewSigner(key)
+	}
+}
+
+// WithTokenTTL sets how long issued bearer tokens stay valid.
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(svc *RestApiService) {
+		svc.tokenTTL = ttl
+	}
+}
+
+// Credentials is the payload registering a user and exchanged for a token.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// UserResponse is a user as served by the API, without its password hash.
+type UserResponse struct {
+	Id           uint64
+	Username     string
+	Role         string
+	CreationDate time.Time
+}
+
+// RoleRequest is the payload changing the role of a user.
+type RoleRequest struct {
+	Role string
+}
+
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"tokenType"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func newUserResponse(user model.User) UserResponse {
+	return UserResponse{Id: user.Id, Username: user.Username, Role: user.UserRole(), CreationDate: user.CreationDate}
+}
+
+func randomTokenKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("could not generate token key: %v", err))
+	}
+	return key
+}
+
+// handler is the router behind the authentication middleware.
+func (svc *RestApiService) handler() http.Handler {
+	return svc.authenticate(svc.router())
+}
+
+// authenticate identifies the caller by the bearer token in the
+// Authorization header or the API key in the X-Api-Key header and answers
+// writes by anonymous callers with 401, except for registering and logging
+// in.
+func (svc *RestApiService) authenticate(next http.Handler) http.Handler {
+	if svc.userRepository == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header, secret := r.Header.Get("Authorization"), r.Header.Get(apiKeyHeader)
+		if header != "" && secret != "" {
+			svc.writeError(w, r, newApiError(http.StatusBadRequest, "send either an Authorization or an %s header, not both", apiKeyHeader))
+			return
+		}
+		if secret != "" {
+			user, key, err := svc.apiKeyUser(secret)
+			if err != nil {
+				svc.writeError(w, r, err)
+				return
+			}
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			r = r.WithContext(context.WithValue(ctx, apiKeyContextKey, key))
+		} else if header != "" {
+			user, err := svc.tokenUser(header)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				svc.writeError(w, r, err)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+		} else if requiresAuthentication(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			svc.writeError(w, r, newApiError(http.StatusUnauthorized, "authentication is required to %s %s", r.Method, r.URL.Path))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (svc *RestApiService) tokenUser(header string) (*model.User, error) {
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header {
+		return nil, newApiError(http.StatusUnauthorized, "Authorization header must hold a Bearer token")
+	}
+	id, err := svc.tokens.Verify(token, time.Now())
+	if errors.Is(err, auth.ErrTokenExpired) {
+		return nil, newApiError(http.StatusUnauthorized, "bearer token expired")
+	}
+	if err != nil {
+		return nil, newApiError(http.StatusUnauthorized, "bearer token is invalid")
+	}
+	user, err := svc.userRepository.GetById(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, newApiError(http.StatusUnauthorized, "bearer token is invalid")
+	}
+	return user, err
+}
+
+func requiresAuthentication(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	case http.MethodPost:
+		return r.URL.Path != usersPath && r.URL.Path != tokensPath
+	}
+	return true
+}
+
+// currentUser returns the authenticated caller, nil for anonymous callers.
+func currentUser(r *http.Request) *model.User {
+	user, _ := r.Context().Value(userContextKey).(*model.User)
+	return user
+}
+
+// requireUser returns the authenticated caller, failing with 401 for
+// anonymous callers of routes the middleware lets through without
+// authentication.
+func requireUser(w http.ResponseWriter, r *http.Request) (*model.User, error) {
+	user := currentUser(r)
+	if user == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		return nil, newApiError(http.StatusUnauthorized, "authentication is required to %s %s", r.Method, r.URL.Path)
+	}
+	return user, nil
+}
+
+// SeedAdmin makes sure an admin with the username and password exists,
+// creating the user or promoting and updating an existing one, so that a
+// blog has somebody to hand out roles.
+func (svc *RestApiService) SeedAdmin(username, password string) error {
+	if svc.userRepository == nil {
+		return errors.New("admin: users are disabled")
+	}
+	credentials := Credentials{Username: username, Password: password}
+	if fieldErrors := validateCredentials(credentials); len(fieldErrors) > 0 {
+		return fmt.Errorf("admin: %s %s", strings.ToLower(fieldErrors[0].Field), fieldErrors[0].Reason)
+	}
+	user, err := svc.userRepository.GetByUsername(username)
+	if errors.Is(err, repository.ErrNotFound) {
+		hash, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		_, err = svc.userRepository.Create(model.User{Username: username, PasswordHash: hash, Role: model.RoleAdmin})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if auth.CheckPassword(user.PasswordHash, password) != nil {
+		if user.PasswordHash, err = auth.HashPassword(password); err != nil {
+			return err
+		}
+	} else if user.Role == model.RoleAdmin {
+		return nil
+	}
+	user.Role = model.RoleAdmin
+	return svc.userRepository.Update(*user)
+}
+
+// handleRegister serves POST /api/users, creating a reader with the given
+// username and password.
+func (svc *RestApiService) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var credentials Credentials
+	_, fieldErrors, err := decodeRequestPayload(r, &credentials)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize user json payload"))
+		return
+	}
+	if fieldErrors = mergeFieldErrors(fieldErrors, validateCredentials(credentials)); len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	hash, err := auth.HashPassword(credentials.Password)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	user, err := svc.userRepository.Create(model.User{Username: credentials.Username, PasswordHash: hash, Role: model.RoleReader})
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "User: %s already exists", credentials.Username))
+		return
+	}
+	w.Header().Set("Location", userLocation(user.Id))
+	writeJsonResponse(w, http.StatusOK, AckJsonResponse{Message: fmt.Sprintf("user id: %d successfully registered", user.Id), Status: http.StatusOK, Id: user.Id})
+}
+
+// handleGetUser serves GET /api/users/{id}. Users may read themselves; only
+// those allowed to manage users may read the others, so that usernames and
+// roles cannot be enumerated.
+func (svc *RestApiService) handleGetUser(w http.ResponseWriter, r *http.Request) {
+	caller, err := requireUser(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	if id != caller.Id {
+		if err := svc.authorize(r, manageUsers, 0); err != nil {
+			svc.writeError(w, r, err)
+			return
+		}
+	}
+	user, err := svc.userRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "User with id: %d does not exist", id))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, newUserResponse(*user))
+}
+
+func (svc *RestApiService) handleGetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	user, err := requireUser(w, r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, newUserResponse(*user))
+}
+
+// handleSetRole serves PUT /api/users/{id}/role, changing the role of a
+// user.
+func (svc *RestApiService) handleSetRole(w http.ResponseWriter, r *http.Request) {
+	if err := svc.authorize(r, manageUsers, 0); err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	id, err := parseIdPathVariable(r)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	var request RoleRequest
+	_, fieldErrors, err := decodeRequestPayload(r, &request)
+	if err != nil {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize role json payload"))
+		return
+	}
+	if _, ok := policy[request.Role]; !ok {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Role", Reason: "must be one of " + strings.Join(roles, ", ")})
+	}
+	if len(fieldErrors) > 0 {
+		svc.writeError(w, r, validationError(fieldErrors))
+		return
+	}
+	user, err := svc.userRepository.GetById(id)
+	if err != nil {
+		svc.writeError(w, r, storeError(err, "User with id: %d does not exist", id))
+		return
+	}
+	user.Role = request.Role
+	if err := svc.userRepository.Update(*user); err != nil {
+		svc.writeError(w, r, storeError(err, "User with id: %d does not exist", id))
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, newUserResponse(*user))
+}
+
+// handleIssueToken serves POST /api/tokens, exchanging the username and
+// password of a user for a bearer token.
+func (svc *RestApiService) handleIssueToken(w http.ResponseWriter, r *http.Request) {
+	var credentials Credentials
+	if _, fieldErrors, err := decodeRequestPayload(r, &credentials); err != nil || len(fieldErrors) > 0 {
+		svc.writeError(w, r, newApiError(http.StatusBadRequest, "could not deserialize credentials json payload"))
+		return
+	}
+	user, err := svc.userRepository.GetByUsername(credentials.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		svc.writeError(w, r, err)
+		return
+	}
+	// Unknown users are checked against a dummy hash so that the response
+	// time does not reveal which usernames exist.
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = user.PasswordHash
+	}
+	if err := auth.CheckPassword(hash, credentials.Password); err != nil || user == nil {
+		svc.writeError(w, r, newApiError(http.StatusUnauthorized, "wrong username or password"))
+		return
+	}
+	now := time.Now().UTC()
+	ttl := svc.tokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	token, err := svc.tokens.Issue(user.Id, now, ttl)
+	if err != nil {
+		svc.writeError(w, r, err)
+		return
+	}
+	writeJsonResponse(w, http.StatusOK, TokenResponse{Token: token, TokenType: "Bearer", ExpiresAt: now.Add(ttl).Truncate(time.Second)})
+}
+
+func validateCredentials(credentials Credentials) []FieldError {
+	var fieldErrors []FieldError
+	if n := len(credentials.Username); n < minUsernameLength || n > maxUsernameLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Username", Reason: fmt.Sprintf("must be between %d and %d characters long", minUsernameLength, maxUsernameLength)})
+	} else if strings.IndexFunc(credentials.Username, func(c rune) bool { return !isUsernameChar(c) }) >= 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Username", Reason: "may only contain letters, digits, '.', '_' and '-'"})
+	}
+	if n := len(credentials.Password); n < minPasswordLength || n > auth.MaxPasswordLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Password", Reason: fmt.Sprintf("must be between %d and %d bytes long", minPasswordLength, auth.MaxPasswordLength)})
+	}
+	return fieldErrors
+}
+
+func isUsernameChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+func dummyPasswordHash() string {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = auth.HashPassword("not the password of any user")
+	})
+	return dummyHash
+}
+
+func userLocation(id uint64) string {
+	return strings.Replace(userPath, "{id:[0-9]+}", strconv.FormatUint(id, 10), 1)
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+const (
+	maxTitleLength   = 200
+	maxContentLength = 100000
+	maxCommentLength = 5000
+	maxAuthorLength  = 100
+	maxTags          = 10
+	maxTagLength     = 50
+)
+
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+type ValidationErrorResponse struct {
+	Message string       `json:"message"`
+	Status  int          `json:"status"`
+	Errors  []FieldError `json:"errors"`
+}
+
+// decodeJsonPayload decodes the JSON object in data into target. Fields that
+// target does not declare and fields of the wrong type are reported as field
+// errors; err is only set when data is not a JSON object at all. The returned
+// set holds the target field names present in the payload.
+func decodeJsonPayload(data []byte, target interface{}) (map[string]bool, []FieldError, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+	if raw == nil {
+		return nil, nil, errors.New("payload is not a json object")
+	}
+	known := jsonFieldNames(reflect.TypeOf(target).Elem())
+	present := make(map[string]bool, len(raw))
+	var fieldErrors []FieldError
+	for key, value := range raw {
+		name, ok := matchFieldName(known, key)
+		if !ok {
+			fieldErrors = append(fieldErrors, FieldError{Field: key, Reason: "is not a known field"})
+			delete(raw, key)
+			continue
+		}
+		field := reflect.New(reflect.TypeOf(target).Elem()).Interface()
+		if err := json.Unmarshal([]byte(fmt.Sprintf("{%q: %s}", name, value)), field); err != nil {
+			fieldErrors = append(fieldErrors, FieldError{Field: name, Reason: "has the wrong type"})
+			delete(raw, key)
+			continue
+		}
+		present[name] = true
+	}
+	valid, _ := json.Marshal(raw)
+	if err := json.Unmarshal(valid, target); err != nil {
+		return nil, nil, err
+	}
+	sortFieldErrors(fieldErrors)
+	return present, fieldErrors, nil
+}
+
+func decodeRequestPayload(r *http.Request, target interface{}) (map[string]bool, []FieldError, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeJsonPayload(data, target)
+}
+
+func validatePost(post model.Post, present map[string]bool) []FieldError {
+	var fieldErrors []FieldError
+	fieldErrors = append(fieldErrors, validateText("Title", post.Title, maxTitleLength)...)
+	fieldErrors = append(fieldErrors, validateText("Content", post.Content, maxContentLength)...)
+	if post.Format != "" && post.Format != model.FormatPlain && post.Format != model.FormatMarkdown {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Format", Reason: fmt.Sprintf("must be one of %s or %s", model.FormatPlain, model.FormatMarkdown)})
+	}
+	fieldErrors = append(fieldErrors, validateCreationDate(post.CreationDate, present)...)
+	fieldErrors = append(fieldErrors, validateTags(post.Tags)...)
+	if utf8.RuneCountInString(post.Category) > maxTagLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Category", Reason: fmt.Sprintf("must be at most %d characters long", maxTagLength)})
+	}
+	if post.Status != "" && !postStatuses[post.Status] {
+		fieldErrors = append(fieldErrors, FieldError{Field: "Status", Reason: fmt.Sprintf("must be one of %s, %s, %s or %s",
+			model.StatusDraft, model.StatusScheduled, model.StatusPublished, model.StatusArchived)})
+	}
+	if post.Status == model.StatusScheduled && post.PublishAt == nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "PublishAt", Reason: "is required for scheduled posts"})
+	}
+	return fieldErrors
+}
+
+func validateTags(tags []string) []FieldError {
+	if len(tags) > maxTags {
+		return []FieldError{{Field: "Tags", Reason: fmt.Sprintf("must hold at most %d tags", maxTags)}}
+	}
+	for _, tag := range tags {
+		if repository.NormalizeTag(tag) == "" {
+			return []FieldError{{Field: "Tags", Reason: "must not hold tags without letters or digits"}}
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return []FieldError{{Field: "Tags", Reason: fmt.Sprintf("must hold tags of at most %d characters", maxTagLength)}}
+		}
+	}
+	return nil
+}
+
+func validateComment(comment model.Comment, present map[string]bool) []FieldError {
+	var fieldErrors []FieldError
+	if comment.PostId == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "PostId", Reason: "is required"})
+	}
+	fieldErrors = append(fieldErrors, validateText("Comment", comment.Comment, maxCommentLength)...)
+	fieldErrors = append(fieldErrors, validateText("Author", comment.Author, maxAuthorLength)...)
+	fieldErrors = append(fieldErrors, validateCreationDate(comment.CreationDate, present)...)
+	return fieldErrors
+}
+
+// validateParent checks that a reply refers to a comment of the same post
+// that was not deleted, and that it stays within maxCommentDepth.
+func (svc *RestApiService) validateParent(comment model.Comment) error {
+	if comment.ParentId == 0 {
+		return nil
+	}
+	parent, err := svc.commentRepository.GetById(comment.ParentId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return parentError("does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if parent.PostId != comment.PostId {
+		return parentError("must be a comment of the same post")
+	}
+	if parent.Deleted {
+		return parentError("was deleted")
+	}
+	for depth := 1; parent.ParentId != 0; depth++ {
+		if depth >= maxCommentDepth {
+			return parentError(fmt.Sprintf("exceeds the maximum nesting depth of %d", maxCommentDepth))
+		}
+		parent, err = svc.commentRepository.GetById(parent.ParentId)
+		if errors.Is(err, repository.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parentError(reason string) error {
+	return validationError([]FieldError{{Field: "ParentId", Reason: reason}})
+}
+
+func validateText(field string, value string, maxLength int) []FieldError {
+	if strings.TrimSpace(value) == "" {
+		return []FieldError{{Field: field, Reason: "is required"}}
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		return []FieldError{{Field: field, Reason: fmt.Sprintf("must be at most %d characters long", maxLength)}}
+	}
+	return nil
+}
+
+// validateCreationDate rejects an explicitly zero creation date; when the
+// field is omitted the server fills it in.
+func validateCreationDate(date time.Time, present map[string]bool) []FieldError {
+	if present["CreationDate"] && date.IsZero() {
+		return []FieldError{{Field: "CreationDate", Reason: "must not be zero"}}
+	}
+	return nil
+}
+
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, ok := jsonFieldName(t.Field(i)); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// jsonFieldName returns the key encoding/json uses for the field, false for
+// fields it skips.
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" || field.PkgPath != "" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// matchFieldName mirrors encoding/json, which matches keys to fields
+// ignoring case.
+func matchFieldName(known []string, key string) (string, bool) {
+	for _, name := range known {
+		if strings.EqualFold(name, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func sortFieldErrors(fieldErrors []FieldError) {
+	sort.Slice(fieldErrors, func(i, j int) bool {
+		return fieldErrors[i].Field < fieldErrors[j].Field
+	})
+}
+
+// mergeFieldErrors appends the validation errors of fields that did not
+// already fail decoding.
+func mergeFieldErrors(decodeErrors []FieldError, validationErrors []FieldError) []FieldError {
+	reported := make(map[string]bool, len(decodeErrors))
+	for _, fieldError := range decodeErrors {
+		reported[fieldError.Field] = true
+	}
+	merged := decodeErrors
+	for _, fieldError := range validationErrors {
+		if !reported[fieldError.Field] {
+			merged = append(merged, fieldError)
+		}
+	}
+	return merged
+}
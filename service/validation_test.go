@@ -0,0 +1,209 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/mindera/go-rest-blog/model"
+	"bitbucket.org/mindera/go-rest-blog/repository"
+)
+
+func TestValidatePost(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected []FieldError
+	}{
+		{
+			name:    "valid post",
+			payload: `{"Title": "title", "Content": "content", "CreationDate": "2018-09-16T12:00:00Z"}`,
+		},
+		{
+			name:    "creation date may be omitted",
+			payload: `{"title": "title", "content": "content"}`,
+		},
+		{
+			name:    "missing fields",
+			payload: `{"Title": "  "}`,
+			expected: []FieldError{
+				{Field: "Title", Reason: "is required"},
+				{Field: "Content", Reason: "is required"},
+			},
+		},
+		{
+			name:    "too long",
+			payload: `{"Title": "` + strings.Repeat("é", maxTitleLength+1) + `", "Content": "content"}`,
+			expected: []FieldError{
+				{Field: "Title", Reason: "must be at most 200 characters long"},
+			},
+		},
+		{
+			name:    "zero creation date",
+			payload: `{"Title": "title", "Content": "content", "CreationDate": "0001-01-01T00:00:00Z"}`,
+			expected: []FieldError{
+				{Field: "CreationDate", Reason: "must not be zero"},
+			},
+		},
+		{
+			name:    "too many tags",
+			payload: `{"Title": "title", "Content": "content", "Tags": ["1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"]}`,
+			expected: []FieldError{
+				{Field: "Tags", Reason: "must hold at most 10 tags"},
+			},
+		},
+		{
+			name:    "tag without letters",
+			payload: `{"Title": "title", "Content": "content", "Tags": ["go", "?!"]}`,
+			expected: []FieldError{
+				{Field: "Tags", Reason: "must not hold tags without letters or digits"},
+			},
+		},
+		{
+			name:    "markdown post",
+			payload: `{"Title": "title", "Content": "*content*", "Format": "markdown"}`,
+		},
+		{
+			name:    "unknown format",
+			payload: `{"Title": "title", "Content": "content", "Format": "html"}`,
+			expected: []FieldError{
+				{Field: "Format", Reason: "must be one of plain or markdown"},
+			},
+		},
+		{
+			name:    "unknown and mistyped fields",
+			payload: `{"Title": 42, "Content": "content", "Body": "text"}`,
+			expected: []FieldError{
+				{Field: "Body", Reason: "is not a known field"},
+				{Field: "Title", Reason: "has the wrong type"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var post model.Post
+			present, fieldErrors, err := decodeJsonPayload([]byte(tt.payload), &post)
+			require.NoError(t, err)
+			fieldErrors = mergeFieldErrors(fieldErrors, validatePost(post, present))
+			assert.Equal(t, tt.expected, fieldErrors)
+		})
+	}
+}
+
+func TestValidateComment(t *testing.T) {
+	var valid = model.Comment{PostId: 3, Comment: "comment", Author: "author", CreationDate: time.Now()}
+
+	assert.Empty(t, validateComment(valid, nil))
+	assert.Equal(t, []FieldError{
+		{Field: "PostId", Reason: "is required"},
+		{Field: "Comment", Reason: "is required"},
+		{Field: "Author", Reason: "must be at most 100 characters long"},
+	}, validateComment(model.Comment{Author: strings.Repeat("a", maxAuthorLength+1)}, nil))
+}
+
+func TestDecodeJsonPayload_NotAnObject(t *testing.T) {
+	for _, payload := range []string{`invalidJson`, `null`, `[1, 2]`} {
+		_, _, err := decodeJsonPayload([]byte(payload), &model.Post{})
+		assert.Error(t, err, payload)
+	}
+}
+
+func TestRestApiService_validationErrors(t *testing.T) {
+	var testDate = time.Date(2018, time.September, 16, 12, 0, 0, 0, time.UTC)
+	var existingPost = model.Post{Id: 3, Title: "title", Slug: "title", Content: "content", CreationDate: testDate}
+	var existingComment = model.Comment{Id: 123, PostId: 3, Comment: "abc", Author: "author", CreationDate: testDate}
+
+	tests := []struct {
+		testName       string
+		method         string
+		path           string
+		reqBody        string
+		expectedErrors []FieldError
+	}{
+		{
+			testName: "testAddPost",
+			method:   http.MethodPost,
+			path:     postsPath,
+			reqBody:  `{"Title": "", "Labels": ["go"]}`,
+			expectedErrors: []FieldError{
+				{Field: "Labels", Reason: "is not a known field"},
+				{Field: "Title", Reason: "is required"},
+				{Field: "Content", Reason: "is required"},
+			},
+		},
+		{
+			testName:       "testReplacePost",
+			method:         http.MethodPut,
+			path:           "/api/posts/3",
+			reqBody:        `{"Title": "title"}`,
+			expectedErrors: []FieldError{{Field: "Content", Reason: "is required"}},
+		},
+		{
+			testName:       "testPatchPost",
+			method:         http.MethodPatch,
+			path:           "/api/posts/3",
+			reqBody:        `{"Title": null, "Draft": true}`,
+			expectedErrors: []FieldError{{Field: "Draft", Reason: "is not a known field"}, {Field: "Title", Reason: "is required"}},
+		},
+		{
+			testName:       "testAddComment",
+			method:         http.MethodPost,
+			path:           commentsPath,
+			reqBody:        `{"PostId": "3", "Comment": "abc", "Author": "author"}`,
+			expectedErrors: []FieldError{{Field: "PostId", Reason: "has the wrong type"}},
+		},
+		{
+			testName:       "testPatchComment",
+			method:         http.MethodPatch,
+			path:           "/api/comments/123",
+			reqBody:        `{"Comment": "` + strings.Repeat("a", maxCommentLength+1) + `"}`,
+			expectedErrors: []FieldError{{Field: "Comment", Reason: "must be at most 5000 characters long"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			// GIVEN
+			postRepository := repository.CustomPostRepository([]model.Post{existingPost})
+			commentRepository := repository.CustomCommentRepository([]model.Comment{existingComment})
+			svc := NewRestApiServiceWithStores(postRepository, commentRepository)
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.reqBody))
+			w := httptest.NewRecorder()
+
+			// WHEN
+			svc.router().ServeHTTP(w, req)
+			response := w.Result()
+			body, _ := io.ReadAll(response.Body)
+
+			// THEN
+			assert.Equal(t, http.StatusUnprocessableEntity, response.StatusCode)
+			assert.Equal(t, problemContentType, response.Header.Get("Content-Type"))
+			var resp ProblemResponse
+			require.NoError(t, json.Unmarshal(body, &resp))
+			assert.Equal(t, ProblemResponse{
+				Type:     "about:blank",
+				Title:    "Unprocessable Entity",
+				Status:   http.StatusUnprocessableEntity,
+				Detail:   "payload validation failed",
+				Instance: tc.path,
+				Errors:   tc.expectedErrors,
+			}, resp)
+			stored, err := postRepository.GetById(existingPost.Id)
+			require.NoError(t, err)
+			assert.Equal(t, existingPost, *stored)
+			page, err := postRepository.Query(repository.PostQuery{})
+			require.NoError(t, err)
+			assert.Equal(t, 1, page.Total)
+			storedComment, err := commentRepository.GetById(existingComment.Id)
+			require.NoError(t, err)
+			assert.Equal(t, existingComment, *storedComment)
+		})
+	}
+}